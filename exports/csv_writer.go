@@ -0,0 +1,68 @@
+package exports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// flushEvery bounds how many rows are held before they are sent on.
+const flushEvery = 500
+
+type csvWriter struct {
+	out  io.Writer
+	csv  *csv.Writer
+	rows int
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{out: w, csv: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = formatCSV(normalize(value))
+	}
+	if err := w.csv.Write(record); err != nil {
+		return err
+	}
+
+	w.rows++
+	if w.rows%flushEvery == 0 {
+		w.csv.Flush()
+		if flusher, ok := w.out.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	return w.csv.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func formatCSV(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return escapeFormula(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// Abort leaves the rows already sent as they are.
+func (w *csvWriter) Abort() {}
@@ -0,0 +1,90 @@
+package exports
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Reader returns the rows of an uploaded table one at a time, starting with
+// the header row. Read returns io.EOF after the last row.
+type Reader interface {
+	Read() ([]string, error)
+	Close() error
+}
+
+// NewReader reads a CSV file or the first sheet of an XLSX workbook.
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		return &csvReader{csv: reader}, nil
+	case FormatXLSX:
+		file, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		sheets := file.GetSheetList()
+		if len(sheets) == 0 {
+			file.Close()
+			return nil, errors.New("workbook has no sheets")
+		}
+		rows, err := file.Rows(sheets[0])
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &xlsxReader{file: file, rows: rows}, nil
+	}
+	return nil, errors.New("format must be csv or xlsx")
+}
+
+type csvReader struct {
+	csv  *csv.Reader
+	read bool
+}
+
+func (r *csvReader) Read() ([]string, error) {
+	record, err := r.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+	// Spreadsheet programs often save CSV files with a byte order mark.
+	if !r.read && len(record) > 0 {
+		record[0] = strings.TrimPrefix(record[0], "\ufeff")
+	}
+	r.read = true
+	for i, field := range record {
+		record[i] = unescapeFormula(field)
+	}
+	return record, nil
+}
+
+func (r *csvReader) Close() error {
+	return nil
+}
+
+type xlsxReader struct {
+	file *excelize.File
+	rows *excelize.Rows
+}
+
+func (r *xlsxReader) Read() ([]string, error) {
+	if !r.rows.Next() {
+		if err := r.rows.Error(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return r.rows.Columns()
+}
+
+func (r *xlsxReader) Close() error {
+	r.rows.Close()
+	return r.file.Close()
+}
@@ -0,0 +1,61 @@
+package exports
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReaderRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatXLSX} {
+		var buf bytes.Buffer
+		w, err := NewWriter(format, &buf, []string{"name", "price"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteRow("-Aqua 600ml", 3500); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(format, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got [][]string
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, record)
+		}
+		r.Close()
+
+		want := [][]string{{"name", "price"}, {"-Aqua 600ml", "3500"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", format, got, want)
+		}
+	}
+}
+
+func TestCSVReaderStripsByteOrderMark(t *testing.T) {
+	r, err := NewReader(FormatCSV, strings.NewReader("\ufeffname,price\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != "name" {
+		t.Errorf("header[0] = %q, want %q", header[0], "name")
+	}
+}
@@ -0,0 +1,116 @@
+// Package exports reads and writes tables as CSV or XLSX one row at a time, so
+// exports can be streamed from a database cursor.
+package exports
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var ErrUnsupportedFormat = errors.New("format must be json, csv or xlsx")
+
+// Writer receives the rows of one table. Close finishes the output; Abort
+// releases the writer without finishing it after an error.
+type Writer interface {
+	WriteRow(values ...interface{}) error
+	Close() error
+	Abort()
+}
+
+// NewWriter returns a Writer for format that has already written the header
+// row with columns.
+func NewWriter(format string, w io.Writer, columns []string) (Writer, error) {
+	var writer Writer
+	switch format {
+	case FormatCSV:
+		writer = newCSVWriter(w)
+	case FormatXLSX:
+		var err error
+		writer, err = newXLSXWriter(w)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	if err := writer.WriteRow(header...); err != nil {
+		writer.Abort()
+		return nil, err
+	}
+	return writer, nil
+}
+
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// formulaStarts are the characters that make a spreadsheet read a cell as a
+// formula when they start it.
+const formulaStarts = "=+-@\t\r"
+
+// escapeFormula puts an apostrophe before text that a spreadsheet would run
+// as a formula, such as a product named =HYPERLINK(...), so it opens as text.
+func escapeFormula(s string) string {
+	if s != "" && strings.IndexByte(formulaStarts, s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeFormula undoes escapeFormula, so an export can be imported again.
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.IndexByte(formulaStarts, s[1]) >= 0 {
+		return s[1:]
+	}
+	return s
+}
+
+// decimal is a fixed-point number, such as a quantity, that is written to a
+// spreadsheet as a number.
+type decimal interface {
+	Float64() float64
+}
+
+// normalize dereferences optional values so a nil pointer becomes an empty
+// cell rather than an address.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case decimal:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil
+		}
+		return v.Float64()
+	case *int:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return value
+}
@@ -0,0 +1,86 @@
+package exports
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf, []string{"id", "name", "customer_id", "created_at", "share"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var customer *int
+	created := time.Date(2026, 2, 1, 9, 30, 0, 0, time.FixedZone("WIB", 7*3600))
+	if err := w.WriteRow(1, `Kopi "Susu"`, customer, created, 12.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id,name,customer_id,created_at,share\n1,\"Kopi \"\"Susu\"\"\",,2026-02-01T09:30:00+07:00,12.5\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCSVWriterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf, []string{"name", "phone", "note", "balance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(`=HYPERLINK("http://x","y")`, "+62812", "@home", -5); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "name,phone,note,balance\n\"'=HYPERLINK(\"\"http://x\"\",\"\"y\"\")\",'+62812,'@home,-5\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatXLSX, &buf, []string{"id", "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := 7
+	if err := w.WriteRow(&id, "=1+1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1][0] != "7" || rows[1][1] != "=1+1" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+	if formula, err := f.GetCellFormula(sheetName, "B2"); err != nil || formula != "" {
+		t.Errorf("B2 has formula %q (%v), want plain text", formula, err)
+	}
+}
+
+func TestNewWriterRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("pdf", &bytes.Buffer{}, nil); err != ErrUnsupportedFormat {
+		t.Errorf("got %v, want ErrUnsupportedFormat", err)
+	}
+}
@@ -0,0 +1,57 @@
+package exports
+
+import (
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const sheetName = "Sheet1"
+
+// xlsxWriter uses the excelize stream writer, which spills rows to a temporary
+// file once they outgrow its memory buffer. The workbook is written to out on
+// Close.
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter(sheetName)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxWriter{out: w, file: file, stream: stream}, nil
+}
+
+func (w *xlsxWriter) WriteRow(values ...interface{}) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+
+	// Strings become inline string cells, which are never evaluated, so text
+	// that looks like a formula needs no escaping here.
+	row := make([]interface{}, len(values))
+	for i, value := range values {
+		row[i] = normalize(value)
+	}
+	return w.stream.SetRow(cell, row)
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	return w.file.Write(w.out)
+}
+
+func (w *xlsxWriter) Abort() {
+	w.file.Close()
+}
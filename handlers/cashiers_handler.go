@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-kasir-api/models"
+	"go-kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CashierHandler struct {
+	service *services.CashierService
+}
+
+func NewCashierHandler(service *services.CashierService) *CashierHandler {
+	return &CashierHandler{service: service}
+}
+
+func (h *CashierHandler) HandleCashiers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getCashiers(w, r)
+	case http.MethodPost:
+		h.createCashier(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CashierHandler) getCashiers(w http.ResponseWriter, r *http.Request) {
+	cashiers, err := h.service.GetAll()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cashiers)
+}
+
+func (h *CashierHandler) createCashier(w http.ResponseWriter, r *http.Request) {
+	var cashier models.Cashier
+	err := json.NewDecoder(r.Body).Decode(&cashier)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cashier, err = h.service.Create(cashier)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cashier)
+}
+
+func (h *CashierHandler) HandleCashierByID(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getCashierByID(w, r)
+	case http.MethodPut:
+		h.updateCashier(w, r)
+	case http.MethodDelete:
+		h.deleteCashier(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CashierHandler) getCashierByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/cashiers/")
+	cashierID, err := strconv.Atoi(id)
+
+	if err != nil {
+		http.Error(w, "Invalid cashier ID", http.StatusBadRequest)
+		return
+	}
+
+	cashier, err := h.service.GetByID(cashierID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cashier)
+}
+
+func (h *CashierHandler) updateCashier(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/cashiers/")
+	cashierID, err := strconv.Atoi(id)
+
+	if err != nil {
+		http.Error(w, "Invalid cashier ID", http.StatusBadRequest)
+		return
+	}
+
+	var cashier models.Cashier
+	err = json.NewDecoder(r.Body).Decode(&cashier)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cashier, err = h.service.Update(cashierID, cashier)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cashier)
+}
+
+func (h *CashierHandler) deleteCashier(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/cashiers/")
+	cashierID, err := strconv.Atoi(id)
+
+	if err != nil {
+		http.Error(w, "Invalid cashier ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.Delete(cashierID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Cashier deleted"})
+}
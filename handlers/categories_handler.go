@@ -57,6 +57,33 @@ func (h *CategoryHandler) createCategory(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(category)
 }
 
+// HandleCategoryTree serves the categories nested under their parents.
+// root_id limits the tree to one category and everything below it.
+func (h *CategoryHandler) HandleCategoryTree(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rootID *int
+	if value := r.URL.Query().Get("root_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid root_id", http.StatusBadRequest)
+			return
+		}
+		rootID = &id
+	}
+
+	tree, err := h.service.GetTree(rootID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
 func (h *CategoryHandler) HandleCategoryByID(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
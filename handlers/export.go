@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"go-kasir-api/exports"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// exportFormat picks the response format from the format query parameter or,
+// failing that, the Accept header. An empty format means JSON.
+func exportFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+	case "json":
+		return "", nil
+	case exports.FormatCSV, exports.FormatXLSX:
+		return format, nil
+	default:
+		return "", exports.ErrUnsupportedFormat
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "text/csv"):
+		return exports.FormatCSV, nil
+	case strings.Contains(accept, exports.ContentType(exports.FormatXLSX)):
+		return exports.FormatXLSX, nil
+	}
+	return "", nil
+}
+
+// importFormat picks the format of an uploaded file from the format query
+// parameter, then its content type, then its file name. CSV is the default.
+func importFormat(r *http.Request, filename string, contentType string) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+	case exports.FormatCSV, exports.FormatXLSX:
+		return format, nil
+	default:
+		return "", errors.New("format must be csv or xlsx")
+	}
+
+	switch {
+	case strings.Contains(contentType, "spreadsheetml.sheet"), strings.HasSuffix(strings.ToLower(filename), ".xlsx"):
+		return exports.FormatXLSX, nil
+	}
+	return exports.FormatCSV, nil
+}
+
+// trackingWriter records whether any of the body has been sent.
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *trackingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeExport streams a table as a file download. fill writes the rows. An
+// error before any of the file has been sent becomes a 500; after that the
+// download is cut short and the error is logged.
+func writeExport(w http.ResponseWriter, format string, name string, columns []string, fill func(exports.Writer) error) {
+	w.Header().Set("Content-Type", exports.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+	out := &trackingWriter{ResponseWriter: w}
+
+	writer, err := exports.NewWriter(format, out, columns)
+	if err == nil {
+		err = fill(writer)
+		if err != nil {
+			writer.Abort()
+		} else {
+			err = writer.Close()
+		}
+	}
+	if err == nil {
+		return
+	}
+
+	if out.wrote {
+		log.Printf("Export %s aborted: %v", name, err)
+		return
+	}
+	w.Header().Del("Content-Disposition")
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
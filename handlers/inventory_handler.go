@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-kasir-api/exports"
+	"go-kasir-api/models"
+	"go-kasir-api/services"
+	"net/http"
+	"strconv"
+)
+
+type InventoryHandler struct {
+	service *services.InventoryService
+}
+
+func NewInventoryHandler(service *services.InventoryService) *InventoryHandler {
+	return &InventoryHandler{service: service}
+}
+
+func (h *InventoryHandler) HandleLowStock(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetLowStock(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *InventoryHandler) GetLowStock(w http.ResponseWriter, r *http.Request) {
+	days, err := queryInt(r, "days", 30)
+	if err != nil || days < 1 {
+		http.Error(w, "Invalid days", http.StatusBadRequest)
+		return
+	}
+	coverDays, err := queryInt(r, "cover_days", 14)
+	if err != nil || coverDays < 0 {
+		http.Error(w, "Invalid cover_days", http.StatusBadRequest)
+		return
+	}
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		columns := []string{"product_id", "product_name", "category_id", "stock", "reorder_point", "target_stock", "on_order",
+			"sold_in_window", "average_daily_sales", "suggested_quantity"}
+		writeExport(w, format, "low-stock", columns, func(out exports.Writer) error {
+			return h.service.EachLowStock(days, coverDays, outletID, categoryID, func(i models.LowStockItem) error {
+				return out.WriteRow(i.ProductID, i.ProductName, i.CategoryID, i.Stock, i.ReorderPoint, i.TargetStock, i.OnOrder,
+					i.SoldInWindow, i.AverageDailySales, i.SuggestedQuantity)
+			})
+		})
+		return
+	}
+
+	items, err := h.service.GetLowStock(days, coverDays, outletID, categoryID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+func (h *InventoryHandler) HandleExpiring(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetExpiring(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GetExpiring serves the expiring-soon report: batches that expire within
+// days, 30 by default, and those that already have.
+func (h *InventoryHandler) GetExpiring(w http.ResponseWriter, r *http.Request) {
+	days, err := queryInt(r, "days", 30)
+	if err != nil || days < 1 {
+		http.Error(w, "Invalid days", http.StatusBadRequest)
+		return
+	}
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		columns := []string{"batch_id", "product_id", "product_name", "outlet_id", "outlet_name", "lot_number", "expiry_date", "days_left",
+			"expired", "quantity", "unit", "value"}
+		writeExport(w, format, "expiring", columns, func(out exports.Writer) error {
+			return h.service.EachExpiring(days, outletID, categoryID, func(b models.ExpiringBatch) error {
+				return out.WriteRow(b.BatchID, b.ProductID, b.ProductName, b.OutletID, b.OutletName, b.LotNumber, b.ExpiryDate, b.DaysLeft,
+					b.Expired, b.Quantity, b.Unit, b.Value)
+			})
+		})
+		return
+	}
+
+	batches, err := h.service.GetExpiring(days, outletID, categoryID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batches)
+}
+
+func (h *InventoryHandler) HandleProductBatches(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetProductBatches(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *InventoryHandler) GetProductBatches(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batches, err := h.service.GetBatches(productID, outletID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batches)
+}
+
+// queryInt reads an integer query parameter, returning fallback when it is
+// missing.
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-kasir-api/models"
+	"go-kasir-api/services"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type PriceChangeHandler struct {
+	service *services.PriceChangeService
+}
+
+func NewPriceChangeHandler(service *services.PriceChangeService) *PriceChangeHandler {
+	return &PriceChangeHandler{service: service}
+}
+
+func (h *PriceChangeHandler) HandlePriceChanges(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	case http.MethodPost:
+		h.Create(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *PriceChangeHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	var productID *int
+	if value := r.URL.Query().Get("product_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid product ID", http.StatusBadRequest)
+			return
+		}
+		productID = &id
+	}
+
+	changes, err := h.service.GetAll(productID, r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
+
+// Create schedules a price change on behalf of whoever X-Changed-By names.
+func (h *PriceChangeHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var change models.PriceChange
+	err := json.NewDecoder(r.Body).Decode(&change)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	change.CreatedBy = changedBy(r)
+
+	change, err = h.service.Create(change)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
+
+func (h *PriceChangeHandler) HandlePriceChangeByID(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetByID(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *PriceChangeHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	changeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid price change ID", http.StatusBadRequest)
+		return
+	}
+
+	change, err := h.service.GetByID(changeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
+
+func (h *PriceChangeHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	changeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid price change ID", http.StatusBadRequest)
+		return
+	}
+
+	change, err := h.service.Cancel(changeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
+
+func (h *PriceChangeHandler) HandleProductPriceHistory(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetHistory(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GetHistory lists a product's price changes. The optional date, a
+// YYYY-MM-DD business date, narrows them to the prices in force that day.
+func (h *PriceChangeHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	var date *time.Time
+	if value := r.URL.Query().Get("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "date must be a date in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+		date = &parsed
+	}
+
+	history, err := h.service.GetHistory(productID, date)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
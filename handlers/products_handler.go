@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"go-kasir-api/exports"
+	"go-kasir-api/images"
 	"go-kasir-api/models"
 	"go-kasir-api/services"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -30,7 +34,27 @@ func (h *ProductHandler) HandleProducts(w http.ResponseWriter, r *http.Request)
 
 func (h *ProductHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
-	products, err := h.service.GetAll(name)
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		columns := []string{"id", "parent_id", "name", "sku", "barcode", "category_id", "category_name", "price", "cost_price", "stock", "reorder_point", "target_stock", "unit"}
+		writeExport(w, format, "products", columns, func(out exports.Writer) error {
+			return h.service.EachProduct(name, categoryID, func(p models.Product) error {
+				return out.WriteRow(p.ID, p.ParentID, p.Name, p.SKU, p.Barcode, p.CategoryID, p.Category.Name, p.Price, p.CostPrice, p.Stock, p.ReorderPoint, p.TargetStock, p.Unit)
+			})
+		})
+		return
+	}
+
+	products, err := h.service.GetAll(name, categoryID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -40,6 +64,47 @@ func (h *ProductHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(products)
 }
 
+// maxSearchResults caps the limit of a product search.
+const maxSearchResults = 100
+
+func (h *ProductHandler) HandleProductSearch(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.Search(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Search finds products by name, SKU, barcode or category, tolerating typos
+// and matching the start of words as the user types.
+func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit, err := queryInt(r, "limit", 20)
+	if err != nil || limit <= 0 || limit > maxSearchResults {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.service.Search(text, categoryID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
 func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var product models.Product
 	err := json.NewDecoder(r.Body).Decode(&product)
@@ -48,7 +113,7 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product, err = h.service.Create(product)
+	product, err = h.service.Create(product, changedBy(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -108,7 +173,7 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product, err = h.service.Update(productId, product)
+	product, err = h.service.Update(productId, product, changedBy(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -138,3 +203,239 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Product deleted"})
 }
+
+func (h *ProductHandler) HandleProductStockMovements(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetStockMovements(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ProductHandler) GetStockMovements(w http.ResponseWriter, r *http.Request) {
+	productId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	movements, err := h.service.GetStockMovements(productId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(movements)
+}
+
+func (h *ProductHandler) HandleProductVariants(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetVariants(w, r)
+	case http.MethodPost:
+		h.CreateVariant(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ProductHandler) GetVariants(w http.ResponseWriter, r *http.Request) {
+	productId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	variants, err := h.service.GetVariants(productId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variants)
+}
+
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	productId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var variant models.Product
+	err = json.NewDecoder(r.Body).Decode(&variant)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	variant, err = h.service.CreateVariant(productId, variant, changedBy(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variant)
+}
+
+// maxImageSize caps the size of an uploaded product image.
+const maxImageSize = 5 << 20
+
+func (h *ProductHandler) HandleProductImage(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		h.UploadImage(w, r)
+	case http.MethodDelete:
+		h.DeleteImage(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// UploadImage takes the picture from the "image" field of a multipart form.
+// Its type is sniffed from its content; the name and declared type of the
+// upload are ignored.
+func (h *ProductHandler) UploadImage(w http.ResponseWriter, r *http.Request) {
+	productId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	// The form around the file needs a little room of its own.
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageSize+64<<10)
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Image is larger than 5 MB", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Missing image file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxImageSize {
+		http.Error(w, "Image is larger than 5 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+	image, err := images.Decode(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.service.SetImage(productId, image)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+func (h *ProductHandler) DeleteImage(w http.ResponseWriter, r *http.Request) {
+	productId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RemoveImage(productId); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Product image deleted"})
+}
+
+// maxImportSize caps the size of an uploaded import file.
+const maxImportSize = 20 << 20
+
+func (h *ProductHandler) HandleProductImport(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.Import(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Import accepts the file either as the "file" field of a multipart form or as
+// the raw request body.
+func (h *ProductHandler) Import(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid dry_run", http.StatusBadRequest)
+			return
+		}
+	}
+	chunkSize, err := queryInt(r, "chunk_size", 0)
+	if err != nil {
+		http.Error(w, "Invalid chunk_size", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	body := io.Reader(r.Body)
+	filename := ""
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Missing import file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+		filename = fileHeader.Filename
+		contentType = fileHeader.Header.Get("Content-Type")
+	}
+
+	format, err := importFormat(r, filename, contentType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reader, err := exports.NewReader(format, body)
+	if err != nil {
+		http.Error(w, "Unreadable import file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer reader.Close()
+
+	summary, err := h.service.Import(reader, dryRun, chunkSize, changedBy(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !dryRun && chunkSize == 0 && summary.Failed > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(summary)
+}
+
+// changedBy is who made a request, as named by the X-Changed-By header, for
+// the price history.
+func changedBy(r *http.Request) string {
+	return r.Header.Get("X-Changed-By")
+}
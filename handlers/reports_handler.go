@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"go-kasir-api/exports"
+	"go-kasir-api/models"
+	"go-kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ReportHandler struct {
+	service *services.ReportService
+}
+
+func NewReportHandler(service *services.ReportService) *ReportHandler {
+	return &ReportHandler{service: service}
+}
+
+func (h *ReportHandler) HandleProfit(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetProfit(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ReportHandler) GetProfit(w http.ResponseWriter, r *http.Request) {
+	start, end, err := queryDateRange(r, "start_date", "end_date", true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "product"
+	}
+	if err := h.service.CheckProfitGroupBy(groupBy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		columns := []string{"group_id", "group_name", "quantity_sold", "revenue", "cogs", "gross_profit", "margin_percent"}
+		writeExport(w, format, "profit", columns, func(out exports.Writer) error {
+			return h.service.EachProfitLine(start, end, outletID, categoryID, groupBy, func(l models.ProfitReportLine) error {
+				return out.WriteRow(l.GroupID, l.GroupName, l.QuantitySold, l.Revenue, l.COGS, l.GrossProfit, l.MarginPercent)
+			})
+		})
+		return
+	}
+
+	report, err := h.service.GetProfit(start, end, outletID, categoryID, groupBy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *ReportHandler) HandleSalesSeries(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetSalesSeries(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ReportHandler) GetSalesSeries(w http.ResponseWriter, r *http.Request) {
+	start, end, err := queryDateRange(r, "start", "end", true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+	if err := h.service.CheckSalesSeries(start, end, interval); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		columns := []string{"bucket_start", "revenue", "transaction_count", "items_sold", "average_basket_value", "average_basket_size"}
+		writeExport(w, format, "sales-series", columns, func(out exports.Writer) error {
+			return h.service.EachSalesSeriesPoint(start, end, outletID, categoryID, interval, func(p models.SalesSeriesPoint) error {
+				return out.WriteRow(p.BucketStart, p.Revenue, p.TransactionCount, p.ItemsSold, p.AverageBasketValue, p.AverageBasketSize)
+			})
+		})
+		return
+	}
+
+	series, err := h.service.GetSalesSeries(start, end, outletID, categoryID, interval)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
+func (h *ReportHandler) HandleTopProducts(w http.ResponseWriter, r *http.Request) {
+	h.handleBreakdown(w, r, productLevel(r), "quantity", false, 10)
+}
+
+func (h *ReportHandler) HandleBottomProducts(w http.ResponseWriter, r *http.Request) {
+	h.handleBreakdown(w, r, productLevel(r), "quantity", true, 10)
+}
+
+// productLevel ranks variants separately with level=variant. By default they
+// are rolled up into their product.
+func productLevel(r *http.Request) string {
+	if r.URL.Query().Get("level") == "variant" {
+		return "variant"
+	}
+	return "product"
+}
+
+func (h *ReportHandler) HandleCategoryBreakdown(w http.ResponseWriter, r *http.Request) {
+	h.handleBreakdown(w, r, "category", "revenue", false, 0)
+}
+
+func (h *ReportHandler) HandleCashierBreakdown(w http.ResponseWriter, r *http.Request) {
+	h.handleBreakdown(w, r, "cashier", "revenue", false, 0)
+}
+
+func (h *ReportHandler) HandleOutletBreakdown(w http.ResponseWriter, r *http.Request) {
+	h.handleBreakdown(w, r, "outlet", "revenue", false, 0)
+}
+
+// queryDateRange reads a pair of YYYY-MM-DD business dates. Unless required,
+// both may be left out, which returns zero times meaning today.
+func queryDateRange(r *http.Request, startName string, endName string, required bool) (time.Time, time.Time, error) {
+	startValue := r.URL.Query().Get(startName)
+	endValue := r.URL.Query().Get(endName)
+	if startValue == "" && endValue == "" && !required {
+		return time.Time{}, time.Time{}, nil
+	}
+	if startValue == "" || endValue == "" {
+		return time.Time{}, time.Time{}, errors.New(startName + " and " + endName + " are required")
+	}
+
+	start, err := time.Parse("2006-01-02", startValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New(startName + " must be a date in YYYY-MM-DD format")
+	}
+	end, err := time.Parse("2006-01-02", endValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New(endName + " must be a date in YYYY-MM-DD format")
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, errors.New(endName + " must not be before " + startName)
+	}
+	return start, end, nil
+}
+
+// queryOutletID reads the optional outlet_id filter. Nil means all outlets.
+func queryOutletID(r *http.Request) (*int, error) {
+	value := r.URL.Query().Get("outlet_id")
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errors.New("Invalid outlet_id")
+	}
+	return &id, nil
+}
+
+// queryCategoryID reads the optional category_id filter, which takes in the
+// category and all of its subcategories. Nil means every category.
+func queryCategoryID(r *http.Request) (*int, error) {
+	value := r.URL.Query().Get("category_id")
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errors.New("Invalid category_id")
+	}
+	return &id, nil
+}
+
+// handleBreakdown serves the ranked sales reports. start_date and end_date
+// default to today; rank_by and limit default per report. outlet_id limits the
+// ranking to the sales of one outlet, category_id to products in a category
+// and its subcategories.
+func (h *ReportHandler) handleBreakdown(w http.ResponseWriter, r *http.Request, dimension string, rankBy string, ascending bool, limit int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start, end, err := queryDateRange(r, "start_date", "end_date", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if value := r.URL.Query().Get("rank_by"); value != "" {
+		rankBy = value
+	}
+	if err := h.service.CheckRankBy(rankBy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err = queryInt(r, "limit", limit)
+	if err != nil || limit < 0 {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		columns := []string{"rank", "id", "name", "quantity_sold", "revenue", "transaction_count", "revenue_share"}
+		name := strings.TrimPrefix(r.URL.Path, "/api/reports/")
+		writeExport(w, format, name, columns, func(out exports.Writer) error {
+			return h.service.EachSalesBreakdownLine(start, end, outletID, categoryID, dimension, rankBy, ascending, limit, func(l models.SalesBreakdownLine) error {
+				return out.WriteRow(l.Rank, l.ID, l.Name, l.QuantitySold, l.Revenue, l.TransactionCount, l.RevenueShare)
+			})
+		})
+		return
+	}
+
+	breakdown, err := h.service.GetSalesBreakdown(start, end, outletID, categoryID, dimension, rankBy, ascending, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(breakdown)
+}
+
+func (h *ReportHandler) HandleDeadStock(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetDeadStock(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ReportHandler) GetDeadStock(w http.ResponseWriter, r *http.Request) {
+	days, err := queryInt(r, "days", 90)
+	if err != nil || days <= 0 {
+		http.Error(w, "Invalid days", http.StatusBadRequest)
+		return
+	}
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		columns := []string{"product_id", "product_name", "category_id", "stock", "stock_value", "last_sold_at", "days_since_last_sale"}
+		writeExport(w, format, "dead-stock", columns, func(out exports.Writer) error {
+			return h.service.EachDeadStockItem(days, outletID, categoryID, func(i models.DeadStockItem) error {
+				return out.WriteRow(i.ProductID, i.ProductName, i.CategoryID, i.Stock, i.StockValue, i.LastSoldAt, i.DaysSinceLastSale)
+			})
+		})
+		return
+	}
+
+	report, err := h.service.GetDeadStock(days, outletID, categoryID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
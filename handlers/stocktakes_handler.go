@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-kasir-api/exports"
+	"go-kasir-api/models"
+	"go-kasir-api/services"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type StocktakeHandler struct {
+	service *services.StocktakeService
+}
+
+func NewStocktakeHandler(service *services.StocktakeService) *StocktakeHandler {
+	return &StocktakeHandler{service: service}
+}
+
+func (h *StocktakeHandler) HandleStocktakes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	case http.MethodPost:
+		h.Open(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StocktakeHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	stocktakes, err := h.service.GetAll(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktakes)
+}
+
+func (h *StocktakeHandler) Open(w http.ResponseWriter, r *http.Request) {
+	var stocktake models.Stocktake
+	err := json.NewDecoder(r.Body).Decode(&stocktake)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opened, err := h.service.Open(stocktake)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(opened)
+}
+
+func (h *StocktakeHandler) HandleStocktakeByID(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetByID(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StocktakeHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/stocktakes/")
+	stocktakeID, err := strconv.Atoi(id)
+
+	if err != nil {
+		http.Error(w, "Invalid stocktake ID", http.StatusBadRequest)
+		return
+	}
+
+	stocktake, err := h.service.GetByID(stocktakeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktake)
+}
+
+func (h *StocktakeHandler) HandleCounts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.SubmitCounts(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StocktakeHandler) SubmitCounts(w http.ResponseWriter, r *http.Request) {
+	stocktakeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid stocktake ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.StocktakeCountRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stocktake, err := h.service.SubmitCounts(stocktakeID, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktake)
+}
+
+func (h *StocktakeHandler) HandleVariance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetVariance(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StocktakeHandler) GetVariance(w http.ResponseWriter, r *http.Request) {
+	stocktakeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid stocktake ID", http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		columns := []string{"product_id", "product_name", "category_id", "snapshot_stock", "expected_stock", "counted_quantity", "variance", "unit_cost", "variance_value"}
+		writeExport(w, format, "stocktake-"+strconv.Itoa(stocktakeID)+"-variance", columns, func(out exports.Writer) error {
+			return h.service.EachVarianceLine(stocktakeID, func(l models.StocktakeVarianceLine) error {
+				return out.WriteRow(l.ProductID, l.ProductName, l.CategoryID, l.SnapshotStock, l.ExpectedStock, l.CountedQuantity, l.Variance, l.UnitCost, l.VarianceValue)
+			})
+		})
+		return
+	}
+
+	variance, err := h.service.GetVariance(stocktakeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variance)
+}
+
+func (h *StocktakeHandler) HandleFinalize(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.Finalize(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StocktakeHandler) Finalize(w http.ResponseWriter, r *http.Request) {
+	stocktakeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid stocktake ID", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional; an empty one finalizes with the defaults.
+	var req models.FinalizeStocktakeRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	variance, err := h.service.Finalize(stocktakeID, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variance)
+}
+
+func (h *StocktakeHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.Cancel(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StocktakeHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	stocktakeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid stocktake ID", http.StatusBadRequest)
+		return
+	}
+
+	stocktake, err := h.service.Cancel(stocktakeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocktake)
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
+	"go-kasir-api/exports"
 	"go-kasir-api/models"
 	"go-kasir-api/services"
 )
@@ -18,6 +21,8 @@ func NewTransactionHandler(service *services.TransactionService) *TransactionHan
 
 func (h *TransactionHandler) HandleTransactions(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
 	case http.MethodPost:
 		h.Create(w, r)
 	default:
@@ -25,6 +30,54 @@ func (h *TransactionHandler) HandleTransactions(w http.ResponseWriter, r *http.R
 	}
 }
 
+// GetAll lists the transactions of a date range. Exports have one row per
+// transaction line.
+func (h *TransactionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	start, end, err := queryDateRange(r, "start_date", "end_date", true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		columns := []string{"transaction_id", "created_at", "outlet_id", "customer_id", "cashier_id", "shift_id", "payment_method", "total_amount", "discount_amount",
+			"points_redeemed", "points_payment", "points_earned", "price_list_id", "detail_id", "product_id", "product_name", "quantity", "subtotal", "unit_cost", "bundle_id", "bundle_quantity"}
+		writeExport(w, format, "transactions", columns, func(out exports.Writer) error {
+			return h.service.EachTransactionLine(start, end, outletID, categoryID, func(t models.Transaction, d models.TransactionDetail) error {
+				return out.WriteRow(t.ID, t.CreatedAt, t.OutletID, t.CustomerID, t.CashierID, t.ShiftID, t.PaymentMethod, t.TotalAmount, t.DiscountAmount,
+					t.PointsRedeemed, t.PointsPayment, t.PointsEarned, t.PriceListID, d.ID, d.ProductID, d.ProductName, d.Quantity, d.Subtotal, d.UnitCost,
+					d.BundleID, d.BundleQuantity)
+			})
+		})
+		return
+	}
+
+	transactions, err := h.service.GetTransactions(start, end, outletID, categoryID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
+
+// Create rings up a sale. A till can send its outlet in the X-Outlet-ID
+// header instead of in the body.
 func (h *TransactionHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req models.CheckoutRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -32,13 +85,56 @@ func (h *TransactionHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if value := r.Header.Get("X-Outlet-ID"); value != "" {
+		outletID, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid X-Outlet-ID", http.StatusBadRequest)
+			return
+		}
+		if req.OutletID != nil && *req.OutletID != outletID {
+			http.Error(w, "outlet_id does not match X-Outlet-ID", http.StatusBadRequest)
+			return
+		}
+		req.OutletID = &outletID
+	}
 
-	transaction, err := h.service.Create(req.Items)
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		transaction, err := h.service.Create(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(transaction)
+		return
+	}
+
+	if len(key) > 255 {
+		http.Error(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	transaction, stored, err := h.service.CreateIdempotent(key, req)
+	if errors.Is(err, services.ErrIdempotencyKeyReused) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if stored != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(stored.StatusCode)
+		w.Write(stored.ResponseBody)
+		return
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(transaction)
@@ -54,14 +150,37 @@ func (h *TransactionHandler) HandleTransactionReport(w http.ResponseWriter, r *h
 }
 
 func (h *TransactionHandler) GetReport(w http.ResponseWriter, r *http.Request) {
-	start := r.URL.Query().Get("start_date")
-	end := r.URL.Query().Get("end_date")
+	start, end, err := queryDateRange(r, "start_date", "end_date", true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	report, err := h.service.GetTransactionReport(start, end)
+	report, err := h.service.GetTransactionReport(start, end, outletID, categoryID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if format != "" {
+		writeTransactionReport(w, format, "sales-report", report)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(report)
@@ -77,13 +196,42 @@ func (h *TransactionHandler) HandleTransactionReportToday(w http.ResponseWriter,
 }
 
 func (h *TransactionHandler) GetReportToday(w http.ResponseWriter, r *http.Request) {
-	report, err := h.service.GetTransactionReportToday()
+	outletID, err := queryOutletID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categoryID, err := queryCategoryID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.GetTransactionReportToday(outletID, categoryID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if format != "" {
+		writeTransactionReport(w, format, "sales-report-today", report)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(report)
 }
 
+// writeTransactionReport exports a sales summary as a single row.
+func writeTransactionReport(w http.ResponseWriter, format string, name string, report *models.TransactionReport) {
+	columns := []string{"total_revenue", "total_transaction", "total_cogs", "gross_profit", "margin_percent",
+		"best_selling_product", "best_selling_quantity"}
+	writeExport(w, format, name, columns, func(out exports.Writer) error {
+		return out.WriteRow(report.TotalRevenue, report.TotalTransaction, report.TotalCOGS, report.GrossProfit, report.MarginPercent,
+			report.BestSellingProduct.ProductName, report.BestSellingProduct.QuantitySold)
+	})
+}
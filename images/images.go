@@ -0,0 +1,128 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	// Registered for image.Decode.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxPixels caps the dimensions of an uploaded image. A small file can
+// declare a huge canvas, and decoding it would allocate width × height pixels.
+const MaxPixels = 40_000_000
+
+// extensions maps the content types that are accepted to their file
+// extension.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image is an uploaded picture that has been checked and decoded.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	decoded     image.Image
+}
+
+// Decode checks and decodes an uploaded image. The type is sniffed from the
+// data itself rather than trusted from the upload.
+func Decode(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	extension, ok := extensions[contentType]
+	if !ok {
+		return nil, errors.New("image must be a JPEG, PNG or GIF")
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unreadable image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, errors.New("image has no pixels")
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("image is %d×%d pixels, more than %d in total", config.Width, config.Height, MaxPixels)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unreadable image: %w", err)
+	}
+	return &Image{
+		Data:        data,
+		ContentType: contentType,
+		Extension:   extension,
+		Width:       config.Width,
+		Height:      config.Height,
+		decoded:     decoded,
+	}, nil
+}
+
+// Thumbnail scales the image down to fit in a size × size square, keeping its
+// aspect ratio, and encodes it as a JPEG. Transparent areas become white.
+// Images that already fit are not enlarged.
+func (img *Image) Thumbnail(size int) ([]byte, error) {
+	width, height := fit(img.Width, img.Height, size)
+	bounds := img.decoded.Bounds()
+
+	// Paint onto white first so transparency does not turn black in the JPEG.
+	source := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(source, source.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(source, source.Bounds(), img.decoded, bounds.Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, shrink(source, width, height), &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fit returns the dimensions of a width × height image scaled down to fit in
+// a size × size square. Neither side drops below one pixel.
+func fit(width int, height int, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
+
+// shrink scales src down to width × height by averaging the source pixels
+// that fall in each target pixel.
+func shrink(src *image.RGBA, width int, height int) *image.RGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, max((y+1)*srcHeight/height, y*srcHeight/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, max((x+1)*srcWidth/width, x*srcWidth/width+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, b, a = r+int(p[0]), g+int(p[1]), b+int(p[2]), a+int(p[3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
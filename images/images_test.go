@@ -0,0 +1,77 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	img, err := Decode(encodePNG(t, 40, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.Extension != ".png" || img.Width != 40 || img.Height != 30 {
+		t.Errorf("got %s %s %d×%d, want image/png .png 40×30", img.ContentType, img.Extension, img.Width, img.Height)
+	}
+
+	if _, err := Decode([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>")); err == nil {
+		t.Error("an SVG was accepted")
+	}
+	if _, err := Decode(encodePNG(t, 40, 30)[:60]); err == nil {
+		t.Error("a truncated PNG was accepted")
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	img, err := Decode(encodePNG(t, 400, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := img.Thumbnail(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := thumb.Bounds().Size(); size.X != 200 || size.Y != 50 {
+		t.Errorf("thumbnail is %d×%d, want 200×50", size.X, size.Y)
+	}
+	// The source is fully transparent, which must come out white.
+	if r, g, b, _ := thumb.At(100, 25).RGBA(); r < 0xf000 || g < 0xf000 || b < 0xf000 {
+		t.Errorf("transparent pixel became %x %x %x, want white", r, g, b)
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		width, height, size int
+		wantW, wantH        int
+	}{
+		{100, 80, 256, 100, 80},
+		{1024, 768, 256, 256, 192},
+		{768, 1024, 256, 192, 256},
+		{5000, 10, 256, 256, 1},
+		{512, 512, 256, 256, 256},
+	}
+	for _, tt := range tests {
+		w, h := fit(tt.width, tt.height, tt.size)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("fit(%d, %d, %d) = %d, %d, want %d, %d", tt.width, tt.height, tt.size, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
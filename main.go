@@ -5,18 +5,33 @@ import (
 	"fmt"
 	"go-kasir-api/database"
 	"go-kasir-api/handlers"
+	"go-kasir-api/models"
+	"go-kasir-api/notifiers"
 	"go-kasir-api/repositories"
 	"go-kasir-api/services"
+	"go-kasir-api/storage"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Port   string `mapstructure:"PORT"`
-	DBConn string `mapstructure:"DB_CONN"`
+	Port           string        `mapstructure:"PORT"`
+	DBConn         string        `mapstructure:"DB_CONN"`
+	IdempotencyTTL time.Duration `mapstructure:"IDEMPOTENCY_KEY_TTL"`
+	LowStockHook   string        `mapstructure:"LOW_STOCK_WEBHOOK_URL"`
+	StoreTimezone  string        `mapstructure:"STORE_TIMEZONE"`
+	DayCutoffHour  int           `mapstructure:"BUSINESS_DAY_CUTOFF_HOUR"`
+	UploadDir      string        `mapstructure:"UPLOAD_DIR"`
+	UploadBaseURL  string        `mapstructure:"UPLOAD_BASE_URL"`
+	Loyalty        models.LoyaltyConfig
+	Checkout       models.CheckoutConfig
 }
 
 func maskConnectionString(conn string) string {
@@ -26,8 +41,34 @@ func maskConnectionString(conn string) string {
 	return "***"
 }
 
+// parseIntList reads a comma separated list such as "3,7" and skips anything
+// that is not a number.
+func parseIntList(value string) []int {
+	var list []int
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err == nil {
+			list = append(list, n)
+		}
+	}
+	return list
+}
+
 func main() {
 	viper.AutomaticEnv()
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", "24h")
+	viper.SetDefault("LOYALTY_EARN_AMOUNT", 10000)
+	viper.SetDefault("LOYALTY_EARN_POINTS", 1)
+	viper.SetDefault("LOYALTY_POINT_VALUE", 1)
+	viper.SetDefault("LOYALTY_EXPIRY_DAYS", 365)
+	viper.SetDefault("STORE_TIMEZONE", "Asia/Jakarta")
+	viper.SetDefault("BUSINESS_DAY_CUTOFF_HOUR", 0)
+	viper.SetDefault("MONEY_ROUNDING", 1)
+	viper.SetDefault("SCALE_BARCODE_PREFIX", "20")
+	viper.SetDefault("UPLOAD_DIR", "uploads")
+	viper.SetDefault("UPLOAD_BASE_URL", "/uploads")
+	viper.SetDefault("SCALE_BARCODE_ITEM_DIGITS", 5)
+	viper.SetDefault("SCALE_BARCODE_EMBEDDED", models.ScaleEmbedsWeight)
 
 	if _, err := os.Stat(".env"); err == nil {
 		viper.SetConfigFile(".env")
@@ -35,8 +76,36 @@ func main() {
 	}
 
 	config := Config{
-		Port:   viper.GetString("PORT"),
-		DBConn: viper.GetString("DB_CONN"),
+		Port:           viper.GetString("PORT"),
+		DBConn:         viper.GetString("DB_CONN"),
+		IdempotencyTTL: viper.GetDuration("IDEMPOTENCY_KEY_TTL"),
+		LowStockHook:   viper.GetString("LOW_STOCK_WEBHOOK_URL"),
+		StoreTimezone:  viper.GetString("STORE_TIMEZONE"),
+		DayCutoffHour:  viper.GetInt("BUSINESS_DAY_CUTOFF_HOUR"),
+		UploadDir:      viper.GetString("UPLOAD_DIR"),
+		UploadBaseURL:  viper.GetString("UPLOAD_BASE_URL"),
+		Loyalty: models.LoyaltyConfig{
+			EarnAmount:          viper.GetInt("LOYALTY_EARN_AMOUNT"),
+			EarnPoints:          viper.GetInt("LOYALTY_EARN_POINTS"),
+			ExcludedCategoryIDs: parseIntList(viper.GetString("LOYALTY_EXCLUDED_CATEGORIES")),
+			PointValue:          viper.GetInt("LOYALTY_POINT_VALUE"),
+			ExpiryDays:          viper.GetInt("LOYALTY_EXPIRY_DAYS"),
+		},
+		Checkout: models.CheckoutConfig{
+			MoneyRounding: viper.GetInt("MONEY_ROUNDING"),
+			ScaleBarcode: models.ScaleBarcodeConfig{
+				Prefix:     viper.GetString("SCALE_BARCODE_PREFIX"),
+				ItemDigits: viper.GetInt("SCALE_BARCODE_ITEM_DIGITS"),
+				Embedded:   viper.GetString("SCALE_BARCODE_EMBEDDED"),
+			},
+			AllowSalesWithoutShift: viper.GetBool("ALLOW_SALES_WITHOUT_SHIFT"),
+		},
+	}
+	if config.Checkout.MoneyRounding < 1 {
+		log.Fatal("MONEY_ROUNDING must be at least 1")
+	}
+	if embedded := config.Checkout.ScaleBarcode.Embedded; embedded != models.ScaleEmbedsPrice && embedded != models.ScaleEmbedsWeight {
+		log.Fatal("SCALE_BARCODE_EMBEDDED must be price or weight")
 	}
 
 	log.Printf("Configuration loaded - Port: %s, DB_CONN: %s", config.Port, maskConnectionString(config.DBConn))
@@ -55,21 +124,177 @@ func main() {
 	})
 
 	productRepository := repositories.NewProductRepository(db)
-	productService := services.NewProductService(productRepository)
+	stockMovementRepository := repositories.NewStockMovementRepository(db)
+	imageStore, err := storage.NewLocalStore(config.UploadDir, config.UploadBaseURL)
+	if err != nil {
+		log.Fatal("Failed to open upload directory:", err)
+	}
+	productService := services.NewProductService(productRepository, stockMovementRepository, imageStore)
 	productHandler := handlers.NewProductHandler(productService)
 
 	categoryRepository := repositories.NewCategoryRepository(db)
 	categoryService := services.NewCategoryService(categoryRepository)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
 
-	transactionRepository := repositories.NewTransactionRepository(db)
-	transactionService := services.NewTransactionService(productRepository, transactionRepository)
+	unitRepository := repositories.NewUnitRepository(db)
+	unitService := services.NewUnitService(unitRepository)
+	unitHandler := handlers.NewUnitHandler(unitService)
+
+	var lowStockNotifier repositories.LowStockNotifier = notifiers.NewLogNotifier()
+	if config.LowStockHook != "" {
+		lowStockNotifier = notifiers.NewWebhookNotifier(config.LowStockHook)
+	}
+
+	calendar, err := repositories.NewBusinessCalendar(config.StoreTimezone, config.DayCutoffHour)
+	if err != nil {
+		log.Fatal("Failed to load business calendar:", err)
+	}
+	reportRepository := repositories.NewReportRepository(db, calendar)
+
+	loyaltyRepository := repositories.NewLoyaltyRepository(db, config.Loyalty)
+	transactionRepository := repositories.NewTransactionRepository(db, loyaltyRepository, lowStockNotifier, config.Checkout, calendar)
+	idempotencyRepository := repositories.NewIdempotencyRepository(db)
+	transactionService := services.NewTransactionService(productRepository, transactionRepository, idempotencyRepository, reportRepository, config.IdempotencyTTL)
 	transactionHandler := handlers.NewTransactionHandler(transactionService)
 
+	customerRepository := repositories.NewCustomerRepository(db)
+	customerService := services.NewCustomerService(customerRepository, transactionRepository, loyaltyRepository)
+	customerHandler := handlers.NewCustomerHandler(customerService)
+
+	cashierRepository := repositories.NewCashierRepository(db)
+	cashierService := services.NewCashierService(cashierRepository)
+	cashierHandler := handlers.NewCashierHandler(cashierService)
+
+	shiftRepository := repositories.NewShiftRepository(db)
+	shiftService := services.NewShiftService(shiftRepository)
+	shiftHandler := handlers.NewShiftHandler(shiftService)
+
+	outletRepository := repositories.NewOutletRepository(db)
+	outletService := services.NewOutletService(outletRepository)
+	outletHandler := handlers.NewOutletHandler(outletService)
+
+	supplierRepository := repositories.NewSupplierRepository(db)
+	supplierService := services.NewSupplierService(supplierRepository)
+	supplierHandler := handlers.NewSupplierHandler(supplierService)
+
+	purchaseOrderRepository := repositories.NewPurchaseOrderRepository(db)
+	purchaseOrderService := services.NewPurchaseOrderService(purchaseOrderRepository)
+	purchaseOrderHandler := handlers.NewPurchaseOrderHandler(purchaseOrderService)
+
+	stockTransferRepository := repositories.NewStockTransferRepository(db)
+	stockTransferService := services.NewStockTransferService(stockTransferRepository)
+	stockTransferHandler := handlers.NewStockTransferHandler(stockTransferService)
+
+	stocktakeRepository := repositories.NewStocktakeRepository(db)
+	stocktakeService := services.NewStocktakeService(stocktakeRepository)
+	stocktakeHandler := handlers.NewStocktakeHandler(stocktakeService)
+
+	inventoryRepository := repositories.NewInventoryRepository(db, calendar)
+	inventoryService := services.NewInventoryService(inventoryRepository)
+	inventoryHandler := handlers.NewInventoryHandler(inventoryService)
+
+	priceListRepository := repositories.NewPriceListRepository(db)
+	priceListService := services.NewPriceListService(priceListRepository)
+	priceListHandler := handlers.NewPriceListHandler(priceListService)
+
+	priceChangeRepository := repositories.NewPriceChangeRepository(db, calendar)
+	priceChangeService := services.NewPriceChangeService(priceChangeRepository)
+	priceChangeHandler := handlers.NewPriceChangeHandler(priceChangeService)
+
+	reportService := services.NewReportService(reportRepository)
+	reportHandler := handlers.NewReportHandler(reportService)
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := transactionService.PurgeExpiredIdempotencyKeys(); err != nil {
+				log.Println("Failed to purge expired idempotency keys:", err)
+			}
+		}
+	}()
+
+	// Scheduled price changes are applied within a minute of falling due. A
+	// sale of the product in between applies its change itself.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			applied, err := priceChangeService.ApplyDue()
+			if err != nil {
+				log.Println("Failed to apply scheduled price changes:", err)
+				continue
+			}
+			if applied > 0 {
+				log.Printf("Applied %d scheduled price changes", applied)
+			}
+		}
+	}()
+
 	http.HandleFunc("/api/products", productHandler.HandleProducts)
+	http.HandleFunc("/api/products/import", productHandler.HandleProductImport)
+	http.HandleFunc("/api/products/search", productHandler.HandleProductSearch)
 	http.HandleFunc("/api/products/{id}", productHandler.HandleProductByID)
+	http.HandleFunc("/api/products/{id}/variants", productHandler.HandleProductVariants)
+	http.HandleFunc("/api/products/{id}/stock-movements", productHandler.HandleProductStockMovements)
+	http.HandleFunc("/api/products/{id}/batches", inventoryHandler.HandleProductBatches)
+	http.HandleFunc("/api/products/{id}/price-history", priceChangeHandler.HandleProductPriceHistory)
+	http.HandleFunc("/api/products/{id}/image", productHandler.HandleProductImage)
+	http.Handle("/uploads/", http.StripPrefix("/uploads", imageStore.Handler()))
+	http.HandleFunc("/api/units", unitHandler.HandleUnits)
+	http.HandleFunc("/api/units/{code}", unitHandler.HandleUnitByCode)
 	http.HandleFunc("/api/categories", categoryHandler.HandleCategories)
+	http.HandleFunc("/api/categories/tree", categoryHandler.HandleCategoryTree)
 	http.HandleFunc("/api/categories/{id}", categoryHandler.HandleCategoryByID)
+	http.HandleFunc("/api/price-changes", priceChangeHandler.HandlePriceChanges)
+	http.HandleFunc("/api/price-changes/{id}", priceChangeHandler.HandlePriceChangeByID)
+	http.HandleFunc("/api/price-changes/{id}/cancel", priceChangeHandler.HandleCancel)
+	http.HandleFunc("/api/price-lists", priceListHandler.HandlePriceLists)
+	http.HandleFunc("/api/price-lists/{id}", priceListHandler.HandlePriceListByID)
+	http.HandleFunc("/api/customers", customerHandler.HandleCustomers)
+	http.HandleFunc("/api/customers/{id}", customerHandler.HandleCustomerByID)
+	http.HandleFunc("/api/customers/{id}/transactions", customerHandler.HandleCustomerTransactions)
+	http.HandleFunc("/api/customers/{id}/stats", customerHandler.HandleCustomerStats)
+	http.HandleFunc("/api/customers/{id}/loyalty", customerHandler.HandleCustomerLoyalty)
+	http.HandleFunc("/api/cashiers", cashierHandler.HandleCashiers)
+	http.HandleFunc("/api/cashiers/{id}", cashierHandler.HandleCashierByID)
+	http.HandleFunc("/api/shifts", shiftHandler.HandleShifts)
+	http.HandleFunc("/api/shifts/{id}", shiftHandler.HandleShiftByID)
+	http.HandleFunc("/api/shifts/{id}/cash-movements", shiftHandler.HandleCashMovements)
+	http.HandleFunc("/api/shifts/{id}/report", shiftHandler.HandleReport)
+	http.HandleFunc("/api/shifts/{id}/close", shiftHandler.HandleClose)
+	http.HandleFunc("/api/outlets", outletHandler.HandleOutlets)
+	http.HandleFunc("/api/outlets/{id}", outletHandler.HandleOutletByID)
+	http.HandleFunc("/api/outlets/{id}/products", outletHandler.HandleOutletProducts)
+	http.HandleFunc("/api/outlets/{id}/products/{product_id}/price", outletHandler.HandleOutletPrice)
+	http.HandleFunc("/api/suppliers", supplierHandler.HandleSuppliers)
+	http.HandleFunc("/api/suppliers/{id}", supplierHandler.HandleSupplierByID)
+	http.HandleFunc("/api/purchase-orders", purchaseOrderHandler.HandlePurchaseOrders)
+	http.HandleFunc("/api/purchase-orders/{id}", purchaseOrderHandler.HandlePurchaseOrderByID)
+	http.HandleFunc("/api/purchase-orders/{id}/submit", purchaseOrderHandler.HandleSubmit)
+	http.HandleFunc("/api/purchase-orders/{id}/cancel", purchaseOrderHandler.HandleCancel)
+	http.HandleFunc("/api/purchase-orders/{id}/receive", purchaseOrderHandler.HandleReceive)
+	http.HandleFunc("/api/reports/profit", reportHandler.HandleProfit)
+	http.HandleFunc("/api/reports/sales-series", reportHandler.HandleSalesSeries)
+	http.HandleFunc("/api/reports/top-products", reportHandler.HandleTopProducts)
+	http.HandleFunc("/api/reports/bottom-products", reportHandler.HandleBottomProducts)
+	http.HandleFunc("/api/reports/categories", reportHandler.HandleCategoryBreakdown)
+	http.HandleFunc("/api/reports/cashiers", reportHandler.HandleCashierBreakdown)
+	http.HandleFunc("/api/reports/outlets", reportHandler.HandleOutletBreakdown)
+	http.HandleFunc("/api/reports/dead-stock", reportHandler.HandleDeadStock)
+	http.HandleFunc("/api/reports/purchase-orders/outstanding", purchaseOrderHandler.HandleOutstandingReport)
+	http.HandleFunc("/api/inventory/low-stock", inventoryHandler.HandleLowStock)
+	http.HandleFunc("/api/inventory/expiring", inventoryHandler.HandleExpiring)
+	http.HandleFunc("/api/stocktakes", stocktakeHandler.HandleStocktakes)
+	http.HandleFunc("/api/stocktakes/{id}", stocktakeHandler.HandleStocktakeByID)
+	http.HandleFunc("/api/stocktakes/{id}/counts", stocktakeHandler.HandleCounts)
+	http.HandleFunc("/api/stocktakes/{id}/variance", stocktakeHandler.HandleVariance)
+	http.HandleFunc("/api/stocktakes/{id}/finalize", stocktakeHandler.HandleFinalize)
+	http.HandleFunc("/api/stocktakes/{id}/cancel", stocktakeHandler.HandleCancel)
+	http.HandleFunc("/api/stock-transfers", stockTransferHandler.HandleStockTransfers)
+	http.HandleFunc("/api/stock-transfers/{id}", stockTransferHandler.HandleStockTransferByID)
+	http.HandleFunc("/api/stock-transfers/{id}/dispatch", stockTransferHandler.HandleDispatch)
+	http.HandleFunc("/api/stock-transfers/{id}/receive", stockTransferHandler.HandleReceive)
 	http.HandleFunc("/api/transactions", transactionHandler.HandleTransactions)
 	http.HandleFunc("/api/transactions/reports", transactionHandler.HandleTransactionReport)
 	http.HandleFunc("/api/transactions/reports/today", transactionHandler.HandleTransactionReportToday)
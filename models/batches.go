@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// StockBatch is a lot of a product at an outlet. Quantity is what is left of
+// the ReceivedQuantity. ExpiryDate is nil for goods that do not expire.
+type StockBatch struct {
+	ID                 int        `json:"id"`
+	ProductID          int        `json:"product_id"`
+	ProductName        string     `json:"product_name,omitempty"`
+	OutletID           int        `json:"outlet_id"`
+	LotNumber          string     `json:"lot_number"`
+	ExpiryDate         *time.Time `json:"expiry_date"`
+	Quantity           Quantity   `json:"quantity"`
+	ReceivedQuantity   Quantity   `json:"received_quantity"`
+	GoodsReceiptItemID *int       `json:"goods_receipt_item_id,omitempty"`
+	ReceivedAt         time.Time  `json:"received_at"`
+}
+
+// LotQuantity is a quantity of one lot of a product.
+type LotQuantity struct {
+	LotNumber  string     `json:"lot_number"`
+	ExpiryDate *time.Time `json:"expiry_date"`
+	Quantity   Quantity   `json:"quantity"`
+}
+
+// ExpiringBatch is a line of the expiring-soon report. DaysLeft is negative
+// once the batch has expired; Value is its quantity at the product's cost
+// price.
+type ExpiringBatch struct {
+	BatchID     int       `json:"batch_id"`
+	ProductID   int       `json:"product_id"`
+	ProductName string    `json:"product_name"`
+	OutletID    int       `json:"outlet_id"`
+	OutletName  string    `json:"outlet_name"`
+	LotNumber   string    `json:"lot_number"`
+	ExpiryDate  time.Time `json:"expiry_date"`
+	DaysLeft    int       `json:"days_left"`
+	Expired     bool      `json:"expired"`
+	Quantity    Quantity  `json:"quantity"`
+	Unit        string    `json:"unit"`
+	Value       int       `json:"value"`
+}
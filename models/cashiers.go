@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Cashier is a person who rings up sales. A cashier with an OutletID can only
+// sell at that outlet; one without can sell at any.
+type Cashier struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	OutletID  *int      `json:"outlet_id"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -1,7 +1,12 @@
 package models
 
+// Category groups products. Categories nest to any depth under ParentID; a
+// category without a parent is top-level. Children is only filled in the
+// category tree.
 type Category struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID          int        `json:"id"`
+	ParentID    *int       `json:"parent_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Children    []Category `json:"children,omitempty"`
 }
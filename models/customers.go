@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Customer is a registered buyer. Group, such as wholesale, picks the price
+// lists that apply to the customer's purchases.
+type Customer struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Phone     string    `json:"phone"`
+	Email     string    `json:"email"`
+	Address   string    `json:"address"`
+	Group     string    `json:"group"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CustomerStats struct {
+	CustomerID         int        `json:"customer_id"`
+	TotalTransactions  int        `json:"total_transactions"`
+	LifetimeValue      int        `json:"lifetime_value"`
+	AverageBasket      int        `json:"average_basket"`
+	FirstVisit         *time.Time `json:"first_visit"`
+	LastVisit          *time.Time `json:"last_visit"`
+	AverageDaysBetween float64    `json:"average_days_between_visits"`
+	VisitsPerMonth     float64    `json:"visits_per_month"`
+	DaysSinceLastVisit int        `json:"days_since_last_visit"`
+}
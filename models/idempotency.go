@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type IdempotencyKey struct {
+	Key          string    `json:"key"`
+	RequestHash  string    `json:"request_hash"`
+	StatusCode   int       `json:"status_code"`
+	ResponseBody []byte    `json:"response_body"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+
+	// TTL is how long a newly reserved key stays valid.
+	TTL time.Duration `json:"-"`
+}
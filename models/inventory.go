@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// LowStockEvent is sent to the notifier when a checkout takes a product from
+// above its reorder point to at or below it.
+type LowStockEvent struct {
+	ProductID     int       `json:"product_id"`
+	ProductName   string    `json:"product_name"`
+	Stock         Quantity  `json:"stock"`
+	ReorderPoint  Quantity  `json:"reorder_point"`
+	TargetStock   Quantity  `json:"target_stock"`
+	TransactionID int       `json:"transaction_id"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+type LowStockItem struct {
+	ProductID         int      `json:"product_id"`
+	ProductName       string   `json:"product_name"`
+	CategoryID        int      `json:"category_id"`
+	Stock             Quantity `json:"stock"`
+	ReorderPoint      Quantity `json:"reorder_point"`
+	TargetStock       Quantity `json:"target_stock"`
+	OnOrder           Quantity `json:"on_order"`
+	SoldInWindow      Quantity `json:"sold_in_window"`
+	AverageDailySales float64  `json:"average_daily_sales"`
+	SuggestedQuantity Quantity `json:"suggested_quantity"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	LoyaltyEntryEarn   = "earn"
+	LoyaltyEntryRedeem = "redeem"
+	LoyaltyEntryExpire = "expire"
+
+	RedeemAsDiscount = "discount"
+	RedeemAsPayment  = "payment"
+)
+
+// LoyaltyConfig holds the earn and redeem rules. A customer earns EarnPoints
+// for every EarnAmount spent on products outside ExcludedCategoryIDs and their
+// subcategories, and each redeemed point is worth PointValue. Earned points
+// expire after ExpiryDays.
+type LoyaltyConfig struct {
+	EarnAmount          int   `json:"earn_amount"`
+	EarnPoints          int   `json:"earn_points"`
+	ExcludedCategoryIDs []int `json:"excluded_category_ids"`
+	PointValue          int   `json:"point_value"`
+	ExpiryDays          int   `json:"expiry_days"`
+}
+
+type LoyaltyEntry struct {
+	ID              int        `json:"id"`
+	CustomerID      int        `json:"customer_id"`
+	TransactionID   *int       `json:"transaction_id"`
+	EntryType       string     `json:"entry_type"`
+	Points          int        `json:"points"`
+	RemainingPoints int        `json:"remaining_points,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type LoyaltyBalance struct {
+	CustomerID int            `json:"customer_id"`
+	Balance    int            `json:"balance"`
+	Value      int            `json:"value"`
+	History    []LoyaltyEntry `json:"history"`
+}
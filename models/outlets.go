@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Outlet is one shop. Exactly one outlet is the default; it receives the
+// stock of new products and the sales of checkouts that name no outlet.
+type Outlet struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	IsDefault bool      `json:"is_default"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutletProduct is a product as seen from one outlet: the stock on its shelves
+// and the price it sells at. PriceOverride is nil when the outlet uses the
+// product's base price.
+type OutletProduct struct {
+	ProductID     int      `json:"product_id"`
+	ProductName   string   `json:"product_name"`
+	CategoryID    int      `json:"category_id"`
+	Stock         Quantity `json:"stock"`
+	BasePrice     int      `json:"base_price"`
+	PriceOverride *int     `json:"price_override"`
+	Price         int      `json:"price"`
+}
+
+// OutletPriceRequest sets or, with a null price, clears an outlet's price
+// for a product.
+type OutletPriceRequest struct {
+	Price *int `json:"price"`
+}
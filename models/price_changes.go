@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	PriceChangeScheduled = "scheduled"
+	PriceChangeApplied   = "applied"
+	PriceChangeCancelled = "cancelled"
+
+	PriceSourceCreated   = "created"
+	PriceSourceUpdated   = "updated"
+	PriceSourceImported  = "imported"
+	PriceSourceScheduled = "scheduled"
+)
+
+// PriceChange is a new base price for a product that takes effect at
+// EffectiveAt. It is applied by the scheduler, or by the first sale of the
+// product after that time if that comes sooner.
+type PriceChange struct {
+	ID          int        `json:"id"`
+	ProductID   int        `json:"product_id"`
+	ProductName string     `json:"product_name,omitempty"`
+	Price       int        `json:"price"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	Status      string     `json:"status"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AppliedAt   *time.Time `json:"applied_at"`
+}
+
+// PriceHistoryEntry records one change of a product's base price. OldPrice is
+// nil for the price a product was created with. Source says what changed
+// it, and PriceChangeID the scheduled change that did.
+type PriceHistoryEntry struct {
+	ID            int       `json:"id"`
+	ProductID     int       `json:"product_id"`
+	OldPrice      *int      `json:"old_price"`
+	Price         int       `json:"price"`
+	Source        string    `json:"source"`
+	PriceChangeID *int      `json:"price_change_id,omitempty"`
+	ChangedBy     string    `json:"changed_by"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
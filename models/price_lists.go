@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// PriceList is a set of special prices, such as wholesale prices. It applies
+// at checkout to customers whose Group matches CustomerGroup, or when the
+// sale selects it, between ValidFrom and ValidTo inclusive. Either date may
+// be left open.
+type PriceList struct {
+	ID            int             `json:"id"`
+	Name          string          `json:"name"`
+	CustomerGroup string          `json:"customer_group"`
+	ValidFrom     *time.Time      `json:"valid_from"`
+	ValidTo       *time.Time      `json:"valid_to"`
+	Active        bool            `json:"active"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Items         []PriceListItem `json:"items"`
+}
+
+// PriceListItem is the unit Price of a product once a sale takes at least
+// MinQuantity of it, in the product's own unit. Several items of a product
+// make quantity breaks.
+type PriceListItem struct {
+	ProductID   int      `json:"product_id"`
+	ProductName string   `json:"product_name,omitempty"`
+	MinQuantity Quantity `json:"min_quantity"`
+	Price       int      `json:"price"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ProductImage is the picture shown on a product's tile, with a thumbnail
+// scaled down from it. The keys locate both files in the image store.
+type ProductImage struct {
+	ProductID    int       `json:"product_id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Size         int       `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+}
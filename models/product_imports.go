@@ -0,0 +1,37 @@
+package models
+
+// ProductImportRow is one parsed row of a product import file. Row is the line
+// number in the file. Name and Category are empty, and the numbers nil, when
+// the file leaves them out; an update then keeps the product's current value.
+type ProductImportRow struct {
+	Row          int
+	ID           *int
+	Name         string
+	Category     string
+	Price        *int
+	CostPrice    *int
+	Stock        *Quantity
+	ReorderPoint *Quantity
+	TargetStock  *Quantity
+}
+
+type ProductImportError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ProductImportSummary reports what an import did, or for a dry run what it
+// would do. Skipped rows matched a product that already had the same values.
+type ProductImportSummary struct {
+	DryRun            bool                 `json:"dry_run"`
+	ChunkSize         int                  `json:"chunk_size"`
+	Committed         bool                 `json:"committed"`
+	TotalRows         int                  `json:"total_rows"`
+	Created           int                  `json:"created"`
+	Updated           int                  `json:"updated"`
+	Skipped           int                  `json:"skipped"`
+	Failed            int                  `json:"failed"`
+	CategoriesCreated []string             `json:"categories_created"`
+	Errors            []ProductImportError `json:"errors"`
+}
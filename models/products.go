@@ -1,10 +1,59 @@
 package models
 
+// Product is something the store sells. A product with Options is a listing
+// that groups its Variants; it is not sold or stocked itself. A variant is a
+// product with a ParentID whose OptionValues pick one value of each of the
+// parent's options.
+//
+// Stock and the quantities of every document about the product are counted
+// in Unit; Units lists the other units it can be sold in.
+//
+// A product with Components is a bundle, such as a gift pack. It holds no
+// stock of its own: Stock is how many bundles its components' stock can make,
+// and selling one takes its components from stock.
+//
+// ImageURL and ThumbnailURL are empty until a picture is uploaded.
 type Product struct {
-	ID         int       `json:"id"`
-	Name       string    `json:"name"`
-	Price      int       `json:"price"`
-	Stock      int       `json:"stock"`
-	CategoryID int       `json:"category_id"`
-	Category   *Category `json:"category,omitempty"`
+	ID           int               `json:"id"`
+	ParentID     *int              `json:"parent_id"`
+	Name         string            `json:"name"`
+	SKU          string            `json:"sku"`
+	Barcode      string            `json:"barcode"`
+	Price        int               `json:"price"`
+	CostPrice    int               `json:"cost_price"`
+	Stock        Quantity          `json:"stock"`
+	ReorderPoint Quantity          `json:"reorder_point"`
+	TargetStock  Quantity          `json:"target_stock"`
+	Unit         string            `json:"unit"`
+	Units        []ProductUnit     `json:"units"`
+	CategoryID   int               `json:"category_id"`
+	Category     *Category         `json:"category,omitempty"`
+	Options      []ProductOption   `json:"options,omitempty"`
+	OptionValues map[string]string `json:"option_values,omitempty"`
+	Variants     []Product         `json:"variants,omitempty"`
+	Components   []BundleComponent `json:"components,omitempty"`
+	ImageURL     string            `json:"image_url"`
+	ThumbnailURL string            `json:"thumbnail_url"`
+}
+
+// ProductSearchResult is a product found by a search, with a Score for how
+// well it matched. Higher is better.
+type ProductSearchResult struct {
+	Product
+	Score float64 `json:"score"`
+}
+
+// BundleComponent is the Quantity of a product, in its own unit, that goes
+// into one bundle.
+type BundleComponent struct {
+	ProductID   int      `json:"product_id"`
+	ProductName string   `json:"product_name,omitempty"`
+	Quantity    Quantity `json:"quantity"`
+}
+
+// ProductOption is an attribute a listing's variants differ by, such as size
+// or color, with the values it can take.
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
 }
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderOrdered           = "ordered"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+	PurchaseOrderCancelled         = "cancelled"
+)
+
+// PurchaseOrder is an order placed with a supplier. Goods are received into
+// OutletID; an order created without one goes to the default outlet.
+type PurchaseOrder struct {
+	ID                int                 `json:"id"`
+	SupplierID        int                 `json:"supplier_id"`
+	SupplierName      string              `json:"supplier_name,omitempty"`
+	OutletID          int                 `json:"outlet_id"`
+	Status            string              `json:"status"`
+	Notes             string              `json:"notes"`
+	ExpectedDate      *time.Time          `json:"expected_date"`
+	TotalExpectedCost int                 `json:"total_expected_cost"`
+	CreatedAt         time.Time           `json:"created_at"`
+	UpdatedAt         time.Time           `json:"updated_at"`
+	Items             []PurchaseOrderItem `json:"items"`
+	Receipts          []GoodsReceipt      `json:"receipts,omitempty"`
+}
+
+type PurchaseOrderItem struct {
+	ID               int      `json:"id"`
+	PurchaseOrderID  int      `json:"purchase_order_id"`
+	ProductID        int      `json:"product_id"`
+	ProductName      string   `json:"product_name,omitempty"`
+	QuantityOrdered  Quantity `json:"quantity_ordered"`
+	QuantityReceived Quantity `json:"quantity_received"`
+	ExpectedUnitCost int      `json:"expected_unit_cost"`
+}
+
+type GoodsReceipt struct {
+	ID              int                `json:"id"`
+	PurchaseOrderID int                `json:"purchase_order_id"`
+	Notes           string             `json:"notes"`
+	ReceivedAt      time.Time          `json:"received_at"`
+	Items           []GoodsReceiptItem `json:"items"`
+}
+
+// GoodsReceiptItem is one product received. Batches are the lots it was
+// received in.
+type GoodsReceiptItem struct {
+	ID                  int          `json:"id"`
+	GoodsReceiptID      int          `json:"goods_receipt_id"`
+	PurchaseOrderItemID int          `json:"purchase_order_item_id"`
+	ProductID           int          `json:"product_id"`
+	Quantity            Quantity     `json:"quantity"`
+	UnitCost            int          `json:"unit_cost"`
+	Batches             []StockBatch `json:"batches,omitempty"`
+}
+
+type ReceiveItem struct {
+	ProductID int      `json:"product_id"`
+	Quantity  Quantity `json:"quantity"`
+	// UnitCost falls back to the expected cost on the order when left out.
+	UnitCost *int `json:"unit_cost,omitempty"`
+	// Lots splits Quantity into the lots it arrived in. Without lots the
+	// goods go into stock outside of any batch.
+	Lots []LotQuantity `json:"lots,omitempty"`
+}
+
+type ReceiveRequest struct {
+	Notes string        `json:"notes"`
+	Items []ReceiveItem `json:"items"`
+}
+
+type OutstandingPurchaseOrders struct {
+	SupplierID          int        `json:"supplier_id"`
+	SupplierName        string     `json:"supplier_name"`
+	OpenOrders          int        `json:"open_orders"`
+	OutstandingQuantity Quantity   `json:"outstanding_quantity"`
+	OutstandingValue    int        `json:"outstanding_value"`
+	EarliestExpected    *time.Time `json:"earliest_expected_date"`
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QuantityDecimals is the number of decimals a Quantity keeps. Units allow at
+// most this many; see Unit.Precision.
+const QuantityDecimals = 3
+
+// QuantityScale is the number of Quantity steps in one whole unit.
+const QuantityScale = 1000
+
+// Quantity is an amount of a product in its unit, such as 3 pcs or 1.25 kg.
+// It is kept in thousandths so sums and comparisons are exact. In JSON it is
+// a plain number and in the database a NUMERIC.
+type Quantity int64
+
+// Whole returns n whole units.
+func Whole(n int) Quantity {
+	return Quantity(n) * QuantityScale
+}
+
+// ParseQuantity reads a decimal such as "2", "-0.5" or "1.250". More than
+// QuantityDecimals decimals is an error rather than being rounded away.
+func ParseQuantity(s string) (Quantity, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	if len(fraction) > QuantityDecimals {
+		if strings.TrimRight(fraction[QuantityDecimals:], "0") != "" {
+			return 0, fmt.Errorf("quantity %s has more than %d decimals", s, QuantityDecimals)
+		}
+		fraction = fraction[:QuantityDecimals]
+	}
+	fraction += strings.Repeat("0", QuantityDecimals-len(fraction))
+	if whole == "" {
+		whole = "0"
+	}
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || w < 0 {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	f, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	q := Quantity(w*QuantityScale + f)
+	if negative {
+		q = -q
+	}
+	return q, nil
+}
+
+// String formats the quantity without trailing zeros, e.g. "3" or "1.25".
+func (q Quantity) String() string {
+	sign := ""
+	n := int64(q)
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	whole := strconv.FormatInt(n/QuantityScale, 10)
+	fraction := n % QuantityScale
+	if fraction == 0 {
+		return sign + whole
+	}
+	return sign + whole + "." + strings.TrimRight(fmt.Sprintf("%03d", fraction), "0")
+}
+
+// Float64 returns the quantity as a float, for spreadsheets and ratios only.
+func (q Quantity) Float64() float64 {
+	return float64(q) / QuantityScale
+}
+
+// IsWhole reports whether the quantity has no fractional part.
+func (q Quantity) IsWhole() bool {
+	return q%QuantityScale == 0
+}
+
+// FitsPrecision reports whether the quantity has no more than decimals
+// decimals.
+func (q Quantity) FitsPrecision(decimals int) bool {
+	step := Quantity(1)
+	for i := decimals; i < QuantityDecimals; i++ {
+		step *= 10
+	}
+	return q%step == 0
+}
+
+// Round rounds the quantity half away from zero to the given number of
+// decimals.
+func (q Quantity) Round(decimals int) Quantity {
+	step := Quantity(1)
+	for i := decimals; i < QuantityDecimals; i++ {
+		step *= 10
+	}
+	if q < 0 {
+		return -(-q).Round(decimals)
+	}
+	return (q + step/2) / step * step
+}
+
+// Times multiplies the quantity by a factor that is itself a quantity, such as
+// the number of base units in a dozen. It fails if the result would need more
+// than QuantityDecimals decimals.
+func (q Quantity) Times(factor Quantity) (Quantity, error) {
+	product := int64(q) * int64(factor)
+	if product%QuantityScale != 0 {
+		return 0, fmt.Errorf("%s times %s has more than %d decimals", q, factor, QuantityDecimals)
+	}
+	return Quantity(product / QuantityScale), nil
+}
+
+// Amount returns price × quantity in thousandths of the currency unit, ready
+// to be rounded by the money rules.
+func (q Quantity) Amount(price int) int64 {
+	return int64(price) * int64(q)
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid quantity %s", s)
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	parsed, err := ParseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
+
+func (q *Quantity) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*q = 0
+	case int64:
+		*q = Whole(int(v))
+	case float64:
+		*q = Quantity(v*QuantityScale + 0.5*sign(v))
+	case []byte:
+		return q.scanString(string(v))
+	case string:
+		return q.scanString(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Quantity", value)
+	}
+	return nil
+}
+
+// scanString reads a NUMERIC, which PostgreSQL may return with more decimals
+// than a Quantity keeps when it is the result of a division.
+func (q *Quantity) scanString(s string) error {
+	if _, fraction, ok := strings.Cut(s, "."); ok && len(fraction) > QuantityDecimals {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		return q.Scan(f)
+	}
+	parsed, err := ParseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
+
+func sign(f float64) float64 {
+	if f < 0 {
+		return -1
+	}
+	return 1
+}
+
+func (q Quantity) Value() (driver.Value, error) {
+	return q.String(), nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+type ProfitReportLine struct {
+	GroupID       int      `json:"group_id,omitempty"`
+	GroupName     string   `json:"group_name"`
+	QuantitySold  Quantity `json:"quantity_sold"`
+	Revenue       int      `json:"revenue"`
+	COGS          int      `json:"cogs"`
+	GrossProfit   int      `json:"gross_profit"`
+	MarginPercent float64  `json:"margin_percent"`
+}
+
+type ProfitReport struct {
+	GroupBy       string             `json:"group_by"`
+	StartDate     string             `json:"start_date"`
+	EndDate       string             `json:"end_date"`
+	OutletID      *int               `json:"outlet_id"`
+	CategoryID    *int               `json:"category_id"`
+	Revenue       int                `json:"revenue"`
+	COGS          int                `json:"cogs"`
+	GrossProfit   int                `json:"gross_profit"`
+	MarginPercent float64            `json:"margin_percent"`
+	Lines         []ProfitReportLine `json:"lines"`
+}
+
+type SalesSeriesPoint struct {
+	BucketStart        time.Time `json:"bucket_start"`
+	Revenue            int       `json:"revenue"`
+	TransactionCount   int       `json:"transaction_count"`
+	ItemsSold          Quantity  `json:"items_sold"`
+	AverageBasketValue int       `json:"average_basket_value"`
+	AverageBasketSize  float64   `json:"average_basket_size"`
+}
+
+type SalesSeries struct {
+	Interval   string             `json:"interval"`
+	Start      string             `json:"start"`
+	End        string             `json:"end"`
+	OutletID   *int               `json:"outlet_id"`
+	CategoryID *int               `json:"category_id"`
+	Points     []SalesSeriesPoint `json:"points"`
+}
+
+type SalesBreakdownLine struct {
+	Rank             int      `json:"rank"`
+	ID               int      `json:"id"`
+	Name             string   `json:"name"`
+	QuantitySold     Quantity `json:"quantity_sold"`
+	Revenue          int      `json:"revenue"`
+	TransactionCount int      `json:"transaction_count"`
+	RevenueShare     float64  `json:"revenue_share"`
+}
+
+type SalesBreakdown struct {
+	Dimension  string               `json:"dimension"`
+	RankBy     string               `json:"rank_by"`
+	Order      string               `json:"order"`
+	StartDate  string               `json:"start_date"`
+	EndDate    string               `json:"end_date"`
+	OutletID   *int                 `json:"outlet_id"`
+	CategoryID *int                 `json:"category_id"`
+	Revenue    int                  `json:"revenue"`
+	Lines      []SalesBreakdownLine `json:"lines"`
+}
+
+type DeadStockItem struct {
+	ProductID         int        `json:"product_id"`
+	ProductName       string     `json:"product_name"`
+	CategoryID        int        `json:"category_id"`
+	Stock             Quantity   `json:"stock"`
+	StockValue        int        `json:"stock_value"`
+	LastSoldAt        *time.Time `json:"last_sold_at"`
+	DaysSinceLastSale *int       `json:"days_since_last_sale"`
+}
+
+type DeadStockReport struct {
+	Days       int             `json:"days"`
+	OutletID   *int            `json:"outlet_id"`
+	CategoryID *int            `json:"category_id"`
+	StockValue int             `json:"stock_value"`
+	Items      []DeadStockItem `json:"items"`
+}
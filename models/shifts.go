@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+const (
+	ShiftOpen   = "open"
+	ShiftClosed = "closed"
+
+	CashPayIn  = "pay_in"
+	CashPayOut = "pay_out"
+
+	// An X report is read while the shift is still open; a Z report is the
+	// final one produced when it closes.
+	ShiftReportX = "X"
+	ShiftReportZ = "Z"
+)
+
+// Shift is a cashier's session at the cash drawer. It starts with an
+// OpeningFloat and is closed with the cash the cashier counted.
+type Shift struct {
+	ID           int        `json:"id"`
+	CashierID    int        `json:"cashier_id"`
+	CashierName  string     `json:"cashier_name,omitempty"`
+	OutletID     int        `json:"outlet_id"`
+	Status       string     `json:"status"`
+	OpeningFloat int        `json:"opening_float"`
+	ExpectedCash *int       `json:"expected_cash"`
+	CountedCash  *int       `json:"counted_cash"`
+	Notes        string     `json:"notes"`
+	ClosingNotes string     `json:"closing_notes"`
+	OpenedAt     time.Time  `json:"opened_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+}
+
+// OpenShiftRequest opens a shift. OutletID falls back to the cashier's outlet
+// and then to the default outlet.
+type OpenShiftRequest struct {
+	CashierID    int    `json:"cashier_id"`
+	OutletID     *int   `json:"outlet_id,omitempty"`
+	OpeningFloat int    `json:"opening_float"`
+	Notes        string `json:"notes"`
+}
+
+type CloseShiftRequest struct {
+	CountedCash *int   `json:"counted_cash"`
+	Notes       string `json:"notes"`
+}
+
+// CashMovement is petty cash put into (pay_in) or taken out of (pay_out) the
+// drawer during a shift. Amount is always positive.
+type CashMovement struct {
+	ID        int       `json:"id"`
+	ShiftID   int       `json:"shift_id"`
+	Type      string    `json:"type"`
+	Amount    int       `json:"amount"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ShiftReport reconciles the drawer. ExpectedCash is the float plus cash
+// sales plus pay-ins minus pay-outs; OverShort is counted minus expected, so
+// a shortage is negative. Sales amounts exclude what was paid with points.
+type ShiftReport struct {
+	Type             string         `json:"type"`
+	Shift            Shift          `json:"shift"`
+	TransactionCount int            `json:"transaction_count"`
+	TotalSales       int            `json:"total_sales"`
+	CashSales        int            `json:"cash_sales"`
+	NonCashSales     int            `json:"non_cash_sales"`
+	PointsPayments   int            `json:"points_payments"`
+	PayIns           int            `json:"pay_ins"`
+	PayOuts          int            `json:"pay_outs"`
+	ExpectedCash     int            `json:"expected_cash"`
+	CountedCash      *int           `json:"counted_cash"`
+	OverShort        *int           `json:"over_short"`
+	CashMovements    []CashMovement `json:"cash_movements"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	StockMovementSale            = "sale"
+	StockMovementPurchaseReceipt = "purchase_receipt"
+	// Stock set directly on a product, when it is created or updated, or by
+	// an import. These movements point at the product itself.
+	StockMovementManualAdjustment = "manual_adjustment"
+	StockMovementImport           = "import"
+)
+
+// StockMovement is one signed change to a product's stock at an outlet.
+// ReferenceType and ReferenceID point at the document that caused it, such as
+// a transaction. ChangedBy names who set the stock by hand.
+type StockMovement struct {
+	ID            int       `json:"id"`
+	ProductID     int       `json:"product_id"`
+	OutletID      int       `json:"outlet_id"`
+	Quantity      Quantity  `json:"quantity"`
+	MovementType  string    `json:"movement_type"`
+	ReferenceType string    `json:"reference_type"`
+	ReferenceID   int       `json:"reference_id"`
+	UnitCost      *int      `json:"unit_cost,omitempty"`
+	ChangedBy     string    `json:"changed_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
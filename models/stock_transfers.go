@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+const (
+	StockTransferDraft     = "draft"
+	StockTransferInTransit = "in_transit"
+	StockTransferReceived  = "received"
+
+	StockMovementTransferOut = "transfer_out"
+	StockMovementTransferIn  = "transfer_in"
+)
+
+// StockTransfer moves goods from one outlet to another. Stock leaves the
+// source when the transfer is dispatched and arrives at the destination when
+// it is received.
+type StockTransfer struct {
+	ID                  int                 `json:"id"`
+	SourceOutletID      int                 `json:"source_outlet_id"`
+	DestinationOutletID int                 `json:"destination_outlet_id"`
+	Status              string              `json:"status"`
+	Notes               string              `json:"notes"`
+	ReceiveNotes        string              `json:"receive_notes"`
+	CreatedAt           time.Time           `json:"created_at"`
+	UpdatedAt           time.Time           `json:"updated_at"`
+	DispatchedAt        *time.Time          `json:"dispatched_at"`
+	ReceivedAt          *time.Time          `json:"received_at"`
+	Items               []StockTransferItem `json:"items"`
+}
+
+// StockTransferItem is one product on a transfer. QuantityReceived is nil
+// until the transfer is received; Discrepancy is received minus sent, so a
+// shortage is negative. Lots are the batches the goods were dispatched from.
+type StockTransferItem struct {
+	ID               int           `json:"id"`
+	StockTransferID  int           `json:"stock_transfer_id"`
+	ProductID        int           `json:"product_id"`
+	ProductName      string        `json:"product_name,omitempty"`
+	Quantity         Quantity      `json:"quantity"`
+	QuantityReceived *Quantity     `json:"quantity_received"`
+	Discrepancy      Quantity      `json:"discrepancy"`
+	UnitCost         int           `json:"unit_cost"`
+	Lots             []LotQuantity `json:"lots,omitempty"`
+}
+
+type ReceiveTransferItem struct {
+	ProductID int      `json:"product_id"`
+	Quantity  Quantity `json:"quantity"`
+}
+
+// ReceiveTransferRequest records what arrived. Products that are not listed
+// are taken as received in full.
+type ReceiveTransferRequest struct {
+	Notes string                `json:"notes"`
+	Items []ReceiveTransferItem `json:"items"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+const (
+	StocktakeOpen      = "open"
+	StocktakeFinalized = "finalized"
+	StocktakeCancelled = "cancelled"
+
+	StockMovementStocktakeAdjustment = "stocktake_adjustment"
+)
+
+// Stocktake is a physical inventory count at one outlet. An empty
+// CategoryIDs counts every product; a zero OutletID counts the default outlet.
+type Stocktake struct {
+	ID          int        `json:"id"`
+	OutletID    int        `json:"outlet_id"`
+	Status      string     `json:"status"`
+	CategoryIDs []int      `json:"category_ids"`
+	Notes       string     `json:"notes"`
+	ItemCount   int        `json:"item_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinalizedAt *time.Time `json:"finalized_at"`
+}
+
+type StocktakeCount struct {
+	ProductID int      `json:"product_id"`
+	Quantity  Quantity `json:"quantity"`
+}
+
+// StocktakeCountRequest holds the counts from one device. Submitting again
+// from the same device replaces that device's earlier count of a product.
+type StocktakeCountRequest struct {
+	DeviceID string           `json:"device_id"`
+	Counts   []StocktakeCount `json:"counts"`
+}
+
+// StocktakeVarianceLine compares the counted quantity with the system stock at
+// the time of the last count, so sales made during the count are not reported
+// as shrinkage.
+type StocktakeVarianceLine struct {
+	ProductID       int       `json:"product_id"`
+	ProductName     string    `json:"product_name"`
+	CategoryID      int       `json:"category_id"`
+	SnapshotStock   Quantity  `json:"snapshot_stock"`
+	ExpectedStock   Quantity  `json:"expected_stock"`
+	CountedQuantity *Quantity `json:"counted_quantity"`
+	Variance        Quantity  `json:"variance"`
+	UnitCost        int       `json:"unit_cost"`
+	VarianceValue   int       `json:"variance_value"`
+}
+
+type StocktakeVariance struct {
+	StocktakeID           int                     `json:"stocktake_id"`
+	Status                string                  `json:"status"`
+	CountedProducts       int                     `json:"counted_products"`
+	UncountedProducts     int                     `json:"uncounted_products"`
+	TotalVarianceQuantity Quantity                `json:"total_variance_quantity"`
+	TotalVarianceValue    int                     `json:"total_variance_value"`
+	Lines                 []StocktakeVarianceLine `json:"lines"`
+}
+
+type FinalizeStocktakeRequest struct {
+	// ZeroUncounted treats products nobody counted as having zero stock.
+	// Otherwise they are left unchanged.
+	ZeroUncounted bool `json:"zero_uncounted"`
+}
@@ -2,38 +2,107 @@ package models
 
 import "time"
 
+const (
+	PaymentCash = "cash"
+	PaymentCard = "card"
+	PaymentQRIS = "qris"
+
+	ScaleEmbedsPrice  = "price"
+	ScaleEmbedsWeight = "weight"
+)
+
+// CheckoutConfig holds the checkout's money, barcode and shift rules. Every
+// line subtotal is rounded half up to a multiple of MoneyRounding. A sale
+// needs a cashier with an open shift unless AllowSalesWithoutShift is set, in
+// which case a sale without a cashier belongs to no shift.
+type CheckoutConfig struct {
+	MoneyRounding          int                `json:"money_rounding"`
+	ScaleBarcode           ScaleBarcodeConfig `json:"scale_barcode"`
+	AllowSalesWithoutShift bool               `json:"allow_sales_without_shift"`
+}
+
+// ScaleBarcodeConfig describes the EAN-13 labels printed by the store's
+// scales: Prefix, then ItemDigits of item code, then the embedded value, then
+// a check digit. Prefix plus the item code is the product's barcode. Embedded
+// says whether the value is the price or the weight in thousandths of the
+// product's unit, e.g. grams for a product sold by the kg. An empty Prefix
+// turns scale barcodes off.
+type ScaleBarcodeConfig struct {
+	Prefix     string `json:"prefix"`
+	ItemDigits int    `json:"item_digits"`
+	Embedded   string `json:"embedded"`
+}
+
 type Transaction struct {
-	ID          int                 `json:"id"`
-	TotalAmount int                 `json:"total_amount"`
-	CreatedAt   time.Time           `json:"created_at"`
-	Details     []TransactionDetail `json:"details"`
+	ID             int                 `json:"id"`
+	CustomerID     *int                `json:"customer_id"`
+	CashierID      *int                `json:"cashier_id"`
+	OutletID       int                 `json:"outlet_id"`
+	ShiftID        *int                `json:"shift_id"`
+	PaymentMethod  string              `json:"payment_method"`
+	TotalAmount    int                 `json:"total_amount"`
+	DiscountAmount int                 `json:"discount_amount"`
+	PointsRedeemed int                 `json:"points_redeemed"`
+	PointsPayment  int                 `json:"points_payment"`
+	PointsEarned   int                 `json:"points_earned"`
+	PriceListID    *int                `json:"price_list_id"`
+	CreatedAt      time.Time           `json:"created_at"`
+	Details        []TransactionDetail `json:"details"`
 }
 
+// TransactionDetail is one product sold. A bundle is sold as one detail per
+// component, each with the BundleID and BundleQuantity it was sold in and its
+// share of the bundle's price as Subtotal.
 type TransactionDetail struct {
-	ID            int    `json:"id"`
-	TransactionID int    `json:"transaction_id"`
-	ProductID     int    `json:"product_id"`
-	ProductName   string `json:"product_name,omitempty"`
-	Quantity      int    `json:"quantity"`
-	Subtotal      int    `json:"subtotal"`
+	ID             int       `json:"id"`
+	TransactionID  int       `json:"transaction_id"`
+	ProductID      int       `json:"product_id"`
+	ProductName    string    `json:"product_name,omitempty"`
+	Quantity       Quantity  `json:"quantity"`
+	Unit           string    `json:"unit"`
+	Subtotal       int       `json:"subtotal"`
+	UnitCost       int       `json:"unit_cost"`
+	BundleID       *int      `json:"bundle_id,omitempty"`
+	BundleQuantity *Quantity `json:"bundle_quantity,omitempty"`
 }
 
+// CheckoutItem is one line of a basket, picked either by ProductID or by
+// scanning a Barcode. Quantity is counted in Unit, which defaults to the
+// product's own unit; a barcode of one of the product's units sells in that
+// unit, and a scale label carries its own weight or price, so Quantity counts
+// labels and defaults to one.
 type CheckoutItem struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID int      `json:"product_id,omitempty"`
+	Barcode   string   `json:"barcode,omitempty"`
+	Unit      string   `json:"unit,omitempty"`
+	Quantity  Quantity `json:"quantity"`
 }
 
+// CheckoutRequest is a sale. The outlet it happens at is OutletID, or else
+// the cashier's outlet, or else the default outlet. A sale with a CashierID
+// is booked to that cashier's open shift. PaymentMethod defaults to cash.
+// PriceListID selects a price list; without it the customer's group decides.
 type CheckoutRequest struct {
-	Items []CheckoutItem `json:"items"`
+	CustomerID    *int           `json:"customer_id,omitempty"`
+	PriceListID   *int           `json:"price_list_id,omitempty"`
+	CashierID     *int           `json:"cashier_id,omitempty"`
+	OutletID      *int           `json:"outlet_id,omitempty"`
+	PaymentMethod string         `json:"payment_method,omitempty"`
+	RedeemPoints  int            `json:"redeem_points,omitempty"`
+	RedeemAs      string         `json:"redeem_as,omitempty"`
+	Items         []CheckoutItem `json:"items"`
 }
 
 type BestSellingProduct struct {
-	ProductName  string `json:"product_name"`
-	QuantitySold int    `json:"quantity_sold"`
+	ProductName  string   `json:"product_name"`
+	QuantitySold Quantity `json:"quantity_sold"`
 }
 
 type TransactionReport struct {
 	TotalRevenue       int                `json:"total_revenue"`
 	TotalTransaction   int                `json:"total_transaction"`
+	TotalCOGS          int                `json:"total_cogs"`
+	GrossProfit        int                `json:"gross_profit"`
+	MarginPercent      float64            `json:"margin_percent"`
 	BestSellingProduct BestSellingProduct `json:"best_selling_product"`
 }
@@ -0,0 +1,20 @@
+package models
+
+// Unit is a unit of measure. Precision is how many decimals a quantity in the
+// unit may have: 0 for pieces, 3 for kilograms weighed to the gram.
+type Unit struct {
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Precision int    `json:"precision"`
+}
+
+// ProductUnit is another unit a product is sold in. One of it holds Factor of
+// the product's own unit, e.g. a dozen of a product kept in pcs has factor 12.
+// Price is what one of it sells for; nil means Factor times the product's
+// price. Scanning Barcode sells the product in this unit.
+type ProductUnit struct {
+	Unit    string   `json:"unit"`
+	Factor  Quantity `json:"factor"`
+	Price   *int     `json:"price"`
+	Barcode string   `json:"barcode"`
+}
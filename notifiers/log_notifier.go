@@ -0,0 +1,20 @@
+package notifiers
+
+import (
+	"go-kasir-api/models"
+	"log"
+)
+
+// LogNotifier writes low-stock events to the application log. It is the
+// default when no webhook is configured.
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) NotifyLowStock(event models.LowStockEvent) error {
+	log.Printf("Low stock: product %d (%s) has %d left, reorder point %d",
+		event.ProductID, event.ProductName, event.Stock, event.ReorderPoint)
+	return nil
+}
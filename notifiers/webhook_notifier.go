@@ -0,0 +1,42 @@
+package notifiers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-kasir-api/models"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier posts each event as JSON to a URL, e.g. a chat bot or an
+// automation service.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *WebhookNotifier) NotifyLowStock(event models.LowStockEvent) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"type":  "low_stock",
+		"event": event,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("low stock webhook returned %s", resp.Status)
+	}
+	return nil
+}
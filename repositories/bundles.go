@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+
+	"github.com/lib/pq"
+)
+
+// bundleStockColumn selects the stock of products p: for a bundle, how many
+// whole bundles its components' stock can make.
+const bundleStockColumn = `COALESCE((SELECT MIN(FLOOR(cp.stock / bc.quantity))
+	                              FROM bundle_components bc
+	                              JOIN products cp ON cp.id = bc.product_id
+	                              WHERE bc.bundle_id = p.id), p.stock)`
+
+// bundleComponentsColumn selects a bundle's components as a JSON array, for
+// use with the products p alias.
+const bundleComponentsColumn = `COALESCE((SELECT json_agg(json_build_object('product_id', bc.product_id, 'product_name', cp.name,
+	                                                           'quantity', bc.quantity) ORDER BY bc.product_id)
+	                                   FROM bundle_components bc
+	                                   JOIN products cp ON cp.id = bc.product_id
+	                                   WHERE bc.bundle_id = p.id), '[]')`
+
+// checkBundle validates the components of a product. id is the product
+// itself when it is being updated and current its stock. Components must be
+// stocked products: not listings, not bundles and not the bundle itself.
+func checkBundle(tx *sql.Tx, id int, product models.Product, current models.Quantity) error {
+	if len(product.Components) == 0 {
+		return nil
+	}
+	if len(product.Options) > 0 {
+		return errors.New("A product with variants cannot be a bundle")
+	}
+	if current != 0 {
+		return errors.New("Product still has stock; it cannot become a bundle")
+	}
+	var component bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM bundle_components WHERE product_id = $1)", id).Scan(&component)
+	if err != nil {
+		return err
+	}
+	if component {
+		return errors.New("Product is part of a bundle and cannot be a bundle itself")
+	}
+
+	seen := make(map[int]bool, len(product.Components))
+	for _, c := range product.Components {
+		if id != 0 && c.ProductID == id {
+			return errors.New("A bundle cannot contain itself")
+		}
+		if seen[c.ProductID] {
+			return errors.New("A bundle lists a component more than once")
+		}
+		seen[c.ProductID] = true
+
+		var name, unit string
+		var precision int
+		var listing, bundle bool
+		err := tx.QueryRow(`SELECT p.name, p.unit, u.precision, p.options <> '[]'::jsonb,
+		                           EXISTS (SELECT 1 FROM bundle_components WHERE bundle_id = p.id)
+		                    FROM products p
+		                    JOIN units u ON u.code = p.unit
+		                    WHERE p.id = $1`, c.ProductID).Scan(&name, &unit, &precision, &listing, &bundle)
+		if err == sql.ErrNoRows {
+			return errors.New("Component product not found")
+		}
+		if err != nil {
+			return err
+		}
+		if listing {
+			return errors.New(name + " has variants; add one of its variants to the bundle")
+		}
+		if bundle {
+			return errors.New(name + " is a bundle and cannot be part of another bundle")
+		}
+		if c.Quantity <= 0 {
+			return errors.New("Quantity of " + name + " must be greater than zero")
+		}
+		if !c.Quantity.FitsPrecision(precision) {
+			return precisionError(name, unit, precision)
+		}
+	}
+	return nil
+}
+
+// saveBundleComponents replaces the components of a bundle.
+func saveBundleComponents(tx *sql.Tx, bundleID int, components []models.BundleComponent) error {
+	if _, err := tx.Exec("DELETE FROM bundle_components WHERE bundle_id = $1", bundleID); err != nil {
+		return err
+	}
+	if len(components) == 0 {
+		return nil
+	}
+	productIDs := make([]int64, len(components))
+	quantities := make([]models.Quantity, len(components))
+	for i, c := range components {
+		productIDs[i] = int64(c.ProductID)
+		quantities[i] = c.Quantity
+	}
+	_, err := tx.Exec(`INSERT INTO bundle_components (bundle_id, product_id, quantity)
+	                   SELECT $1, v.product_id, v.quantity
+	                   FROM unnest($2::int[], $3::numeric[]) AS v(product_id, quantity)`,
+		bundleID, pq.Array(productIDs), pq.Array(quantities))
+	return err
+}
+
+// bundleComponents returns the components of those products that are
+// bundles, keyed by bundle.
+func bundleComponents(tx *sql.Tx, productIDs []int64) (map[int][]models.BundleComponent, error) {
+	rows, err := tx.Query(`SELECT bundle_id, product_id, quantity FROM bundle_components
+	                       WHERE bundle_id = ANY($1)
+	                       ORDER BY bundle_id, product_id`, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	components := make(map[int][]models.BundleComponent)
+	for rows.Next() {
+		var bundleID int
+		var c models.BundleComponent
+		if err := rows.Scan(&bundleID, &c.ProductID, &c.Quantity); err != nil {
+			return nil, err
+		}
+		components[bundleID] = append(components[bundleID], c)
+	}
+	return components, rows.Err()
+}
+
+// allocateAmount splits total over lines in proportion to their weights, such
+// as the value of each component of a bundle. The shares always add up to
+// total; the last line takes what rounding leaves over, and lines share
+// equally when no line has any weight.
+func allocateAmount(total int, weights []int64) []int {
+	shares := make([]int, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+	var sum int64
+	for _, w := range weights {
+		sum += w
+	}
+	allocated := 0
+	for i, w := range weights[:len(weights)-1] {
+		if sum > 0 {
+			shares[i] = int(int64(total) * w / sum)
+		} else {
+			shares[i] = total / len(weights)
+		}
+		allocated += shares[i]
+	}
+	shares[len(shares)-1] = total - allocated
+	return shares
+}
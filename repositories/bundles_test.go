@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllocateAmount(t *testing.T) {
+	tests := []struct {
+		total   int
+		weights []int64
+		want    []int
+	}{
+		{100000, []int64{30000000, 10000000}, []int{75000, 25000}},
+		{100, []int64{1, 1, 1}, []int{33, 33, 34}},
+		{90, []int64{0, 0}, []int{45, 45}},
+		{50000, []int64{20000}, []int{50000}},
+		{0, []int64{5, 5}, []int{0, 0}},
+		{10, nil, []int{}},
+	}
+	for _, tt := range tests {
+		if got := allocateAmount(tt.total, tt.weights); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("allocateAmount(%d, %v) = %v, want %v", tt.total, tt.weights, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BusinessCalendar maps sale timestamps to the store's business days. A
+// business day starts at CutoffHour in the store's timezone, so with a cutoff
+// of 4 a sale at 01:30 counts towards the previous day.
+type BusinessCalendar struct {
+	location   *time.Location
+	cutoffHour int
+}
+
+func NewBusinessCalendar(timezone string, cutoffHour int) (*BusinessCalendar, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store timezone %q: %w", timezone, err)
+	}
+	if cutoffHour < 0 || cutoffHour > 23 {
+		return nil, errors.New("business day cutoff hour must be between 0 and 23")
+	}
+	return &BusinessCalendar{location: location, cutoffHour: cutoffHour}, nil
+}
+
+// Today returns the current business date.
+func (c *BusinessCalendar) Today() time.Time {
+	return c.DateOf(time.Now())
+}
+
+// LocalDate returns today's date on the store's clock, as midnight UTC.
+// Unlike Today it ignores the cutoff, for dates such as expiry dates that
+// follow the calendar rather than the business day.
+func (c *BusinessCalendar) LocalDate() time.Time {
+	local := time.Now().In(c.location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// DateOf returns the business date an instant belongs to, as midnight UTC.
+func (c *BusinessCalendar) DateOf(t time.Time) time.Time {
+	local := t.In(c.location).Add(-time.Duration(c.cutoffHour) * time.Hour)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// StartOf returns the instant the business day on date begins.
+func (c *BusinessCalendar) StartOf(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), c.cutoffHour, 0, 0, 0, c.location)
+}
+
+// Bounds returns the half-open range of instants covering the business days
+// from start through end.
+func (c *BusinessCalendar) Bounds(start time.Time, end time.Time) (time.Time, time.Time) {
+	return c.StartOf(start), c.StartOf(end.AddDate(0, 0, 1))
+}
+
+// localTime returns SQL that turns a TIMESTAMP column written by the database
+// into wall-clock time in the store's timezone. When shifted, the cutoff is
+// taken off so truncating to a day, week or month yields the business period.
+func (c *BusinessCalendar) localTime(column string, shifted bool) string {
+	expr := fmt.Sprintf("(%s::timestamptz AT TIME ZONE %s)", column, pq.QuoteLiteral(c.location.String()))
+	if shifted && c.cutoffHour > 0 {
+		expr = fmt.Sprintf("(%s - interval '%d hours')", expr, c.cutoffHour)
+	}
+	return expr
+}
+
+// instant undoes localTime, turning a store wall-clock timestamp back into a
+// TIMESTAMPTZ.
+func (c *BusinessCalendar) instant(expr string, shifted bool) string {
+	if shifted && c.cutoffHour > 0 {
+		expr = fmt.Sprintf("(%s + interval '%d hours')", expr, c.cutoffHour)
+	}
+	return fmt.Sprintf("(%s AT TIME ZONE %s)", expr, pq.QuoteLiteral(c.location.String()))
+}
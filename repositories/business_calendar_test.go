@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBusinessCalendarCutoff(t *testing.T) {
+	calendar, err := NewBusinessCalendar("Asia/Makassar", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 01:30 WITA on the 2nd is still the business day of the 1st.
+	lateNight := time.Date(2026, 2, 1, 17, 30, 0, 0, time.UTC)
+	if got := calendar.DateOf(lateNight).Format(dateLayout); got != "2026-02-01" {
+		t.Errorf("DateOf(%v) = %s, want 2026-02-01", lateNight, got)
+	}
+	morning := time.Date(2026, 2, 1, 20, 0, 0, 0, time.UTC)
+	if got := calendar.DateOf(morning).Format(dateLayout); got != "2026-02-02" {
+		t.Errorf("DateOf(%v) = %s, want 2026-02-02", morning, got)
+	}
+
+	day := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	from, to := calendar.Bounds(day, day)
+	if want := time.Date(2026, 1, 31, 20, 0, 0, 0, time.UTC); !from.Equal(want) {
+		t.Errorf("from = %v, want %v", from.UTC(), want)
+	}
+	if want := time.Date(2026, 2, 1, 20, 0, 0, 0, time.UTC); !to.Equal(want) {
+		t.Errorf("to = %v, want %v", to.UTC(), want)
+	}
+
+	if _, err := NewBusinessCalendar("Asia/Nowhere", 0); err == nil {
+		t.Error("expected an error for an unknown timezone")
+	}
+	if _, err := NewBusinessCalendar("Asia/Jakarta", 24); err == nil {
+		t.Error("expected an error for a cutoff of 24")
+	}
+}
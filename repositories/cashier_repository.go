@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+)
+
+type CashierRepository struct {
+	db *sql.DB
+}
+
+func NewCashierRepository(db *sql.DB) *CashierRepository {
+	return &CashierRepository{db: db}
+}
+
+func (r *CashierRepository) GetAll() ([]models.Cashier, error) {
+	rows, err := r.db.Query("SELECT id, name, outlet_id, active, created_at FROM cashiers ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cashiers []models.Cashier
+	for rows.Next() {
+		var cashier models.Cashier
+		err := rows.Scan(&cashier.ID, &cashier.Name, &cashier.OutletID, &cashier.Active, &cashier.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		cashiers = append(cashiers, cashier)
+	}
+	return cashiers, nil
+}
+
+func (r *CashierRepository) Create(cashier models.Cashier) (models.Cashier, error) {
+	query := "INSERT INTO cashiers (name, outlet_id, active) VALUES ($1, $2, $3) RETURNING id, created_at"
+	err := r.db.QueryRow(query, cashier.Name, cashier.OutletID, cashier.Active).Scan(&cashier.ID, &cashier.CreatedAt)
+	if err != nil {
+		return models.Cashier{}, err
+	}
+	return cashier, nil
+}
+
+func (r *CashierRepository) GetByID(id int) (models.Cashier, error) {
+	query := "SELECT id, name, outlet_id, active, created_at FROM cashiers WHERE id = $1"
+	var cashier models.Cashier
+	err := r.db.QueryRow(query, id).Scan(&cashier.ID, &cashier.Name, &cashier.OutletID, &cashier.Active, &cashier.CreatedAt)
+	if err != nil {
+		return models.Cashier{}, err
+	}
+	return cashier, nil
+}
+
+func (r *CashierRepository) Update(id int, cashier models.Cashier) (models.Cashier, error) {
+	query := "UPDATE cashiers SET name = $2, outlet_id = $3, active = $4, updated_at = NOW() WHERE id = $1"
+	result, err := r.db.Exec(query, id, cashier.Name, cashier.OutletID, cashier.Active)
+	if err != nil {
+		return models.Cashier{}, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return models.Cashier{}, err
+	}
+	if rows == 0 {
+		return models.Cashier{}, errors.New("cashier not found")
+	}
+
+	cashier.ID = id
+	return cashier, nil
+}
+
+// Delete only removes cashiers without sales or shifts. Deactivate the others
+// instead so their history stays in the reports.
+func (r *CashierRepository) Delete(id int) error {
+	var used bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM transactions WHERE cashier_id = $1)
+	                         OR EXISTS (SELECT 1 FROM cashier_shifts WHERE cashier_id = $1)`, id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return errors.New("cashier has transactions or shifts and cannot be deleted, deactivate it instead")
+	}
+
+	result, err := r.db.Exec("DELETE FROM cashiers WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("cashier not found")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"go-kasir-api/models"
 )
 
@@ -15,7 +16,7 @@ func NewCategoryRepository(db *sql.DB) *CategoryRepository {
 }
 
 func (r *CategoryRepository) GetAll() ([]models.Category, error) {
-	query := "SELECT id, name, description FROM categories ORDER BY id ASC"
+	query := "SELECT id, parent_id, name, description FROM categories ORDER BY id ASC"
 	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -25,7 +26,7 @@ func (r *CategoryRepository) GetAll() ([]models.Category, error) {
 	var categories []models.Category
 	for rows.Next() {
 		var category models.Category
-		err := rows.Scan(&category.ID, &category.Name, &category.Description)
+		err := rows.Scan(&category.ID, &category.ParentID, &category.Name, &category.Description)
 		if err != nil {
 			return nil, err
 		}
@@ -34,9 +35,39 @@ func (r *CategoryRepository) GetAll() ([]models.Category, error) {
 	return categories, nil
 }
 
+// GetTree returns the categories nested under their parents, or only the
+// subtree under rootID when it is set.
+func (r *CategoryRepository) GetTree(rootID *int) ([]models.Category, error) {
+	categories, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if rootID == nil {
+		return buildCategoryTree(categories, nil), nil
+	}
+	for _, category := range categories {
+		if category.ID == *rootID {
+			category.Children = buildCategoryTree(categories, rootID)
+			return []models.Category{category}, nil
+		}
+	}
+	return nil, errors.New("category not found")
+}
+
 func (r *CategoryRepository) Create(category models.Category) (models.Category, error) {
-	query := "INSERT INTO categories (name, description) VALUES ($1, $2) RETURNING id"
-	row := r.db.QueryRow(query, category.Name, category.Description)
+	if category.ParentID != nil {
+		var exists bool
+		err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)", *category.ParentID).Scan(&exists)
+		if err != nil {
+			return models.Category{}, err
+		}
+		if !exists {
+			return models.Category{}, errors.New("parent category not found")
+		}
+	}
+
+	query := "INSERT INTO categories (parent_id, name, description) VALUES ($1, $2, $3) RETURNING id"
+	row := r.db.QueryRow(query, category.ParentID, category.Name, category.Description)
 	var id int
 	err := row.Scan(&id)
 	if err != nil {
@@ -47,19 +78,50 @@ func (r *CategoryRepository) Create(category models.Category) (models.Category,
 }
 
 func (r *CategoryRepository) GetByID(id int) (models.Category, error) {
-	query := "SELECT id, name, description FROM categories WHERE id = $1"
+	query := "SELECT id, parent_id, name, description FROM categories WHERE id = $1"
 	row := r.db.QueryRow(query, id)
 	var category models.Category
-	err := row.Scan(&category.ID, &category.Name, &category.Description)
+	err := row.Scan(&category.ID, &category.ParentID, &category.Name, &category.Description)
 	if err != nil {
 		return models.Category{}, err
 	}
 	return category, nil
 }
 
+// Update renames a category and moves it, with everything under it, to
+// ParentID. A category cannot be moved under itself or its own descendants.
 func (r *CategoryRepository) Update(id int, category models.Category) (models.Category, error) {
-	query := "UPDATE categories SET name = $2, description = $3 WHERE id = $1 RETURNING id"
-	result, err := r.db.Exec(query, id, category.Name, category.Description)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Category{}, err
+	}
+	defer tx.Rollback()
+
+	// Moves are serialized so two of them cannot each pass the cycle check
+	// and together make a loop.
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('categories'))"); err != nil {
+		return models.Category{}, err
+	}
+	if category.ParentID != nil {
+		if *category.ParentID == id {
+			return models.Category{}, errors.New("a category cannot be its own parent")
+		}
+		var exists, descendant bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM categories WHERE id = $2),
+		                           $2 IN (`+categorySubtree("$1")+`)`, id, *category.ParentID).Scan(&exists, &descendant)
+		if err != nil {
+			return models.Category{}, err
+		}
+		if !exists {
+			return models.Category{}, errors.New("parent category not found")
+		}
+		if descendant {
+			return models.Category{}, errors.New("a category cannot be moved under one of its own subcategories")
+		}
+	}
+
+	query := "UPDATE categories SET parent_id = $2, name = $3, description = $4 WHERE id = $1"
+	result, err := tx.Exec(query, id, category.ParentID, category.Name, category.Description)
 	if err != nil {
 		return models.Category{}, err
 	}
@@ -71,13 +133,40 @@ func (r *CategoryRepository) Update(id int, category models.Category) (models.Ca
 	if rows == 0 {
 		return models.Category{}, errors.New("category not found")
 	}
+	if err := tx.Commit(); err != nil {
+		return models.Category{}, err
+	}
 
+	category.ID = id
 	return category, nil
 }
 
+// Delete removes a category that has neither subcategories nor products.
 func (r *CategoryRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('categories'))"); err != nil {
+		return err
+	}
+	var children, products bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM categories WHERE parent_id = $1),
+	                          EXISTS (SELECT 1 FROM products WHERE category_id = $1)`, id).Scan(&children, &products)
+	if err != nil {
+		return err
+	}
+	if children {
+		return errors.New("category has subcategories and cannot be deleted, move or delete them first")
+	}
+	if products {
+		return errors.New("category has products and cannot be deleted, move them to another category first")
+	}
+
 	query := "DELETE FROM categories WHERE id = $1"
-	result, err := r.db.Exec(query, id)
+	result, err := tx.Exec(query, id)
 	if err != nil {
 		return err
 	}
@@ -89,5 +178,59 @@ func (r *CategoryRepository) Delete(id int) error {
 	if rows == 0 {
 		return errors.New("category not found")
 	}
-	return nil
+	return tx.Commit()
+}
+
+// buildCategoryTree nests categories under their parents, starting with the
+// children of parentID, or the top-level categories when it is nil.
+func buildCategoryTree(categories []models.Category, parentID *int) []models.Category {
+	children := make(map[int][]models.Category)
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var nest func(level []models.Category) []models.Category
+	nest = func(level []models.Category) []models.Category {
+		nested := make([]models.Category, len(level))
+		for i, category := range level {
+			category.Children = nest(children[category.ID])
+			nested[i] = category
+		}
+		return nested
+	}
+	if parentID != nil {
+		return nest(children[*parentID])
+	}
+	return nest(roots)
+}
+
+// categorySubtree returns SQL selecting the IDs of the category at the
+// placeholder and all of its descendants.
+func categorySubtree(placeholder string) string {
+	return fmt.Sprintf(`WITH RECURSIVE subtree AS (
+	                        SELECT id FROM categories WHERE id = %[1]s::int
+	                        UNION
+	                        SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+	                    ) SELECT id FROM subtree`, placeholder)
+}
+
+// categorySubtrees returns SQL selecting the IDs of the categories in the int
+// array at the placeholder and all of their descendants.
+func categorySubtrees(placeholder string) string {
+	return fmt.Sprintf(`WITH RECURSIVE subtree AS (
+	                        SELECT id FROM categories WHERE id = ANY(%[1]s::int[])
+	                        UNION
+	                        SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+	                    ) SELECT id FROM subtree`, placeholder)
+}
+
+// inCategory returns SQL that is true when column is the category at the
+// placeholder or one of its descendants, or when the placeholder is NULL.
+func inCategory(column string, placeholder string) string {
+	return fmt.Sprintf("(%[2]s::int IS NULL OR %[1]s IN (%[3]s))", column, placeholder, categorySubtree(placeholder))
 }
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"go-kasir-api/models"
+	"testing"
+)
+
+func TestBuildCategoryTree(t *testing.T) {
+	id := func(n int) *int { return &n }
+	categories := []models.Category{
+		{ID: 1, Name: "Food"},
+		{ID: 2, ParentID: id(1), Name: "Snacks"},
+		{ID: 3, ParentID: id(2), Name: "Chips"},
+		{ID: 4, Name: "Drinks"},
+		{ID: 5, ParentID: id(1), Name: "Frozen"},
+	}
+
+	tree := buildCategoryTree(categories, nil)
+	if len(tree) != 2 || tree[0].ID != 1 || tree[1].ID != 4 {
+		t.Fatalf("roots = %+v, want Food and Drinks", tree)
+	}
+	food := tree[0]
+	if len(food.Children) != 2 || food.Children[0].ID != 2 || food.Children[1].ID != 5 {
+		t.Fatalf("children of Food = %+v, want Snacks and Frozen", food.Children)
+	}
+	if chips := food.Children[0].Children; len(chips) != 1 || chips[0].ID != 3 || len(chips[0].Children) != 0 {
+		t.Errorf("children of Snacks = %+v, want Chips alone", chips)
+	}
+	if tree[1].Children == nil || len(tree[1].Children) != 0 {
+		t.Errorf("children of Drinks = %#v, want an empty list", tree[1].Children)
+	}
+
+	subtree := buildCategoryTree(categories, id(2))
+	if len(subtree) != 1 || subtree[0].ID != 3 {
+		t.Errorf("subtree of Snacks = %+v, want Chips", subtree)
+	}
+}
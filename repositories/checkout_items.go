@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// checkoutLine is a basket item resolved to a product. quantity is counted in
+// unit, or in the product's own unit when unit is empty. A price label from a
+// scale sets labelAmount instead, in thousandths of the currency, and the
+// quantity follows from the product's price.
+type checkoutLine struct {
+	productID   int
+	unit        string
+	quantity    models.Quantity
+	labelAmount *int64
+}
+
+// checkoutSale is everything a basket sells of one product, in the product's
+// own unit. priced is the part of quantity charged at price, the product's
+// unit price, and fixed what the rest costs, such as price labels and units
+// with a price of their own, in thousandths of the currency.
+type checkoutSale struct {
+	productID int
+	quantity  models.Quantity
+	priced    models.Quantity
+	fixed     int64
+	price     int
+}
+
+// amount is what the sale costs in thousandths of the currency, before
+// rounding.
+func (s checkoutSale) amount() int64 {
+	return s.fixed + s.priced.Amount(s.price)
+}
+
+// resolveCheckoutItems looks up the product behind every scanned barcode.
+func (r *TransactionRepository) resolveCheckoutItems(tx *sql.Tx, items []models.CheckoutItem) ([]checkoutLine, error) {
+	lines := make([]checkoutLine, len(items))
+	for i, item := range items {
+		if item.Barcode == "" {
+			lines[i] = checkoutLine{productID: item.ProductID, unit: item.Unit, quantity: item.Quantity}
+			continue
+		}
+		if item.ProductID != 0 || item.Unit != "" {
+			return nil, errors.New("Give either a barcode or a product_id and unit")
+		}
+
+		if itemBarcode, value, ok := parseScaleBarcode(r.checkout.ScaleBarcode, item.Barcode); ok {
+			var productID int
+			err := tx.QueryRow("SELECT id FROM products WHERE barcode = $1", itemBarcode).Scan(&productID)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+			if err == nil {
+				if !item.Quantity.IsWhole() {
+					return nil, errors.New("Quantity of a scale label must be a whole number")
+				}
+				labels := int64(item.Quantity / models.QuantityScale)
+				line := checkoutLine{productID: productID}
+				if r.checkout.ScaleBarcode.Embedded == models.ScaleEmbedsPrice {
+					amount := int64(value) * models.QuantityScale * labels
+					line.labelAmount = &amount
+				} else {
+					line.quantity = models.Quantity(int64(value) * labels)
+				}
+				lines[i] = line
+				continue
+			}
+		}
+
+		var productID int
+		var unit sql.NullString
+		err := tx.QueryRow(`SELECT id, NULL FROM products WHERE barcode = $1
+		                    UNION ALL
+		                    SELECT product_id, unit FROM product_units WHERE barcode = $1`, item.Barcode).Scan(&productID, &unit)
+		if err == sql.ErrNoRows {
+			return nil, errors.New("Unknown barcode " + item.Barcode)
+		}
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = checkoutLine{productID: productID, unit: unit.String, quantity: item.Quantity}
+	}
+	return lines, nil
+}
+
+// sellingUnit is one of a product's other units as checkout needs it.
+type sellingUnit struct {
+	factor    models.Quantity
+	price     *int
+	precision int
+}
+
+// sellingUnits returns the other units of products, keyed by product and
+// unit code.
+func sellingUnits(tx *sql.Tx, productIDs []int64) (map[int]map[string]sellingUnit, error) {
+	rows, err := tx.Query(`SELECT pu.product_id, pu.unit, pu.factor, pu.price, u.precision
+	                       FROM product_units pu
+	                       JOIN units u ON u.code = pu.unit
+	                       WHERE pu.product_id = ANY($1)`, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := make(map[int]map[string]sellingUnit)
+	for rows.Next() {
+		var productID int
+		var code string
+		var unit sellingUnit
+		var price sql.NullInt64
+		if err := rows.Scan(&productID, &code, &unit.factor, &price, &unit.precision); err != nil {
+			return nil, err
+		}
+		if price.Valid {
+			p := int(price.Int64)
+			unit.price = &p
+		}
+		if units[productID] == nil {
+			units[productID] = make(map[string]sellingUnit)
+		}
+		units[productID][code] = unit
+	}
+	return units, rows.Err()
+}
+
+// priceCheckoutLine converts a line to the product's own unit and prices it.
+// price is the product's price at the outlet and precision its unit's.
+func priceCheckoutLine(line checkoutLine, product models.Product, price int, precision int, units map[string]sellingUnit) (checkoutSale, error) {
+	sale := checkoutSale{productID: product.ID, price: price}
+	switch {
+	case line.labelAmount != nil:
+		if price <= 0 {
+			return checkoutSale{}, errors.New(product.Name + " has no price to weigh its price label against")
+		}
+		sale.fixed = *line.labelAmount
+		sale.quantity = models.Quantity((sale.fixed + int64(price)/2) / int64(price)).Round(precision)
+	case line.unit == "" || line.unit == product.Unit:
+		sale.quantity = line.quantity
+		sale.priced = line.quantity
+	default:
+		unit, ok := units[line.unit]
+		if !ok {
+			return checkoutSale{}, errors.New(product.Name + " is not sold in " + line.unit)
+		}
+		if !line.quantity.FitsPrecision(unit.precision) {
+			return checkoutSale{}, precisionError(product.Name, line.unit, unit.precision)
+		}
+		quantity, err := line.quantity.Times(unit.factor)
+		if err != nil {
+			return checkoutSale{}, err
+		}
+		sale.quantity = quantity
+		if unit.price != nil {
+			sale.fixed = line.quantity.Amount(*unit.price)
+		} else {
+			sale.priced = quantity
+		}
+	}
+	if sale.quantity <= 0 {
+		return checkoutSale{}, errors.New("Quantity of " + product.Name + " must be greater than zero")
+	}
+	if !sale.quantity.FitsPrecision(precision) {
+		return checkoutSale{}, precisionError(product.Name, product.Unit, precision)
+	}
+	return sale, nil
+}
+
+// parseScaleBarcode splits an EAN-13 label printed by a scale into the
+// product's barcode and the embedded value. ok is false for any other
+// barcode, including one whose check digit is wrong.
+func parseScaleBarcode(config models.ScaleBarcodeConfig, code string) (string, int, bool) {
+	valueDigits := 12 - len(config.Prefix) - config.ItemDigits
+	if config.Prefix == "" || config.ItemDigits <= 0 || valueDigits <= 0 || len(code) != 13 {
+		return "", 0, false
+	}
+	if code[:len(config.Prefix)] != config.Prefix {
+		return "", 0, false
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		digit := int(code[i] - '0')
+		if digit < 0 || digit > 9 {
+			return "", 0, false
+		}
+		if i < 12 {
+			if i%2 == 1 {
+				digit *= 3
+			}
+			sum += digit
+		} else if (10-sum%10)%10 != digit {
+			return "", 0, false
+		}
+	}
+
+	itemEnd := len(config.Prefix) + config.ItemDigits
+	value, err := strconv.Atoi(code[itemEnd:12])
+	if err != nil {
+		return "", 0, false
+	}
+	return code[:itemEnd], value, true
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"go-kasir-api/models"
+	"testing"
+)
+
+func TestParseScaleBarcode(t *testing.T) {
+	config := models.ScaleBarcodeConfig{Prefix: "20", ItemDigits: 5, Embedded: models.ScaleEmbedsWeight}
+
+	item, value, ok := parseScaleBarcode(config, "2000123012506")
+	if !ok {
+		t.Fatal("expected a scale barcode")
+	}
+	if item != "2000123" || value != 1250 {
+		t.Errorf("got item %q value %d, want 2000123 and 1250", item, value)
+	}
+
+	for _, code := range []string{
+		"2000123012505", // wrong check digit
+		"2100123012503", // other prefix
+		"200012301250",  // too short
+		"20001230125a4", // not a number
+	} {
+		if _, _, ok := parseScaleBarcode(config, code); ok {
+			t.Errorf("%s should not parse as a scale barcode", code)
+		}
+	}
+	if _, _, ok := parseScaleBarcode(models.ScaleBarcodeConfig{}, "2000123012506"); ok {
+		t.Error("scale barcodes should be off without a prefix")
+	}
+}
+
+func TestRoundAmount(t *testing.T) {
+	tests := []struct {
+		amount int64
+		unit   int
+		want   int
+	}{
+		{12499, 1, 12},
+		{12500, 1, 13},
+		{-12500, 1, -13},
+		{1249000, 100, 1200},
+		{1250000, 100, 1300},
+		{1250000, 0, 1250},
+	}
+	for _, tt := range tests {
+		if got := roundAmount(tt.amount, tt.unit); got != tt.want {
+			t.Errorf("roundAmount(%d, %d) = %d, want %d", tt.amount, tt.unit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+	"strconv"
+	"strings"
+)
+
+type CustomerRepository struct {
+	db *sql.DB
+}
+
+func NewCustomerRepository(db *sql.DB) *CustomerRepository {
+	return &CustomerRepository{db: db}
+}
+
+func (r *CustomerRepository) GetAll(name string, phone string, email string) ([]models.Customer, error) {
+	args := []interface{}{}
+	query := "SELECT id, name, phone, email, address, customer_group, created_at FROM customers WHERE 1 = 1"
+	if name != "" {
+		args = append(args, "%"+name+"%")
+		query += " AND name ILIKE $" + strconv.Itoa(len(args))
+	}
+	if phone != "" {
+		args = append(args, "%"+phone+"%")
+		query += " AND phone LIKE $" + strconv.Itoa(len(args))
+	}
+	if email != "" {
+		args = append(args, "%"+email+"%")
+		query += " AND email ILIKE $" + strconv.Itoa(len(args))
+	}
+	query += " ORDER BY id ASC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []models.Customer
+	for rows.Next() {
+		var customer models.Customer
+		err := rows.Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Email, &customer.Address, &customer.Group, &customer.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+	return customers, nil
+}
+
+func (r *CustomerRepository) Create(customer models.Customer) (models.Customer, error) {
+	customer.Group = strings.ToLower(strings.TrimSpace(customer.Group))
+	query := "INSERT INTO customers (name, phone, email, address, customer_group) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at"
+	row := r.db.QueryRow(query, customer.Name, customer.Phone, customer.Email, customer.Address, customer.Group)
+	err := row.Scan(&customer.ID, &customer.CreatedAt)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	return customer, nil
+}
+
+func (r *CustomerRepository) GetByID(id int) (models.Customer, error) {
+	query := "SELECT id, name, phone, email, address, customer_group, created_at FROM customers WHERE id = $1"
+	row := r.db.QueryRow(query, id)
+	var customer models.Customer
+	err := row.Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Email, &customer.Address, &customer.Group, &customer.CreatedAt)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	return customer, nil
+}
+
+func (r *CustomerRepository) Update(id int, customer models.Customer) (models.Customer, error) {
+	customer.Group = strings.ToLower(strings.TrimSpace(customer.Group))
+	query := "UPDATE customers SET name = $2, phone = $3, email = $4, address = $5, customer_group = $6, updated_at = NOW() WHERE id = $1"
+	result, err := r.db.Exec(query, id, customer.Name, customer.Phone, customer.Email, customer.Address, customer.Group)
+	if err != nil {
+		return models.Customer{}, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return models.Customer{}, err
+	}
+	if rows == 0 {
+		return models.Customer{}, errors.New("customer not found")
+	}
+
+	customer.ID = id
+	return customer, nil
+}
+
+func (r *CustomerRepository) Delete(id int) error {
+	query := "DELETE FROM customers WHERE id = $1"
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("customer not found")
+	}
+	return nil
+}
+
+func (r *CustomerRepository) GetStats(id int) (*models.CustomerStats, error) {
+	stats := models.CustomerStats{CustomerID: id}
+	var firstVisit, lastVisit sql.NullTime
+	err := r.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(total_amount), 0),
+			MIN(created_at),
+			MAX(created_at),
+			COALESCE(EXTRACT(DAY FROM LOCALTIMESTAMP - MAX(created_at)), 0)::int
+		FROM transactions
+		WHERE customer_id = $1
+	`, id).Scan(&stats.TotalTransactions, &stats.LifetimeValue, &firstVisit, &lastVisit, &stats.DaysSinceLastVisit)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.TotalTransactions == 0 {
+		return &stats, nil
+	}
+
+	stats.AverageBasket = stats.LifetimeValue / stats.TotalTransactions
+	stats.FirstVisit = &firstVisit.Time
+	stats.LastVisit = &lastVisit.Time
+
+	activeDays := lastVisit.Time.Sub(firstVisit.Time).Hours() / 24
+	if stats.TotalTransactions > 1 {
+		stats.AverageDaysBetween = activeDays / float64(stats.TotalTransactions-1)
+	}
+	// Customers seen within a single month count as one month of activity.
+	months := activeDays / 30
+	if months < 1 {
+		months = 1
+	}
+	stats.VisitsPerMonth = float64(stats.TotalTransactions) / months
+
+	return &stats, nil
+}
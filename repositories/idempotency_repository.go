@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+
+	"github.com/lib/pq"
+)
+
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// GetByKey returns the stored record for a key that has not expired yet.
+func (r *IdempotencyRepository) GetByKey(key string) (*models.IdempotencyKey, error) {
+	query := `SELECT key, request_hash, COALESCE(status_code, 0), COALESCE(response_body, ''), created_at, expires_at
+	          FROM idempotency_keys
+	          WHERE key = $1 AND expires_at > NOW()`
+	var record models.IdempotencyKey
+	var body string
+	err := r.db.QueryRow(query, key).Scan(
+		&record.Key, &record.RequestHash, &record.StatusCode, &body, &record.CreatedAt, &record.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	record.ResponseBody = []byte(body)
+	return &record, nil
+}
+
+func (r *IdempotencyRepository) DeleteExpired() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM idempotency_keys WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// reserveIdempotencyKey claims the key inside tx. A concurrent request holding
+// the same key blocks here until the first one commits or rolls back.
+func reserveIdempotencyKey(tx *sql.Tx, record *models.IdempotencyKey) error {
+	_, err := tx.Exec("DELETE FROM idempotency_keys WHERE key = $1 AND expires_at <= NOW()", record.Key)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`INSERT INTO idempotency_keys (key, request_hash, expires_at)
+	                   VALUES ($1, $2, NOW() + make_interval(secs => $3))
+	                   RETURNING created_at, expires_at`,
+		record.Key, record.RequestHash, record.TTL.Seconds()).Scan(&record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrIdempotencyKeyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func completeIdempotencyKey(tx *sql.Tx, record *models.IdempotencyKey) error {
+	_, err := tx.Exec("UPDATE idempotency_keys SET status_code = $2, response_body = $3 WHERE key = $1",
+		record.Key, record.StatusCode, string(record.ResponseBody))
+	return err
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+	"math"
+)
+
+type InventoryRepository struct {
+	db       *sql.DB
+	calendar *BusinessCalendar
+}
+
+func NewInventoryRepository(db *sql.DB, calendar *BusinessCalendar) *InventoryRepository {
+	return &InventoryRepository{db: db, calendar: calendar}
+}
+
+// GetLowStock lists products at or below their reorder point. Sales velocity is
+// measured over the last days, and the suggested quantity tops the product up
+// to its target stock or to enough stock for coverDays of sales above the
+// reorder point, whichever is higher, minus what is already on order. A
+// non-nil outletID compares that outlet's stock, sales and orders with the
+// reorder point instead of the totals. A non-nil categoryID only lists
+// products in that category or below it.
+func (r *InventoryRepository) GetLowStock(days int, coverDays int, outletID *int, categoryID *int) ([]models.LowStockItem, error) {
+	items := make([]models.LowStockItem, 0)
+	err := r.EachLowStock(days, coverDays, outletID, categoryID, func(item models.LowStockItem) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// EachLowStock calls fn for every line of the low-stock report as it is read.
+func (r *InventoryRepository) EachLowStock(days int, coverDays int, outletID *int, categoryID *int, fn func(models.LowStockItem) error) error {
+	rows, err := r.db.Query(`
+		SELECT p.id, p.name, p.category_id, st.stock, p.reorder_point, p.target_stock,
+		       COALESCE(o.on_order, 0), COALESCE(s.sold, 0)
+		FROM products p
+		CROSS JOIN LATERAL (`+stockAt("$4")+`) st
+		LEFT JOIN (
+			SELECT td.product_id, SUM(td.quantity) AS sold
+			FROM transaction_details td
+			JOIN transactions t ON t.id = td.transaction_id
+			WHERE t.created_at >= LOCALTIMESTAMP - make_interval(days => $1)
+			  AND ($4::int IS NULL OR t.outlet_id = $4)
+			GROUP BY td.product_id
+		) s ON s.product_id = p.id
+		LEFT JOIN (
+			SELECT i.product_id, SUM(i.quantity_ordered - i.quantity_received) AS on_order
+			FROM purchase_order_items i
+			JOIN purchase_orders po ON po.id = i.purchase_order_id
+			WHERE po.status IN ($2, $3) AND ($4::int IS NULL OR po.outlet_id = $4)
+			GROUP BY i.product_id
+		) o ON o.product_id = p.id
+		WHERE p.reorder_point > 0 AND st.stock <= p.reorder_point
+		  AND `+inCategory("p.category_id", "$5")+`
+		ORDER BY st.stock - p.reorder_point ASC, p.id ASC
+	`, days, models.PurchaseOrderOrdered, models.PurchaseOrderPartiallyReceived, outletID, categoryID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.LowStockItem
+		err := rows.Scan(&item.ProductID, &item.ProductName, &item.CategoryID, &item.Stock,
+			&item.ReorderPoint, &item.TargetStock, &item.OnOrder, &item.SoldInWindow)
+		if err != nil {
+			return err
+		}
+
+		item.AverageDailySales = item.SoldInWindow.Float64() / float64(days)
+		demand := models.Whole(int(math.Ceil(item.AverageDailySales * float64(coverDays))))
+		target := item.ReorderPoint + demand
+		if item.TargetStock > target {
+			target = item.TargetStock
+		}
+		if suggested := target - item.Stock - item.OnOrder; suggested > 0 {
+			item.SuggestedQuantity = suggested
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetBatches lists the batches of a product that still hold stock, in the
+// order stock leaves them. A non-nil outletID only lists that outlet's.
+func (r *InventoryRepository) GetBatches(productID int, outletID *int) ([]models.StockBatch, error) {
+	var exists bool
+	if err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)", productID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("product not found")
+	}
+
+	rows, err := r.db.Query(`SELECT `+stockBatchColumns+`
+	                         FROM stock_batches b
+	                         JOIN products p ON p.id = b.product_id
+	                         WHERE b.product_id = $1 AND b.quantity > 0 AND ($2::int IS NULL OR b.outlet_id = $2)
+	                         ORDER BY b.outlet_id, b.expiry_date ASC NULLS LAST, b.id`, productID, outletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := make([]models.StockBatch, 0)
+	for rows.Next() {
+		batch, err := scanStockBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, rows.Err()
+}
+
+// GetExpiring lists the batches with stock that expire within days from
+// today, including those that already have, soonest first. A non-nil
+// outletID only lists that outlet's, and a non-nil categoryID those of
+// products in that category or below it.
+func (r *InventoryRepository) GetExpiring(days int, outletID *int, categoryID *int) ([]models.ExpiringBatch, error) {
+	batches := make([]models.ExpiringBatch, 0)
+	err := r.EachExpiring(days, outletID, categoryID, func(batch models.ExpiringBatch) error {
+		batches = append(batches, batch)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+// EachExpiring calls fn for every line of the expiring-soon report as it is
+// read.
+func (r *InventoryRepository) EachExpiring(days int, outletID *int, categoryID *int, fn func(models.ExpiringBatch) error) error {
+	today := r.calendar.LocalDate()
+	rows, err := r.db.Query(`SELECT b.id, b.product_id, p.name, b.outlet_id, o.name, b.lot_number, b.expiry_date,
+	                                b.expiry_date - $1::date, b.quantity, p.unit, ROUND(b.quantity * p.cost_price)::bigint
+	                         FROM stock_batches b
+	                         JOIN products p ON p.id = b.product_id
+	                         JOIN outlets o ON o.id = b.outlet_id
+	                         WHERE b.quantity > 0 AND b.expiry_date <= $1::date + $2::int
+	                           AND ($3::int IS NULL OR b.outlet_id = $3)
+	                           AND `+inCategory("p.category_id", "$4")+`
+	                         ORDER BY b.expiry_date ASC, b.id ASC`, today, days, outletID, categoryID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var batch models.ExpiringBatch
+		err := rows.Scan(&batch.BatchID, &batch.ProductID, &batch.ProductName, &batch.OutletID, &batch.OutletName, &batch.LotNumber,
+			&batch.ExpiryDate, &batch.DaysLeft, &batch.Quantity, &batch.Unit, &batch.Value)
+		if err != nil {
+			return err
+		}
+		batch.Expired = batch.DaysLeft < 0
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,220 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+
+	"github.com/lib/pq"
+)
+
+type LoyaltyRepository struct {
+	db     *sql.DB
+	config models.LoyaltyConfig
+}
+
+func NewLoyaltyRepository(db *sql.DB, config models.LoyaltyConfig) *LoyaltyRepository {
+	return &LoyaltyRepository{db: db, config: config}
+}
+
+func (r *LoyaltyRepository) Config() models.LoyaltyConfig {
+	return r.config
+}
+
+// GetBalance expires any overdue points before reading, so the returned
+// balance is always what the customer can redeem right now.
+func (r *LoyaltyRepository) GetBalance(customerID int) (*models.LoyaltyBalance, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockCustomer(tx, customerID); err != nil {
+		return nil, err
+	}
+	if err := r.expirePoints(tx, customerID); err != nil {
+		return nil, err
+	}
+	balance, err := r.balance(tx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT id, customer_id, transaction_id, entry_type, points, remaining_points, expires_at, created_at
+	                       FROM loyalty_ledger
+	                       WHERE customer_id = $1
+	                       ORDER BY created_at DESC, id DESC`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]models.LoyaltyEntry, 0)
+	for rows.Next() {
+		var entry models.LoyaltyEntry
+		var transactionID sql.NullInt64
+		var expiresAt sql.NullTime
+		err := rows.Scan(&entry.ID, &entry.CustomerID, &transactionID, &entry.EntryType, &entry.Points,
+			&entry.RemainingPoints, &expiresAt, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if transactionID.Valid {
+			id := int(transactionID.Int64)
+			entry.TransactionID = &id
+		}
+		if expiresAt.Valid {
+			entry.ExpiresAt = &expiresAt.Time
+		}
+		history = append(history, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &models.LoyaltyBalance{
+		CustomerID: customerID,
+		Balance:    balance,
+		Value:      balance * r.config.PointValue,
+		History:    history,
+	}, nil
+}
+
+// PointsFor returns the points earned on an eligible spend.
+func (r *LoyaltyRepository) PointsFor(amount int) int {
+	if r.config.EarnAmount <= 0 || amount <= 0 {
+		return 0
+	}
+	return amount / r.config.EarnAmount * r.config.EarnPoints
+}
+
+// excludedCategories returns the categories whose products earn no points:
+// the configured ones and every category below them.
+func (r *LoyaltyRepository) excludedCategories(tx *sql.Tx) (map[int]bool, error) {
+	if len(r.config.ExcludedCategoryIDs) == 0 {
+		return nil, nil
+	}
+	ids := make([]int64, len(r.config.ExcludedCategoryIDs))
+	for i, id := range r.config.ExcludedCategoryIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := tx.Query(categorySubtrees("$1"), pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	excluded := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		excluded[id] = true
+	}
+	return excluded, rows.Err()
+}
+
+// lockCustomer serializes all ledger writes for one customer and doubles as
+// the existence check.
+func lockCustomer(tx *sql.Tx, customerID int) error {
+	var id int
+	err := tx.QueryRow("SELECT id FROM customers WHERE id = $1 FOR UPDATE", customerID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return errors.New("Customer not found")
+	}
+	return err
+}
+
+func (r *LoyaltyRepository) expirePoints(tx *sql.Tx, customerID int) error {
+	var expired int
+	err := tx.QueryRow(`SELECT COALESCE(SUM(remaining_points), 0)
+	                    FROM loyalty_ledger
+	                    WHERE customer_id = $1 AND entry_type = $2 AND remaining_points > 0 AND expires_at <= LOCALTIMESTAMP`,
+		customerID, models.LoyaltyEntryEarn).Scan(&expired)
+	if err != nil || expired == 0 {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE loyalty_ledger SET remaining_points = 0
+	                  WHERE customer_id = $1 AND entry_type = $2 AND remaining_points > 0 AND expires_at <= LOCALTIMESTAMP`,
+		customerID, models.LoyaltyEntryEarn)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO loyalty_ledger (customer_id, entry_type, points) VALUES ($1, $2, $3)",
+		customerID, models.LoyaltyEntryExpire, -expired)
+	return err
+}
+
+func (r *LoyaltyRepository) balance(tx *sql.Tx, customerID int) (int, error) {
+	var balance int
+	err := tx.QueryRow("SELECT COALESCE(SUM(points), 0) FROM loyalty_ledger WHERE customer_id = $1", customerID).Scan(&balance)
+	return balance, err
+}
+
+func (r *LoyaltyRepository) earn(tx *sql.Tx, customerID int, transactionID int, points int) error {
+	if points <= 0 {
+		return nil
+	}
+	// With ExpiryDays disabled the points never expire.
+	query := `INSERT INTO loyalty_ledger (customer_id, transaction_id, entry_type, points, remaining_points, expires_at)
+	          VALUES ($1, $2, $3, $4, $4, CASE WHEN $5::int > 0 THEN LOCALTIMESTAMP + make_interval(days => $5::int) END)`
+	_, err := tx.Exec(query, customerID, transactionID, models.LoyaltyEntryEarn, points, r.config.ExpiryDays)
+	return err
+}
+
+// redeem spends points from the earn entries that expire first.
+func (r *LoyaltyRepository) redeem(tx *sql.Tx, customerID int, transactionID int, points int) error {
+	rows, err := tx.Query(`SELECT id, remaining_points
+	                       FROM loyalty_ledger
+	                       WHERE customer_id = $1 AND entry_type = $2 AND remaining_points > 0
+	                       ORDER BY expires_at ASC NULLS LAST, id ASC
+	                       FOR UPDATE`, customerID, models.LoyaltyEntryEarn)
+	if err != nil {
+		return err
+	}
+	type lot struct{ id, remaining int }
+	var lots []lot
+	for rows.Next() {
+		var l lot
+		if err := rows.Scan(&l.id, &l.remaining); err != nil {
+			rows.Close()
+			return err
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	left := points
+	for _, l := range lots {
+		if left == 0 {
+			break
+		}
+		take := l.remaining
+		if take > left {
+			take = left
+		}
+		if _, err := tx.Exec("UPDATE loyalty_ledger SET remaining_points = remaining_points - $2 WHERE id = $1", l.id, take); err != nil {
+			return err
+		}
+		left -= take
+	}
+	if left > 0 {
+		return errors.New("Insufficient loyalty points")
+	}
+
+	_, err = tx.Exec("INSERT INTO loyalty_ledger (customer_id, transaction_id, entry_type, points) VALUES ($1, $2, $3, $4)",
+		customerID, transactionID, models.LoyaltyEntryRedeem, -points)
+	return err
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import "go-kasir-api/models"
+
+// roundAmount rounds an amount in thousandths of the currency, such as a
+// price times a decimal quantity, half up to a multiple of unit.
+func roundAmount(amount int64, unit int) int {
+	if unit < 1 {
+		unit = 1
+	}
+	step := int64(unit) * models.QuantityScale
+	if amount < 0 {
+		return -roundAmount(-amount, unit)
+	}
+	return int((amount+step/2)/step) * unit
+}
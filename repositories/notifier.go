@@ -0,0 +1,9 @@
+package repositories
+
+import "go-kasir-api/models"
+
+// LowStockNotifier receives an event whenever a checkout takes a product to or
+// below its reorder point.
+type LowStockNotifier interface {
+	NotifyLowStock(event models.LowStockEvent) error
+}
@@ -0,0 +1,243 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+)
+
+type OutletRepository struct {
+	db *sql.DB
+}
+
+func NewOutletRepository(db *sql.DB) *OutletRepository {
+	return &OutletRepository{db: db}
+}
+
+const outletColumns = "id, name, address, is_default, active, created_at"
+
+func scanOutlet(row rowScanner) (models.Outlet, error) {
+	var outlet models.Outlet
+	err := row.Scan(&outlet.ID, &outlet.Name, &outlet.Address, &outlet.IsDefault, &outlet.Active, &outlet.CreatedAt)
+	return outlet, err
+}
+
+func (r *OutletRepository) GetAll() ([]models.Outlet, error) {
+	rows, err := r.db.Query("SELECT " + outletColumns + " FROM outlets ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	outlets := make([]models.Outlet, 0)
+	for rows.Next() {
+		outlet, err := scanOutlet(rows)
+		if err != nil {
+			return nil, err
+		}
+		outlets = append(outlets, outlet)
+	}
+	return outlets, rows.Err()
+}
+
+func (r *OutletRepository) GetByID(id int) (models.Outlet, error) {
+	outlet, err := scanOutlet(r.db.QueryRow("SELECT "+outletColumns+" FROM outlets WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return models.Outlet{}, errors.New("outlet not found")
+	}
+	if err != nil {
+		return models.Outlet{}, err
+	}
+	return outlet, nil
+}
+
+// Create adds an outlet. The first outlet always becomes the default.
+func (r *OutletRepository) Create(outlet models.Outlet) (models.Outlet, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Outlet{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("LOCK TABLE outlets IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return models.Outlet{}, err
+	}
+	var hasDefault bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM outlets WHERE is_default)").Scan(&hasDefault); err != nil {
+		return models.Outlet{}, err
+	}
+	if !hasDefault {
+		outlet.IsDefault = true
+	}
+	if outlet.IsDefault {
+		if !outlet.Active {
+			return models.Outlet{}, errors.New("the default outlet must be active")
+		}
+		if _, err := tx.Exec("UPDATE outlets SET is_default = FALSE, updated_at = NOW() WHERE is_default"); err != nil {
+			return models.Outlet{}, err
+		}
+	}
+
+	err = tx.QueryRow("INSERT INTO outlets (name, address, is_default, active) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		outlet.Name, outlet.Address, outlet.IsDefault, outlet.Active).Scan(&outlet.ID, &outlet.CreatedAt)
+	if err != nil {
+		return models.Outlet{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Outlet{}, err
+	}
+	return outlet, nil
+}
+
+// Update changes an outlet. Making an outlet the default takes the flag from
+// the previous default; the default itself cannot be unset or deactivated.
+func (r *OutletRepository) Update(id int, outlet models.Outlet) (models.Outlet, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Outlet{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("LOCK TABLE outlets IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return models.Outlet{}, err
+	}
+	current, err := scanOutlet(tx.QueryRow("SELECT "+outletColumns+" FROM outlets WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return models.Outlet{}, errors.New("outlet not found")
+	}
+	if err != nil {
+		return models.Outlet{}, err
+	}
+	if current.IsDefault && !outlet.IsDefault {
+		return models.Outlet{}, errors.New("make another outlet the default instead")
+	}
+	if outlet.IsDefault && !outlet.Active {
+		return models.Outlet{}, errors.New("the default outlet must be active")
+	}
+	if outlet.IsDefault && !current.IsDefault {
+		if _, err := tx.Exec("UPDATE outlets SET is_default = FALSE, updated_at = NOW() WHERE is_default"); err != nil {
+			return models.Outlet{}, err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE outlets SET name = $2, address = $3, is_default = $4, active = $5, updated_at = NOW() WHERE id = $1",
+		id, outlet.Name, outlet.Address, outlet.IsDefault, outlet.Active)
+	if err != nil {
+		return models.Outlet{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Outlet{}, err
+	}
+
+	outlet.ID = id
+	outlet.CreatedAt = current.CreatedAt
+	return outlet, nil
+}
+
+// Delete only removes an outlet that has never traded and holds no stock.
+// Deactivate the others instead so their history stays in the reports.
+func (r *OutletRepository) Delete(id int) error {
+	outlet, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if outlet.IsDefault {
+		return errors.New("the default outlet cannot be deleted")
+	}
+
+	var used bool
+	err = r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM transactions WHERE outlet_id = $1)
+	                         OR EXISTS (SELECT 1 FROM outlet_stock WHERE outlet_id = $1 AND stock <> 0)
+	                         OR EXISTS (SELECT 1 FROM purchase_orders WHERE outlet_id = $1)
+	                         OR EXISTS (SELECT 1 FROM stocktakes WHERE outlet_id = $1)
+	                         OR EXISTS (SELECT 1 FROM stock_transfers WHERE source_outlet_id = $1 OR destination_outlet_id = $1)
+	                         OR EXISTS (SELECT 1 FROM cashier_shifts WHERE outlet_id = $1)`, id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return errors.New("outlet has history and cannot be deleted, deactivate it instead")
+	}
+
+	_, err = r.db.Exec("DELETE FROM outlets WHERE id = $1", id)
+	return err
+}
+
+// GetProducts lists every sellable product with its stock and selling price
+// at an outlet. Products the outlet has never stocked show zero stock, and a
+// bundle shows how many its components' stock at the outlet can make;
+// listings, which are sold through their variants, are left out.
+func (r *OutletRepository) GetProducts(id int) ([]models.OutletProduct, error) {
+	if _, err := r.GetByID(id); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT p.id, p.name, p.category_id,
+	                                COALESCE((SELECT MIN(FLOOR(COALESCE(cos.stock, 0) / bc.quantity))
+	                                          FROM bundle_components bc
+	                                          LEFT JOIN outlet_stock cos ON cos.product_id = bc.product_id AND cos.outlet_id = $1
+	                                          WHERE bc.bundle_id = p.id), os.stock, 0),
+	                                p.price, os.price
+	                         FROM products p
+	                         LEFT JOIN outlet_stock os ON os.product_id = p.id AND os.outlet_id = $1
+	                         WHERE p.options = '[]'::jsonb
+	                         ORDER BY p.id ASC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]models.OutletProduct, 0)
+	for rows.Next() {
+		var product models.OutletProduct
+		var override sql.NullInt64
+		err := rows.Scan(&product.ProductID, &product.ProductName, &product.CategoryID, &product.Stock, &product.BasePrice, &override)
+		if err != nil {
+			return nil, err
+		}
+		product.Price = product.BasePrice
+		if override.Valid {
+			price := int(override.Int64)
+			product.PriceOverride = &price
+			product.Price = price
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+// SetPrice overrides the price of a product at an outlet. A nil price goes
+// back to the product's base price.
+func (r *OutletRepository) SetPrice(id int, productID int, price *int) (*models.OutletProduct, error) {
+	if price != nil && *price < 0 {
+		return nil, errors.New("Price cannot be negative")
+	}
+	if _, err := r.GetByID(id); err != nil {
+		return nil, err
+	}
+
+	var product models.OutletProduct
+	err := r.db.QueryRow("SELECT id, name, category_id, price FROM products WHERE id = $1", productID).
+		Scan(&product.ProductID, &product.ProductName, &product.CategoryID, &product.BasePrice)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("product not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.QueryRow(`INSERT INTO outlet_stock (outlet_id, product_id, stock, price)
+	                     VALUES ($1, $2, 0, $3)
+	                     ON CONFLICT (outlet_id, product_id) DO UPDATE SET price = EXCLUDED.price, updated_at = NOW()
+	                     RETURNING stock`, id, productID, price).Scan(&product.Stock)
+	if err != nil {
+		return nil, err
+	}
+
+	product.PriceOverride = price
+	product.Price = product.BasePrice
+	if price != nil {
+		product.Price = *price
+	}
+	return &product, nil
+}
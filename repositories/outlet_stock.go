@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+
+	"github.com/lib/pq"
+)
+
+// Stock is kept per outlet in outlet_stock, and products.stock holds the total
+// across all outlets. Both are only written while the product's row is locked,
+// so that lock guards the stock of the product at every outlet.
+
+// defaultOutletID returns the outlet that takes stock and sales not assigned
+// to a particular outlet.
+func defaultOutletID(tx *sql.Tx) (int, error) {
+	var id int
+	err := tx.QueryRow("SELECT id FROM outlets WHERE is_default").Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("No default outlet is configured")
+	}
+	return id, err
+}
+
+// resolveOutlet checks that an outlet exists and is active. A nil outletID
+// means the default outlet.
+func resolveOutlet(tx *sql.Tx, outletID *int) (int, error) {
+	if outletID == nil {
+		return defaultOutletID(tx)
+	}
+	var active bool
+	err := tx.QueryRow("SELECT active FROM outlets WHERE id = $1", *outletID).Scan(&active)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("Outlet not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !active {
+		return 0, errors.New("Outlet is inactive")
+	}
+	return *outletID, nil
+}
+
+// stockAt returns a subquery, for use in a lateral join on products p, that
+// yields the stock column: the total when the outlet placeholder is NULL and
+// the stock at that outlet otherwise.
+func stockAt(outlet string) string {
+	return `SELECT CASE WHEN ` + outlet + `::int IS NULL THEN p.stock
+	                    ELSE COALESCE((SELECT os.stock FROM outlet_stock os WHERE os.outlet_id = ` + outlet + ` AND os.product_id = p.id), 0)
+	               END AS stock`
+}
+
+// outletProduct is a product's stock and price override at one outlet.
+type outletProduct struct {
+	stock models.Quantity
+	price *int
+}
+
+// outletProducts returns the stock and price overrides of products at an
+// outlet. Products the outlet has never stocked or priced are left out.
+func outletProducts(tx *sql.Tx, outletID int, productIDs []int64) (map[int]outletProduct, error) {
+	rows, err := tx.Query("SELECT product_id, stock, price FROM outlet_stock WHERE outlet_id = $1 AND product_id = ANY($2)",
+		outletID, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make(map[int]outletProduct, len(productIDs))
+	for rows.Next() {
+		var productID int
+		var product outletProduct
+		var price sql.NullInt64
+		if err := rows.Scan(&productID, &product.stock, &price); err != nil {
+			return nil, err
+		}
+		if price.Valid {
+			p := int(price.Int64)
+			product.price = &p
+		}
+		products[productID] = product
+	}
+	return products, rows.Err()
+}
+
+// adjustOutletStock adds signed deltas to the stock of products at an outlet
+// and to their totals. Stock taken away leaves the outlet's batches first;
+// the batches it left are returned. The caller must hold the products' row
+// locks.
+func adjustOutletStock(tx *sql.Tx, outletID int, productIDs []int64, deltas []models.Quantity) ([]batchTake, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+	if err := checkStockPrecision(tx, productIDs, deltas); err != nil {
+		return nil, err
+	}
+
+	// Listings hold no stock of their own; their variants do. Neither do
+	// bundles, whose stock is that of their components.
+	result, err := tx.Exec(`UPDATE products p SET stock = p.stock + v.delta, updated_at = NOW()
+	                        FROM unnest($1::int[], $2::numeric[]) AS v(product_id, delta)
+	                        WHERE p.id = v.product_id AND p.options = '[]'::jsonb
+	                          AND NOT EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.bundle_id = p.id)`, pq.Array(productIDs), pq.Array(deltas))
+	if err != nil {
+		return nil, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated != int64(len(productIDs)) {
+		return nil, errors.New("Products with variants and bundles have no stock of their own; use a variant or the bundle's components")
+	}
+
+	_, err = tx.Exec(`INSERT INTO outlet_stock (outlet_id, product_id, stock)
+	                   SELECT $1, v.product_id, v.delta
+	                   FROM unnest($2::int[], $3::numeric[]) AS v(product_id, delta)
+	                   ON CONFLICT (outlet_id, product_id)
+	                   DO UPDATE SET stock = outlet_stock.stock + EXCLUDED.stock, updated_at = NOW()`,
+		outletID, pq.Array(productIDs), pq.Array(deltas))
+	if err != nil {
+		return nil, err
+	}
+
+	var taken []int64
+	for i, delta := range deltas {
+		if delta < 0 {
+			taken = append(taken, productIDs[i])
+		}
+	}
+	if len(taken) == 0 {
+		return nil, nil
+	}
+	batches, err := outletBatches(tx, outletID, taken)
+	if err != nil {
+		return nil, err
+	}
+	var takes []batchTake
+	for i, delta := range deltas {
+		if delta < 0 {
+			takes = append(takes, takeBatches(batches[int(productIDs[i])], -delta, nil)...)
+		}
+	}
+	if err := saveBatchTakes(tx, takes); err != nil {
+		return nil, err
+	}
+	return takes, nil
+}
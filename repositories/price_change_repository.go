@@ -0,0 +1,274 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PriceChangeRepository struct {
+	db       *sql.DB
+	calendar *BusinessCalendar
+}
+
+func NewPriceChangeRepository(db *sql.DB, calendar *BusinessCalendar) *PriceChangeRepository {
+	return &PriceChangeRepository{db: db, calendar: calendar}
+}
+
+const priceChangeColumns = `c.id, c.product_id, p.name, c.price, c.effective_at, c.status, c.created_by, c.created_at, c.applied_at`
+
+func scanPriceChange(row rowScanner) (models.PriceChange, error) {
+	var change models.PriceChange
+	err := row.Scan(&change.ID, &change.ProductID, &change.ProductName, &change.Price, &change.EffectiveAt, &change.Status,
+		&change.CreatedBy, &change.CreatedAt, &change.AppliedAt)
+	return change, err
+}
+
+// GetAll lists price changes by when they take effect, optionally only those
+// of one product or in one status.
+func (r *PriceChangeRepository) GetAll(productID *int, status string) ([]models.PriceChange, error) {
+	rows, err := r.db.Query(`SELECT `+priceChangeColumns+`
+	                         FROM scheduled_price_changes c
+	                         JOIN products p ON p.id = c.product_id
+	                         WHERE ($1::int IS NULL OR c.product_id = $1) AND ($2 = '' OR c.status = $2)
+	                         ORDER BY c.effective_at ASC, c.id ASC`, productID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]models.PriceChange, 0)
+	for rows.Next() {
+		change, err := scanPriceChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *PriceChangeRepository) GetByID(id int) (models.PriceChange, error) {
+	change, err := scanPriceChange(r.db.QueryRow(`SELECT `+priceChangeColumns+`
+	                                              FROM scheduled_price_changes c
+	                                              JOIN products p ON p.id = c.product_id
+	                                              WHERE c.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return models.PriceChange{}, errors.New("price change not found")
+	}
+	return change, err
+}
+
+// Create schedules a price change. It must take effect in the future; a price
+// that applies now is set with PUT /api/products/{id}.
+func (r *PriceChangeRepository) Create(change models.PriceChange) (models.PriceChange, error) {
+	if change.Price < 0 {
+		return models.PriceChange{}, errors.New("Price cannot be negative")
+	}
+	if change.EffectiveAt.IsZero() {
+		return models.PriceChange{}, errors.New("effective_at is required")
+	}
+	if !change.EffectiveAt.After(time.Now()) {
+		return models.PriceChange{}, errors.New("effective_at must be in the future")
+	}
+
+	var listing bool
+	err := r.db.QueryRow("SELECT options <> '[]'::jsonb FROM products WHERE id = $1", change.ProductID).Scan(&listing)
+	if err == sql.ErrNoRows {
+		return models.PriceChange{}, errors.New("Product not found")
+	}
+	if err != nil {
+		return models.PriceChange{}, err
+	}
+	if listing {
+		return models.PriceChange{}, errors.New("Product has variants; schedule the change for each variant")
+	}
+
+	// effective_at keeps its offset, so the change falls due at that instant
+	// whatever timezone the database session is in.
+	var id int
+	err = r.db.QueryRow(`INSERT INTO scheduled_price_changes (product_id, price, effective_at, status, created_by)
+	                     VALUES ($1, $2, $3::timestamptz, $4, $5) RETURNING id`,
+		change.ProductID, change.Price, change.EffectiveAt, models.PriceChangeScheduled, strings.TrimSpace(change.CreatedBy)).Scan(&id)
+	if err != nil {
+		return models.PriceChange{}, err
+	}
+	return r.GetByID(id)
+}
+
+// Cancel withdraws a price change that has not been applied yet.
+func (r *PriceChangeRepository) Cancel(id int) (models.PriceChange, error) {
+	result, err := r.db.Exec("UPDATE scheduled_price_changes SET status = $2 WHERE id = $1 AND status = $3",
+		id, models.PriceChangeCancelled, models.PriceChangeScheduled)
+	if err != nil {
+		return models.PriceChange{}, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return models.PriceChange{}, err
+	}
+	if rows == 0 {
+		change, err := r.GetByID(id)
+		if err != nil {
+			return models.PriceChange{}, err
+		}
+		return models.PriceChange{}, errors.New("Price change is " + change.Status + " and cannot be cancelled")
+	}
+	return r.GetByID(id)
+}
+
+// ApplyDue applies every scheduled price change whose time has come and
+// returns how many it applied.
+func (r *PriceChangeRepository) ApplyDue() (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Prices only change while their product is locked, so a sale never sees
+	// a price change half applied.
+	rows, err := tx.Query(`SELECT id FROM products
+	                       WHERE id IN (SELECT product_id FROM scheduled_price_changes WHERE status = $1 AND effective_at <= NOW())
+	                       ORDER BY id FOR UPDATE`, models.PriceChangeScheduled)
+	if err != nil {
+		return 0, err
+	}
+	var productIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		productIDs = append(productIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(productIDs) == 0 {
+		return 0, nil
+	}
+
+	_, applied, err := applyDuePriceChanges(tx, productIDs)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return applied, nil
+}
+
+// GetHistory lists the changes of a product's base price, newest first. With
+// a business date it lists only those in force at some point that day: the
+// price the day began with and any changes during it.
+func (r *PriceChangeRepository) GetHistory(productID int, date *time.Time) ([]models.PriceHistoryEntry, error) {
+	var exists bool
+	if err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)", productID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("product not found")
+	}
+
+	var from, to *time.Time
+	if date != nil {
+		start, end := r.calendar.Bounds(*date, *date)
+		from, to = &start, &end
+	}
+	rows, err := r.db.Query(`SELECT id, product_id, old_price, price, source, price_change_id, changed_by, changed_at
+	                         FROM product_price_history
+	                         WHERE product_id = $1
+	                           AND ($3::timestamptz IS NULL OR changed_at < $3)
+	                           AND ($2::timestamptz IS NULL OR changed_at >= $2
+	                                OR id = (SELECT id FROM product_price_history
+	                                         WHERE product_id = $1 AND changed_at < $2
+	                                         ORDER BY changed_at DESC, id DESC LIMIT 1))
+	                         ORDER BY changed_at DESC, id DESC`, productID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]models.PriceHistoryEntry, 0)
+	for rows.Next() {
+		var entry models.PriceHistoryEntry
+		err := rows.Scan(&entry.ID, &entry.ProductID, &entry.OldPrice, &entry.Price, &entry.Source, &entry.PriceChangeID,
+			&entry.ChangedBy, &entry.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// recordPriceChange adds a change of a product's base price to its history.
+// changedAt defaults to now.
+func recordPriceChange(tx *sql.Tx, productID int, oldPrice *int, price int, source string, changedBy string, changeID *int, changedAt *time.Time) error {
+	_, err := tx.Exec(`INSERT INTO product_price_history (product_id, old_price, price, source, price_change_id, changed_by, changed_at)
+	                   VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7::timestamptz, NOW()))`,
+		productID, oldPrice, price, source, changeID, strings.TrimSpace(changedBy), changedAt)
+	return err
+}
+
+// applyDuePriceChanges applies the scheduled price changes of products whose
+// time has come, oldest first, and returns the new prices by product and how
+// many changes it applied. The products must already be locked. Each change
+// is dated in the history by when it was due, not when it was applied.
+func applyDuePriceChanges(tx *sql.Tx, productIDs []int64) (map[int]int, int, error) {
+	rows, err := tx.Query(`SELECT id, product_id, price, effective_at, created_by
+	                       FROM scheduled_price_changes
+	                       WHERE status = $1 AND effective_at <= NOW() AND product_id = ANY($2)
+	                       ORDER BY product_id, effective_at, id
+	                       FOR UPDATE`, models.PriceChangeScheduled, pq.Array(productIDs))
+	if err != nil {
+		return nil, 0, err
+	}
+	var changes []models.PriceChange
+	for rows.Next() {
+		var change models.PriceChange
+		if err := rows.Scan(&change.ID, &change.ProductID, &change.Price, &change.EffectiveAt, &change.CreatedBy); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		changes = append(changes, change)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	prices := make(map[int]int)
+	for _, change := range changes {
+		var oldPrice int
+		err := tx.QueryRow(`UPDATE products p SET price = $2, updated_at = NOW()
+		                    FROM products old
+		                    WHERE p.id = $1 AND old.id = p.id
+		                    RETURNING old.price`, change.ProductID, change.Price).Scan(&oldPrice)
+		if err != nil {
+			return nil, 0, err
+		}
+		if oldPrice != change.Price {
+			changeID, effectiveAt := change.ID, change.EffectiveAt
+			err := recordPriceChange(tx, change.ProductID, &oldPrice, change.Price, models.PriceSourceScheduled,
+				change.CreatedBy, &changeID, &effectiveAt)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		_, err = tx.Exec("UPDATE scheduled_price_changes SET status = $2, applied_at = NOW() WHERE id = $1",
+			change.ID, models.PriceChangeApplied)
+		if err != nil {
+			return nil, 0, err
+		}
+		prices[change.ProductID] = change.Price
+	}
+	return prices, len(changes), nil
+}
@@ -0,0 +1,304 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-kasir-api/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PriceListRepository struct {
+	db *sql.DB
+}
+
+func NewPriceListRepository(db *sql.DB) *PriceListRepository {
+	return &PriceListRepository{db: db}
+}
+
+const priceListColumns = `pl.id, pl.name, pl.customer_group, pl.valid_from, pl.valid_to, pl.active, pl.created_at,
+	COALESCE((SELECT json_agg(json_build_object('product_id', pi.product_id, 'product_name', p.name,
+	                                            'min_quantity', pi.min_quantity, 'price', pi.price)
+	                          ORDER BY pi.product_id, pi.min_quantity)
+	          FROM price_list_items pi
+	          JOIN products p ON p.id = pi.product_id
+	          WHERE pi.price_list_id = pl.id), '[]')`
+
+func scanPriceList(row rowScanner) (models.PriceList, error) {
+	var list models.PriceList
+	var items []byte
+	err := row.Scan(&list.ID, &list.Name, &list.CustomerGroup, &list.ValidFrom, &list.ValidTo, &list.Active, &list.CreatedAt, &items)
+	if err != nil {
+		return models.PriceList{}, err
+	}
+	if err := json.Unmarshal(items, &list.Items); err != nil {
+		return models.PriceList{}, err
+	}
+	return list, nil
+}
+
+func (r *PriceListRepository) GetAll() ([]models.PriceList, error) {
+	rows, err := r.db.Query("SELECT " + priceListColumns + " FROM price_lists pl ORDER BY pl.id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := make([]models.PriceList, 0)
+	for rows.Next() {
+		list, err := scanPriceList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
+
+func (r *PriceListRepository) GetByID(id int) (models.PriceList, error) {
+	list, err := scanPriceList(r.db.QueryRow("SELECT "+priceListColumns+" FROM price_lists pl WHERE pl.id = $1", id))
+	if err == sql.ErrNoRows {
+		return models.PriceList{}, errors.New("price list not found")
+	}
+	return list, err
+}
+
+func (r *PriceListRepository) Create(list models.PriceList) (models.PriceList, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.PriceList{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkPriceList(tx, &list); err != nil {
+		return models.PriceList{}, err
+	}
+	err = tx.QueryRow(`INSERT INTO price_lists (name, customer_group, valid_from, valid_to, active)
+	                   VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		list.Name, list.CustomerGroup, list.ValidFrom, list.ValidTo, list.Active).Scan(&list.ID, &list.CreatedAt)
+	if err != nil {
+		return models.PriceList{}, err
+	}
+	if err := savePriceListItems(tx, list.ID, list.Items); err != nil {
+		return models.PriceList{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.PriceList{}, err
+	}
+	return r.GetByID(list.ID)
+}
+
+// Update replaces a price list along with all of its items.
+func (r *PriceListRepository) Update(id int, list models.PriceList) (models.PriceList, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.PriceList{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkPriceList(tx, &list); err != nil {
+		return models.PriceList{}, err
+	}
+	result, err := tx.Exec(`UPDATE price_lists SET name = $2, customer_group = $3, valid_from = $4, valid_to = $5, active = $6, updated_at = NOW()
+	                        WHERE id = $1`, id, list.Name, list.CustomerGroup, list.ValidFrom, list.ValidTo, list.Active)
+	if err != nil {
+		return models.PriceList{}, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return models.PriceList{}, err
+	}
+	if rows == 0 {
+		return models.PriceList{}, errors.New("price list not found")
+	}
+	if err := savePriceListItems(tx, id, list.Items); err != nil {
+		return models.PriceList{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.PriceList{}, err
+	}
+	return r.GetByID(id)
+}
+
+// Delete removes a price list that no sale has used. A list that priced a
+// sale stays for the record and is deactivated instead.
+func (r *PriceListRepository) Delete(id int) error {
+	var used bool
+	err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM transactions WHERE price_list_id = $1)", id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return errors.New("price list is used in sales and cannot be deleted; deactivate it instead")
+	}
+
+	result, err := r.db.Exec("DELETE FROM price_lists WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("price list not found")
+	}
+	return nil
+}
+
+// checkPriceList validates a price list and normalises its name and customer
+// group the way customers store them.
+func checkPriceList(tx *sql.Tx, list *models.PriceList) error {
+	list.Name = strings.TrimSpace(list.Name)
+	list.CustomerGroup = strings.ToLower(strings.TrimSpace(list.CustomerGroup))
+	if list.Name == "" {
+		return errors.New("Price list name is required")
+	}
+	if list.ValidFrom != nil && list.ValidTo != nil && list.ValidTo.Before(*list.ValidFrom) {
+		return errors.New("valid_to cannot be before valid_from")
+	}
+
+	type tierKey struct {
+		productID   int
+		minQuantity models.Quantity
+	}
+	seen := make(map[tierKey]bool, len(list.Items))
+	for _, item := range list.Items {
+		key := tierKey{item.ProductID, item.MinQuantity}
+		if seen[key] {
+			return errors.New("A product is listed more than once with the same min_quantity")
+		}
+		seen[key] = true
+
+		var name, unit string
+		var precision int
+		err := tx.QueryRow(`SELECT p.name, p.unit, u.precision FROM products p JOIN units u ON u.code = p.unit WHERE p.id = $1`,
+			item.ProductID).Scan(&name, &unit, &precision)
+		if err == sql.ErrNoRows {
+			return errors.New("Product not found")
+		}
+		if err != nil {
+			return err
+		}
+		if item.MinQuantity < 0 {
+			return errors.New("min_quantity of " + name + " cannot be negative")
+		}
+		if !item.MinQuantity.FitsPrecision(precision) {
+			return precisionError(name, unit, precision)
+		}
+		if item.Price < 0 {
+			return errors.New("Price cannot be negative")
+		}
+	}
+	return nil
+}
+
+// savePriceListItems replaces the items of a price list.
+func savePriceListItems(tx *sql.Tx, listID int, items []models.PriceListItem) error {
+	if _, err := tx.Exec("DELETE FROM price_list_items WHERE price_list_id = $1", listID); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	productIDs := make([]int64, len(items))
+	minQuantities := make([]models.Quantity, len(items))
+	prices := make([]int64, len(items))
+	for i, item := range items {
+		productIDs[i] = int64(item.ProductID)
+		minQuantities[i] = item.MinQuantity
+		prices[i] = int64(item.Price)
+	}
+	_, err := tx.Exec(`INSERT INTO price_list_items (price_list_id, product_id, min_quantity, price)
+	                   SELECT $1, v.product_id, v.min_quantity, v.price
+	                   FROM unnest($2::int[], $3::numeric[], $4::int[]) AS v(product_id, min_quantity, price)`,
+		listID, pq.Array(productIDs), pq.Array(minQuantities), pq.Array(prices))
+	return err
+}
+
+// checkoutPriceList picks the price list a sale is priced with on the
+// business date today: the one the sale selects, or else the customer
+// group's list that started most recently. It returns nil when no list
+// applies.
+func checkoutPriceList(tx *sql.Tx, listID *int, customerID *int, today time.Time) (*int, error) {
+	if listID != nil {
+		var active, valid bool
+		err := tx.QueryRow(`SELECT active, (valid_from IS NULL OR valid_from <= $2::date) AND (valid_to IS NULL OR valid_to >= $2::date)
+		                    FROM price_lists WHERE id = $1`, *listID, today).Scan(&active, &valid)
+		if err == sql.ErrNoRows {
+			return nil, errors.New("Price list not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, errors.New("Price list is not active")
+		}
+		if !valid {
+			return nil, errors.New("Price list is not valid today")
+		}
+		return listID, nil
+	}
+	if customerID == nil {
+		return nil, nil
+	}
+
+	var id int
+	err := tx.QueryRow(`SELECT pl.id FROM price_lists pl
+	                    JOIN customers c ON c.customer_group = pl.customer_group
+	                    WHERE c.id = $1 AND pl.customer_group <> '' AND pl.active
+	                      AND (pl.valid_from IS NULL OR pl.valid_from <= $2::date)
+	                      AND (pl.valid_to IS NULL OR pl.valid_to >= $2::date)
+	                    ORDER BY pl.valid_from DESC NULLS LAST, pl.id DESC
+	                    LIMIT 1`, *customerID, today).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// priceTier is a price that applies from a quantity on.
+type priceTier struct {
+	minQuantity models.Quantity
+	price       int
+}
+
+// priceListTiers returns a price list's tiers for products, keyed by
+// product and ordered by min_quantity.
+func priceListTiers(tx *sql.Tx, listID int, productIDs []int64) (map[int][]priceTier, error) {
+	rows, err := tx.Query(`SELECT product_id, min_quantity, price FROM price_list_items
+	                       WHERE price_list_id = $1 AND product_id = ANY($2)
+	                       ORDER BY product_id, min_quantity`, listID, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := make(map[int][]priceTier)
+	for rows.Next() {
+		var productID int
+		var tier priceTier
+		if err := rows.Scan(&productID, &tier.minQuantity, &tier.price); err != nil {
+			return nil, err
+		}
+		tiers[productID] = append(tiers[productID], tier)
+	}
+	return tiers, rows.Err()
+}
+
+// tierPrice returns the price of the highest tier a quantity reaches. ok is
+// false when it reaches none.
+func tierPrice(tiers []priceTier, quantity models.Quantity) (int, bool) {
+	i := sort.Search(len(tiers), func(i int) bool { return tiers[i].minQuantity > quantity })
+	if i == 0 {
+		return 0, false
+	}
+	return tiers[i-1].price, true
+}
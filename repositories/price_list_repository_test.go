@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"go-kasir-api/models"
+	"testing"
+)
+
+func TestTierPrice(t *testing.T) {
+	tiers := []priceTier{
+		{minQuantity: models.Whole(0), price: 9000},
+		{minQuantity: models.Whole(12), price: 8500},
+		{minQuantity: models.Whole(48), price: 8000},
+	}
+	tests := []struct {
+		tiers    []priceTier
+		quantity models.Quantity
+		want     int
+		ok       bool
+	}{
+		{tiers, models.Whole(1), 9000, true},
+		{tiers, models.Whole(12), 8500, true},
+		{tiers, models.Whole(47), 8500, true},
+		{tiers, models.Whole(100), 8000, true},
+		{tiers[1:], models.Whole(5), 0, false},
+		{tiers[1:], models.Whole(12) + 1, 8500, true},
+		{nil, models.Whole(3), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := tierPrice(tt.tiers, tt.quantity)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("tierPrice(%v, %s) = %d, %v, want %d, %v", tt.tiers, tt.quantity, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+)
+
+const productImageColumns = `product_id, url, thumbnail_url, content_type, size_bytes, width, height, uploaded_at, storage_key, thumbnail_key`
+
+func scanProductImage(row rowScanner) (models.ProductImage, error) {
+	var image models.ProductImage
+	err := row.Scan(&image.ProductID, &image.URL, &image.ThumbnailURL, &image.ContentType, &image.Size, &image.Width, &image.Height,
+		&image.UploadedAt, &image.Key, &image.ThumbnailKey)
+	return image, err
+}
+
+// SetImage records the uploaded image of a product, whose files are already
+// stored, and returns the image it replaces, if any, so the caller can remove
+// the old files.
+func (r *ProductRepository) SetImage(image models.ProductImage) (models.ProductImage, *models.ProductImage, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.ProductImage{}, nil, err
+	}
+	defer tx.Rollback()
+
+	// The product is locked so a concurrent upload or delete waits for this
+	// one and sees its files.
+	var id int
+	err = tx.QueryRow("SELECT id FROM products WHERE id = $1 FOR UPDATE", image.ProductID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return models.ProductImage{}, nil, errors.New("product not found")
+	}
+	if err != nil {
+		return models.ProductImage{}, nil, err
+	}
+	old, err := lockProductImage(tx, image.ProductID)
+	if err != nil {
+		return models.ProductImage{}, nil, err
+	}
+
+	saved, err := scanProductImage(tx.QueryRow(`
+		INSERT INTO product_images (product_id, url, thumbnail_url, content_type, size_bytes, width, height, storage_key, thumbnail_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (product_id) DO UPDATE
+		SET url = EXCLUDED.url, thumbnail_url = EXCLUDED.thumbnail_url, content_type = EXCLUDED.content_type,
+		    size_bytes = EXCLUDED.size_bytes, width = EXCLUDED.width, height = EXCLUDED.height,
+		    storage_key = EXCLUDED.storage_key, thumbnail_key = EXCLUDED.thumbnail_key, uploaded_at = CURRENT_TIMESTAMP
+		RETURNING `+productImageColumns,
+		image.ProductID, image.URL, image.ThumbnailURL, image.ContentType, image.Size, image.Width, image.Height, image.Key, image.ThumbnailKey))
+	if err != nil {
+		return models.ProductImage{}, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.ProductImage{}, nil, err
+	}
+	return saved, old, nil
+}
+
+// RemoveImage forgets the image of a product and returns it so the caller can
+// remove its files.
+func (r *ProductRepository) RemoveImage(productID int) (models.ProductImage, error) {
+	image, err := scanProductImage(r.db.QueryRow("DELETE FROM product_images WHERE product_id = $1 RETURNING "+productImageColumns, productID))
+	if err == sql.ErrNoRows {
+		return models.ProductImage{}, errors.New("product has no image")
+	}
+	return image, err
+}
+
+// lockProductImage returns the image of a product, locked until the end of
+// tx, or nil if it has none.
+func lockProductImage(tx *sql.Tx, productID int) (*models.ProductImage, error) {
+	image, err := scanProductImage(tx.QueryRow("SELECT "+productImageColumns+" FROM product_images WHERE product_id = $1 FOR UPDATE", productID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
@@ -0,0 +1,335 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"go-kasir-api/models"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Import upserts products. A row with an ID updates that product; otherwise it
+// updates the product with the same name, ignoring case, or creates one.
+// Categories are matched by name and created when missing. Stock is the total
+// across outlets; changes to it are applied at the default outlet.
+//
+// With chunkSize 0 the import runs in one transaction that is only committed
+// when every row succeeds. Otherwise each chunk of rows commits on its own and
+// failing rows are left out. A dry run does all the work and rolls it back.
+// Each row runs under a savepoint, so a row the database rejects is reported
+// without aborting the rows around it. Price changes are recorded in the
+// price history with changedBy.
+func (r *ProductRepository) Import(rows []models.ProductImportRow, dryRun bool, chunkSize int, changedBy string) (*models.ProductImportSummary, error) {
+	summary := &models.ProductImportSummary{
+		DryRun:            dryRun,
+		ChunkSize:         chunkSize,
+		TotalRows:         len(rows),
+		CategoriesCreated: make([]string, 0),
+		Errors:            make([]models.ProductImportError, 0),
+	}
+	atomic := chunkSize <= 0
+	if atomic {
+		chunkSize = len(rows)
+	}
+
+	state := &productImport{summary: summary, seen: make(map[string]int), newCategories: make(map[string]bool), changedBy: changedBy}
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		commit := !dryRun && (!atomic || len(summary.Errors) == 0)
+		committed, err := r.importChunk(state, rows[start:end], commit && atomic, commit)
+		if err != nil {
+			return nil, err
+		}
+		summary.Committed = summary.Committed || committed
+	}
+	return summary, nil
+}
+
+// productImport carries state across the chunks of one import.
+type productImport struct {
+	summary *models.ProductImportSummary
+	// seen maps each product key to the first row that used it, so a file
+	// cannot change the same product twice.
+	seen          map[string]int
+	newCategories map[string]bool
+	changedBy     string
+}
+
+func (s *productImport) fail(row int, column string, format string, args ...interface{}) {
+	s.summary.Errors = append(s.summary.Errors, models.ProductImportError{Row: row, Column: column, Message: fmt.Sprintf(format, args...)})
+	s.summary.Failed++
+}
+
+// importChunk imports rows in one transaction. When allOrNothing is set the
+// transaction is only committed if no row failed; otherwise commit decides.
+func (r *ProductRepository) importChunk(state *productImport, rows []models.ProductImportRow, allOrNothing bool, commit bool) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	categories, err := loadCategoryIDs(tx)
+	if err != nil {
+		return false, err
+	}
+	byID, byName, err := lockImportMatches(tx, rows)
+	if err != nil {
+		return false, err
+	}
+
+	for _, row := range rows {
+		if err := importProductRow(tx, state, row, categories, byID, byName); err != nil {
+			return false, err
+		}
+	}
+
+	if !commit || (allOrNothing && len(state.summary.Errors) > 0) {
+		return false, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// loadCategoryIDs maps lowercased category names to IDs. When names repeat
+// the oldest category wins.
+func loadCategoryIDs(tx *sql.Tx) (map[string]int, error) {
+	rows, err := tx.Query("SELECT id, name FROM categories ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(strings.TrimSpace(name))
+		if _, ok := categories[key]; !ok {
+			categories[key] = id
+		}
+	}
+	return categories, rows.Err()
+}
+
+// lockImportMatches locks every existing product a chunk could update, in ID
+// order, and indexes them by ID and by lowercased name. Price changes that
+// fell due since the scheduler last ran are applied first, so the import
+// compares against and records over the price in effect.
+func lockImportMatches(tx *sql.Tx, rows []models.ProductImportRow) (map[int]models.Product, map[string][]models.Product, error) {
+	ids := make([]int64, 0, len(rows))
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.ID != nil {
+			ids = append(ids, int64(*row.ID))
+		} else {
+			names = append(names, strings.ToLower(row.Name))
+		}
+	}
+
+	result, err := tx.Query(`SELECT id, name, price, cost_price, stock, reorder_point, target_stock, category_id
+	                         FROM products
+	                         WHERE id = ANY($1) OR LOWER(name) = ANY($2)
+	                         ORDER BY id
+	                         FOR UPDATE`, pq.Array(ids), pq.Array(names))
+	if err != nil {
+		return nil, nil, err
+	}
+	var products []models.Product
+	var lockedIDs []int64
+	for result.Next() {
+		var p models.Product
+		err := result.Scan(&p.ID, &p.Name, &p.Price, &p.CostPrice, &p.Stock, &p.ReorderPoint, &p.TargetStock, &p.CategoryID)
+		if err != nil {
+			result.Close()
+			return nil, nil, err
+		}
+		products = append(products, p)
+		lockedIDs = append(lockedIDs, int64(p.ID))
+	}
+	result.Close()
+	if err := result.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	prices, _, err := applyDuePriceChanges(tx, lockedIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[int]models.Product)
+	byName := make(map[string][]models.Product)
+	for _, p := range products {
+		if price, ok := prices[p.ID]; ok {
+			p.Price = price
+		}
+		byID[p.ID] = p
+		key := strings.ToLower(p.Name)
+		byName[key] = append(byName[key], p)
+	}
+	return byID, byName, nil
+}
+
+// importProductRow validates and writes one row. Problems with the row are
+// recorded in the summary; only failures of the transaction itself are
+// returned.
+func importProductRow(tx *sql.Tx, state *productImport, row models.ProductImportRow, categories map[string]int, byID map[int]models.Product, byName map[string][]models.Product) error {
+	var existing *models.Product
+	key := "name:" + strings.ToLower(row.Name)
+	if row.ID != nil {
+		p, ok := byID[*row.ID]
+		if !ok {
+			state.fail(row.Row, "id", "product %d not found", *row.ID)
+			return nil
+		}
+		existing = &p
+		key = fmt.Sprintf("id:%d", p.ID)
+	} else {
+		matches := byName[strings.ToLower(row.Name)]
+		if len(matches) > 1 {
+			state.fail(row.Row, "name", "%d products are named %q; add an id column to choose one", len(matches), row.Name)
+			return nil
+		}
+		if len(matches) == 1 {
+			existing = &matches[0]
+			key = fmt.Sprintf("id:%d", existing.ID)
+		}
+	}
+	if first, ok := state.seen[key]; ok {
+		state.fail(row.Row, "", "same product as row %d", first)
+		return nil
+	}
+	state.seen[key] = row.Row
+
+	product := models.Product{}
+	if existing != nil {
+		product = *existing
+	} else {
+		if row.Category == "" {
+			state.fail(row.Row, "category", "category is required for a new product")
+			return nil
+		}
+		if row.Price == nil {
+			state.fail(row.Row, "price", "price is required for a new product")
+			return nil
+		}
+	}
+	if row.Name != "" {
+		product.Name = row.Name
+	}
+	for _, field := range []struct {
+		value  *int
+		target *int
+	}{
+		{row.Price, &product.Price},
+		{row.CostPrice, &product.CostPrice},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+		}
+	}
+	for _, field := range []struct {
+		value  *models.Quantity
+		target *models.Quantity
+	}{
+		{row.Stock, &product.Stock},
+		{row.ReorderPoint, &product.ReorderPoint},
+		{row.TargetStock, &product.TargetStock},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+		}
+	}
+
+	if _, err := tx.Exec("SAVEPOINT import_row"); err != nil {
+		return err
+	}
+	createdCategory := ""
+	err := func() error {
+		if row.Category != "" {
+			categoryKey := strings.ToLower(row.Category)
+			id, ok := categories[categoryKey]
+			if !ok {
+				err := tx.QueryRow("INSERT INTO categories (name, description) VALUES ($1, '') RETURNING id", row.Category).Scan(&id)
+				if err != nil {
+					return err
+				}
+				categories[categoryKey] = id
+				createdCategory = categoryKey
+			}
+			product.CategoryID = id
+		}
+
+		if existing == nil {
+			err := tx.QueryRow(`INSERT INTO products (name, price, cost_price, stock, reorder_point, target_stock, category_id)
+			                    VALUES ($1, $2, $3, 0, $4, $5, $6) RETURNING id`,
+				product.Name, product.Price, product.CostPrice, product.ReorderPoint, product.TargetStock, product.CategoryID).Scan(&product.ID)
+			if err != nil {
+				return err
+			}
+			if err := recordPriceChange(tx, product.ID, nil, product.Price, models.PriceSourceImported, state.changedBy, nil, nil); err != nil {
+				return err
+			}
+			return setDefaultOutletStock(tx, product.ID, 0, product.Stock, models.StockMovementImport, state.changedBy)
+		}
+		if importFieldsEqual(product, *existing) {
+			return nil
+		}
+		_, err := tx.Exec(`UPDATE products
+		                   SET name = $2, price = $3, cost_price = $4, reorder_point = $5, target_stock = $6, category_id = $7, updated_at = NOW()
+		                   WHERE id = $1`,
+			product.ID, product.Name, product.Price, product.CostPrice, product.ReorderPoint, product.TargetStock, product.CategoryID)
+		if err != nil {
+			return err
+		}
+		if product.Price != existing.Price {
+			err := recordPriceChange(tx, product.ID, &existing.Price, product.Price, models.PriceSourceImported, state.changedBy, nil, nil)
+			if err != nil {
+				return err
+			}
+		}
+		return setDefaultOutletStock(tx, product.ID, existing.Stock, product.Stock, models.StockMovementImport, state.changedBy)
+	}()
+	if err != nil {
+		if _, rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT import_row"); rollbackErr != nil {
+			return rollbackErr
+		}
+		if createdCategory != "" {
+			delete(categories, createdCategory)
+		}
+		state.fail(row.Row, "", "%v", err)
+		return nil
+	}
+	if _, err := tx.Exec("RELEASE SAVEPOINT import_row"); err != nil {
+		return err
+	}
+
+	if createdCategory != "" && !state.newCategories[createdCategory] {
+		state.newCategories[createdCategory] = true
+		state.summary.CategoriesCreated = append(state.summary.CategoriesCreated, row.Category)
+	}
+	switch {
+	case existing == nil:
+		state.summary.Created++
+	case importFieldsEqual(product, *existing):
+		state.summary.Skipped++
+	default:
+		state.summary.Updated++
+	}
+	return nil
+}
+
+// importFieldsEqual reports whether an import row leaves a product as it was.
+// Only the columns an import can set are compared.
+func importFieldsEqual(a models.Product, b models.Product) bool {
+	return a.Name == b.Name && a.Price == b.Price && a.CostPrice == b.CostPrice && a.Stock == b.Stock &&
+		a.ReorderPoint == b.ReorderPoint && a.TargetStock == b.TargetStock && a.CategoryID == b.CategoryID
+}
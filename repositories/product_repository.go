@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"go-kasir-api/models"
 )
@@ -14,105 +15,449 @@ func NewProductRepository(db *sql.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) GetAll(name string) ([]models.Product, error) {
-	args := []interface{}{}
-	query := `SELECT p.id, p.name, p.price, p.stock, p.category_id, 
-	          c.id, c.name, c.description 
-	          FROM products p 
-	          JOIN categories c ON p.category_id = c.id`
+const productColumns = `p.id, p.parent_id, p.name, COALESCE(p.sku, ''), COALESCE(p.barcode, ''), p.price, p.cost_price,
+	` + bundleStockColumn + `, p.reorder_point, p.target_stock, p.unit, ` + productUnitsColumn + `,
+	p.category_id, p.options, p.option_values, ` + bundleComponentsColumn + `, c.id, c.parent_id, c.name, c.description,
+	COALESCE(pi.url, ''), COALESCE(pi.thumbnail_url, '')`
+
+const productFrom = `
+	FROM products p
+	JOIN categories c ON p.category_id = c.id
+	LEFT JOIN product_images pi ON pi.product_id = p.id`
+
+func scanProduct(row rowScanner) (models.Product, error) {
+	var product models.Product
+	var category models.Category
+	var units, options, values, components []byte
+	err := row.Scan(
+		&product.ID, &product.ParentID, &product.Name, &product.SKU, &product.Barcode, &product.Price, &product.CostPrice, &product.Stock,
+		&product.ReorderPoint, &product.TargetStock, &product.Unit, &units, &product.CategoryID, &options, &values, &components,
+		&category.ID, &category.ParentID, &category.Name, &category.Description, &product.ImageURL, &product.ThumbnailURL,
+	)
+	if err != nil {
+		return models.Product{}, err
+	}
+	if err := json.Unmarshal(units, &product.Units); err != nil {
+		return models.Product{}, err
+	}
+	if err := json.Unmarshal(options, &product.Options); err != nil {
+		return models.Product{}, err
+	}
+	if err := json.Unmarshal(values, &product.OptionValues); err != nil {
+		return models.Product{}, err
+	}
+	if err := json.Unmarshal(components, &product.Components); err != nil {
+		return models.Product{}, err
+	}
+	product.Category = &category
+	return product, nil
+}
+
+func (r *ProductRepository) GetAll(name string, categoryID *int) ([]models.Product, error) {
+	var products []models.Product
+	err := r.EachProduct(name, categoryID, func(product models.Product) error {
+		products = append(products, product)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// EachProduct calls fn for every product matching name as it is read, so
+// large exports do not hold the whole list in memory. name also finds a
+// product by its exact SKU or barcode. A non-nil categoryID limits the list
+// to that category and its subcategories.
+func (r *ProductRepository) EachProduct(name string, categoryID *int, fn func(models.Product) error) error {
+	args := []interface{}{categoryID}
+	query := "SELECT " + productColumns + productFrom + " WHERE " + inCategory("p.category_id", "$1")
 	if name != "" {
-		query += " WHERE p.name ILIKE $1"
-		args = append(args, "%"+name+"%")
+		query += " AND (p.name ILIKE $2 OR p.sku = $3 OR p.barcode = $3)"
+		args = append(args, "%"+name+"%", name)
 	}
 	query += " ORDER BY p.id ASC"
 	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetVariants lists the variants of a listing.
+func (r *ProductRepository) GetVariants(id int) ([]models.Product, error) {
+	rows, err := r.db.Query("SELECT "+productColumns+productFrom+" WHERE p.parent_id = $1 ORDER BY p.id ASC", id)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var products []models.Product
+	variants := make([]models.Product, 0)
 	for rows.Next() {
-		var product models.Product
-		var category models.Category
-		err := rows.Scan(
-			&product.ID, &product.Name, &product.Price, &product.Stock, &product.CategoryID,
-			&category.ID, &category.Name, &category.Description,
-		)
+		variant, err := scanProduct(rows)
 		if err != nil {
 			return nil, err
 		}
-		product.Category = &category
-		products = append(products, product)
+		variants = append(variants, variant)
 	}
-	return products, nil
+	return variants, rows.Err()
 }
 
-func (r *ProductRepository) Create(product models.Product) (models.Product, error) {
-	query := "INSERT INTO products (name, price, stock, category_id) VALUES ($1, $2, $3, $4) RETURNING id"
-	row := r.db.QueryRow(query, product.Name, product.Price, product.Stock, product.CategoryID)
-	var id int
-	err := row.Scan(&id)
+// Create adds a product. Its opening stock is placed at the default outlet. A
+// listing can be created together with its variants. changedBy is recorded
+// in the price history.
+func (r *ProductRepository) Create(product models.Product, changedBy string) (models.Product, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return models.Product{}, err
 	}
-	product.ID = id
-	return product, nil
+	defer tx.Rollback()
+
+	id, err := insertProduct(tx, product, changedBy)
+	if err != nil {
+		return models.Product{}, err
+	}
+	for _, variant := range product.Variants {
+		variant.ParentID = &id
+		if _, err := insertProduct(tx, variant, changedBy); err != nil {
+			return models.Product{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Product{}, err
+	}
+	return r.GetByID(id)
 }
 
+// insertProduct validates and inserts one product. A variant takes its
+// listing's category when it has none of its own, and a bundle's stock comes
+// from its components.
+func insertProduct(tx *sql.Tx, product models.Product, changedBy string) (int, error) {
+	if product.ParentID != nil {
+		parent, err := lockProductParent(tx, *product.ParentID)
+		if err != nil {
+			return 0, err
+		}
+		if len(product.Variants) > 0 {
+			return 0, errors.New("A variant cannot have variants of its own")
+		}
+		if err := checkVariant(tx, parent, *product.ParentID, product, 0); err != nil {
+			return 0, err
+		}
+		if product.CategoryID == 0 {
+			product.CategoryID = parent.categoryID
+		}
+	} else if err := checkListing(product); err != nil {
+		return 0, err
+	}
+	if err := checkBundle(tx, 0, product, 0); err != nil {
+		return 0, err
+	}
+	if len(product.Components) > 0 {
+		product.Stock = 0
+	}
+	if err := checkProductCodes(tx, 0, product.SKU, product.Barcode); err != nil {
+		return 0, err
+	}
+	unit, err := checkProductUnits(tx, product)
+	if err != nil {
+		return 0, err
+	}
+
+	options, values, err := marshalProductOptions(product)
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = tx.QueryRow(`INSERT INTO products (parent_id, name, sku, barcode, price, cost_price, stock, reorder_point, target_stock, unit, category_id, options, option_values)
+	                   VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, 0, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		product.ParentID, product.Name, product.SKU, product.Barcode, product.Price, product.CostPrice,
+		product.ReorderPoint, product.TargetStock, unit, product.CategoryID, options, values).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := saveProductUnits(tx, id, product.Units); err != nil {
+		return 0, err
+	}
+	if err := saveBundleComponents(tx, id, product.Components); err != nil {
+		return 0, err
+	}
+	if err := setDefaultOutletStock(tx, id, 0, product.Stock, models.StockMovementManualAdjustment, changedBy); err != nil {
+		return 0, err
+	}
+	if err := recordPriceChange(tx, id, nil, product.Price, models.PriceSourceCreated, changedBy, nil, nil); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// checkListing validates a product that is not a variant. With options it is
+// a listing, which holds no stock of its own.
+func checkListing(product models.Product) error {
+	if len(product.OptionValues) > 0 {
+		return errors.New("option_values are only for variants; set parent_id")
+	}
+	if len(product.Options) == 0 {
+		if len(product.Variants) > 0 {
+			return errors.New("A product with variants needs options")
+		}
+		return nil
+	}
+	if err := validateProductOptions(product.Options); err != nil {
+		return err
+	}
+	if product.Stock != 0 {
+		return errors.New("A product with variants has no stock of its own; set stock on its variants")
+	}
+	return nil
+}
+
+func marshalProductOptions(product models.Product) (string, string, error) {
+	options := product.Options
+	if options == nil {
+		options = []models.ProductOption{}
+	}
+	values := product.OptionValues
+	if values == nil {
+		values = map[string]string{}
+	}
+	o, err := json.Marshal(options)
+	if err != nil {
+		return "", "", err
+	}
+	v, err := json.Marshal(values)
+	if err != nil {
+		return "", "", err
+	}
+	return string(o), string(v), nil
+}
+
+// setDefaultOutletStock moves a product's total stock from current to stock
+// by adjusting its stock at the default outlet, and records the change as a
+// stock movement of movementType made by changedBy.
+func setDefaultOutletStock(tx *sql.Tx, productID int, current models.Quantity, stock models.Quantity, movementType string, changedBy string) error {
+	if stock == current {
+		return nil
+	}
+	outletID, err := defaultOutletID(tx)
+	if err != nil {
+		return err
+	}
+	if _, err := adjustOutletStock(tx, outletID, []int64{int64(productID)}, []models.Quantity{stock - current}); err != nil {
+		return err
+	}
+	return recordStockMovements(tx, []models.StockMovement{{
+		ProductID:     productID,
+		OutletID:      outletID,
+		Quantity:      stock - current,
+		MovementType:  movementType,
+		ReferenceType: "product",
+		ReferenceID:   productID,
+		ChangedBy:     changedBy,
+	}})
+}
+
+// GetByID returns a product. A listing comes with its variants.
 func (r *ProductRepository) GetByID(id int) (models.Product, error) {
-	query := `SELECT p.id, p.name, p.price, p.stock, p.category_id,
-	          c.id, c.name, c.description
-	          FROM products p
-	          JOIN categories c ON p.category_id = c.id
-	          WHERE p.id = $1`
-	row := r.db.QueryRow(query, id)
-	var product models.Product
-	var category models.Category
-	err := row.Scan(
-		&product.ID, &product.Name, &product.Price, &product.Stock, &product.CategoryID,
-		&category.ID, &category.Name, &category.Description,
-	)
+	product, err := scanProduct(r.db.QueryRow("SELECT "+productColumns+productFrom+" WHERE p.id = $1", id))
 	if err != nil {
 		return models.Product{}, err
 	}
-	product.Category = &category
+	if len(product.Options) > 0 {
+		product.Variants, err = r.GetVariants(id)
+		if err != nil {
+			return models.Product{}, err
+		}
+	}
 	return product, nil
 }
 
-func (r *ProductRepository) Update(id int, product models.Product) (models.Product, error) {
-	query := "UPDATE products SET name = $2, price = $3, stock = $4, category_id = $5, updated_at = NOW() WHERE id = $1 RETURNING id"
-	result, err := r.db.Exec(query, id, product.Name, product.Price, product.Stock, product.CategoryID)
+// Update changes a product. Stock is the total across outlets; any change to
+// it is applied at the default outlet. parent_id cannot be changed, so a
+// variant stays with its listing, and the unit cannot be changed once stock
+// has moved in it. The stock of a bundle comes from its components and is
+// left alone. A new price is recorded in the price history with changedBy.
+func (r *ProductRepository) Update(id int, product models.Product, changedBy string) (models.Product, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return models.Product{}, err
 	}
+	defer tx.Rollback()
 
-	rows, err := result.RowsAffected()
+	var parentID *int
+	err = tx.QueryRow("SELECT parent_id FROM products WHERE id = $1", id).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return models.Product{}, errors.New("product not found")
+	}
 	if err != nil {
 		return models.Product{}, err
 	}
-	if rows == 0 {
-		return models.Product{}, errors.New("product not found")
+	product.ParentID = parentID
+
+	// A listing is always older than its variants, so locking it first keeps
+	// the locks in ID order.
+	var parent productParent
+	if parentID != nil {
+		if parent, err = lockProductParent(tx, *parentID); err != nil {
+			return models.Product{}, err
+		}
+	}
+	var current models.Quantity
+	var currentUnit string
+	var currentPrice int
+	var bundle bool
+	err = tx.QueryRow(`SELECT stock, unit, price, EXISTS (SELECT 1 FROM bundle_components WHERE bundle_id = products.id)
+	                   FROM products WHERE id = $1 FOR UPDATE`, id).Scan(&current, &currentUnit, &currentPrice, &bundle)
+	if err != nil {
+		return models.Product{}, err
+	}
+	// A price change that fell due since the scheduler last ran takes effect
+	// first, so the history records this update over the price it replaced.
+	prices, _, err := applyDuePriceChanges(tx, []int64{int64(id)})
+	if err != nil {
+		return models.Product{}, err
+	}
+	if price, ok := prices[id]; ok {
+		currentPrice = price
 	}
 
-	return product, nil
-}
+	if parentID != nil {
+		if err := checkVariant(tx, parent, *parentID, product, id); err != nil {
+			return models.Product{}, err
+		}
+		if product.CategoryID == 0 {
+			product.CategoryID = parent.categoryID
+		}
+	} else {
+		if len(product.Variants) > 0 {
+			return models.Product{}, errors.New("Add variants with POST /api/products/{id}/variants")
+		}
+		if err := checkListing(product); err != nil {
+			return models.Product{}, err
+		}
+		variants, err := checkListingVariants(tx, id, product.Options)
+		if err != nil {
+			return models.Product{}, err
+		}
+		if variants > 0 && len(product.Options) == 0 {
+			return models.Product{}, errors.New("A product with variants needs options")
+		}
+		if len(product.Options) > 0 && current != 0 {
+			return models.Product{}, errors.New("Product still has stock; move it to a variant before adding options")
+		}
+	}
+	if err := checkBundle(tx, id, product, current); err != nil {
+		return models.Product{}, err
+	}
+	if bundle || len(product.Components) > 0 {
+		product.Stock = current
+	}
+	if err := checkProductCodes(tx, id, product.SKU, product.Barcode); err != nil {
+		return models.Product{}, err
+	}
+	unit, err := checkProductUnits(tx, product)
+	if err != nil {
+		return models.Product{}, err
+	}
+	if unit != currentUnit {
+		var moved bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM stock_movements WHERE product_id = $1)", id).Scan(&moved)
+		if err != nil {
+			return models.Product{}, err
+		}
+		if moved || current != 0 {
+			return models.Product{}, errors.New("Product has stock history in " + currentUnit + "; its unit cannot be changed")
+		}
+	}
 
-func (r *ProductRepository) Delete(id int) error {
-	query := "DELETE FROM products WHERE id = $1"
-	result, err := r.db.Exec(query, id)
+	options, values, err := marshalProductOptions(product)
 	if err != nil {
-		return err
+		return models.Product{}, err
+	}
+	_, err = tx.Exec(`UPDATE products SET name = $2, sku = NULLIF($3, ''), barcode = NULLIF($4, ''), price = $5, cost_price = $6,
+	                  reorder_point = $7, target_stock = $8, unit = $9, category_id = $10, options = $11, option_values = $12, updated_at = NOW()
+	                  WHERE id = $1`,
+		id, product.Name, product.SKU, product.Barcode, product.Price, product.CostPrice,
+		product.ReorderPoint, product.TargetStock, unit, product.CategoryID, options, values)
+	if err != nil {
+		return models.Product{}, err
+	}
+	if err := saveProductUnits(tx, id, product.Units); err != nil {
+		return models.Product{}, err
+	}
+	if err := saveBundleComponents(tx, id, product.Components); err != nil {
+		return models.Product{}, err
+	}
+	if err := setDefaultOutletStock(tx, id, current, product.Stock, models.StockMovementManualAdjustment, changedBy); err != nil {
+		return models.Product{}, err
+	}
+	if product.Price != currentPrice {
+		err := recordPriceChange(tx, id, &currentPrice, product.Price, models.PriceSourceUpdated, changedBy, nil, nil)
+		if err != nil {
+			return models.Product{}, err
+		}
 	}
+	if err := tx.Commit(); err != nil {
+		return models.Product{}, err
+	}
+
+	return r.GetByID(id)
+}
 
-	rows, err := result.RowsAffected()
+// Delete removes a product. A listing can only be deleted once its variants
+// are gone, and a product only once no bundle contains it. The product's
+// image, if it had one, is returned so the caller can remove the files once
+// the product is gone.
+func (r *ProductRepository) Delete(id int) (*models.ProductImage, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	if rows == 0 {
-		return errors.New("product not found")
+	// Locked in the same order as SetImage: the product, then its image.
+	err = tx.QueryRow("SELECT id FROM products WHERE id = $1 FOR UPDATE", id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("product not found")
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	// Adding a variant or a bundle component needs a key share lock on this
+	// row, so none can appear between these checks and the delete.
+	var variants, component bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM products WHERE parent_id = $1),
+	                          EXISTS (SELECT 1 FROM bundle_components WHERE product_id = $1)`, id).Scan(&variants, &component)
+	if err != nil {
+		return nil, err
+	}
+	if variants {
+		return nil, errors.New("product has variants and cannot be deleted, delete its variants first")
+	}
+	if component {
+		return nil, errors.New("product is part of a bundle and cannot be deleted, remove it from the bundle first")
+	}
+	image, err := lockProductImage(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM products WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return image, nil
 }
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"go-kasir-api/models"
+	"strings"
+	"unicode"
+)
+
+// productSearch finds and ranks products for a search. $1 is the search text
+// and $2 the prefix query built by prefixQuery. Each way a product can match
+// has its own branch in candidates so every branch can use its index:
+//
+//   - an exact SKU, barcode or pack barcode scores 3;
+//   - every search term starting a word of the name, SKU or barcode scores
+//     between 1 and 2, higher the closer the name is to the search;
+//   - a name within trigram distance of the search, which catches typos such
+//     as "cocacola", scores its similarity, up to 1;
+//   - a product in a matching category, or below one, scores half the
+//     category's similarity.
+//
+// A product takes the best of its scores.
+const productSearch = `
+	WITH RECURSIVE matched_categories AS (
+		SELECT id, GREATEST(similarity(name, $1), word_similarity($1, name),
+		                    CASE WHEN to_tsvector('simple', name) @@ to_tsquery('simple', $2) THEN 1 ELSE 0 END) AS score
+		FROM categories
+		WHERE name % $1 OR $1 <% name OR to_tsvector('simple', name) @@ to_tsquery('simple', $2)
+		UNION
+		SELECT c.id, m.score FROM categories c JOIN matched_categories m ON c.parent_id = m.id
+	),
+	category_scores AS (
+		SELECT id, MAX(score) AS score FROM matched_categories GROUP BY id
+	),
+	candidates AS (
+		SELECT id FROM products WHERE sku = $1 OR barcode = $1
+		UNION
+		SELECT product_id FROM product_units WHERE barcode = $1
+		UNION
+		SELECT id FROM products WHERE search_vector @@ to_tsquery('simple', $2)
+		UNION
+		SELECT id FROM products WHERE name % $1 OR $1 <% name
+		UNION
+		SELECT p.id FROM products p JOIN category_scores cs ON cs.id = p.category_id
+	),
+	scored AS (
+		SELECT p.id, GREATEST(
+			CASE WHEN p.sku = $1 OR p.barcode = $1 OR EXISTS (SELECT 1 FROM product_units u WHERE u.product_id = p.id AND u.barcode = $1)
+			     THEN 3 ELSE 0 END,
+			CASE WHEN p.search_vector @@ to_tsquery('simple', $2) THEN 1 + similarity(p.name, $1) ELSE 0 END,
+			similarity(p.name, $1),
+			word_similarity($1, p.name),
+			COALESCE(cs.score, 0) / 2
+		) AS score
+		FROM candidates m
+		JOIN products p ON p.id = m.id
+		LEFT JOIN category_scores cs ON cs.id = p.category_id
+	)`
+
+// Search ranks the products matching text by how well they match, best first.
+// A non-nil categoryID only searches that category and its subcategories.
+func (r *ProductRepository) Search(text string, categoryID *int, limit int) ([]models.ProductSearchResult, error) {
+	rows, err := r.db.Query(productSearch+`
+		SELECT `+productColumns+`, s.score`+productFrom+`
+		JOIN scored s ON s.id = p.id
+		WHERE `+inCategory("p.category_id", "$3")+`
+		ORDER BY s.score DESC, p.name ASC, p.id ASC
+		LIMIT $4`, text, prefixQuery(text), categoryID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]models.ProductSearchResult, 0)
+	for rows.Next() {
+		var score float64
+		product, err := scanProduct(scoredRow{rows, &score})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, models.ProductSearchResult{Product: product, Score: score})
+	}
+	return results, rows.Err()
+}
+
+// scoredRow scans a product row followed by its score.
+type scoredRow struct {
+	row   rowScanner
+	score *float64
+}
+
+func (s scoredRow) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.score)...)
+}
+
+// prefixQuery turns search text into a tsquery that matches when every term
+// starts a word, such as "coca:* & col:*" for "Coca Col". Terms are runs of
+// letters and digits, so punctuation cannot break the query syntax. It
+// returns nil when the text has no terms.
+func prefixQuery(text string) interface{} {
+	terms := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) == 0 {
+		return nil
+	}
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,22 @@
+package repositories
+
+import "testing"
+
+func TestPrefixQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want interface{}
+	}{
+		{"coca", "coca:*"},
+		{"Coca Col", "coca:* & col:*"},
+		{"  BEV-COKE-330 ", "bev:* & coke:* & 330:*"},
+		{"kopi & susu:*", "kopi:* & susu:*"},
+		{"!(|)", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := prefixQuery(tt.text); got != tt.want {
+			t.Errorf("prefixQuery(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-kasir-api/models"
+	"strings"
+)
+
+// validateProductOptions checks a listing's options: each needs a name and at
+// least one value, and neither names nor an option's values may repeat.
+func validateProductOptions(options []models.ProductOption) error {
+	names := make(map[string]bool, len(options))
+	for _, option := range options {
+		name := strings.ToLower(strings.TrimSpace(option.Name))
+		if name == "" {
+			return errors.New("Option name is required")
+		}
+		if names[name] {
+			return errors.New("Option " + option.Name + " is listed more than once")
+		}
+		names[name] = true
+
+		if len(option.Values) == 0 {
+			return errors.New("Option " + option.Name + " needs at least one value")
+		}
+		values := make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			key := strings.ToLower(strings.TrimSpace(value))
+			if key == "" {
+				return errors.New("Option " + option.Name + " has an empty value")
+			}
+			if values[key] {
+				return errors.New("Option " + option.Name + " lists " + value + " more than once")
+			}
+			values[key] = true
+		}
+	}
+	return nil
+}
+
+// checkOptionValues checks that a variant picks exactly one of the allowed
+// values for every option of its listing, and nothing else.
+func checkOptionValues(options []models.ProductOption, values map[string]string) error {
+	if len(values) != len(options) {
+		return errors.New("A variant needs a value for each option of its product")
+	}
+	for _, option := range options {
+		value, ok := values[option.Name]
+		if !ok {
+			return errors.New("Variant is missing a value for option " + option.Name)
+		}
+		allowed := false
+		for _, v := range option.Values {
+			if v == value {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errors.New(value + " is not a value of option " + option.Name)
+		}
+	}
+	return nil
+}
+
+// productParent is what a variant needs to know about its listing.
+type productParent struct {
+	parentID   *int
+	categoryID int
+	options    []models.ProductOption
+}
+
+// lockProductParent locks a listing so its options and the set of its
+// variants cannot change while a variant is written.
+func lockProductParent(tx *sql.Tx, id int) (productParent, error) {
+	var parent productParent
+	var options []byte
+	err := tx.QueryRow("SELECT parent_id, category_id, options FROM products WHERE id = $1 FOR UPDATE", id).
+		Scan(&parent.parentID, &parent.categoryID, &options)
+	if err == sql.ErrNoRows {
+		return productParent{}, errors.New("Parent product not found")
+	}
+	if err != nil {
+		return productParent{}, err
+	}
+	if err := json.Unmarshal(options, &parent.options); err != nil {
+		return productParent{}, err
+	}
+	if parent.parentID != nil {
+		return productParent{}, errors.New("A variant cannot have variants of its own")
+	}
+	if len(parent.options) == 0 {
+		return productParent{}, errors.New("Parent product has no options")
+	}
+	return parent, nil
+}
+
+// checkVariant validates a variant against its locked listing. excludeID is
+// the variant itself when it is being updated.
+func checkVariant(tx *sql.Tx, parent productParent, parentID int, variant models.Product, excludeID int) error {
+	if len(variant.Options) > 0 {
+		return errors.New("A variant cannot have options of its own")
+	}
+	if err := checkOptionValues(parent.options, variant.OptionValues); err != nil {
+		return err
+	}
+
+	values, err := json.Marshal(variant.OptionValues)
+	if err != nil {
+		return err
+	}
+	var taken bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM products WHERE parent_id = $1 AND option_values = $2::jsonb AND id <> $3)",
+		parentID, string(values), excludeID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errors.New("Another variant already has these option values")
+	}
+	return nil
+}
+
+// checkListingVariants makes sure every existing variant of a listing is
+// still valid under new options.
+func checkListingVariants(tx *sql.Tx, id int, options []models.ProductOption) (int, error) {
+	rows, err := tx.Query("SELECT option_values FROM products WHERE parent_id = $1", id)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return 0, err
+		}
+		var values map[string]string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return 0, err
+		}
+		if err := checkOptionValues(options, values); err != nil {
+			return 0, errors.New("Existing variants do not fit the new options: " + err.Error())
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// checkProductCodes reports a SKU or barcode that another product already
+// uses, including as the barcode of one of its units. Empty codes are not
+// stored and never clash.
+func checkProductCodes(tx *sql.Tx, id int, sku string, barcode string) error {
+	var skuTaken, barcodeTaken bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM products WHERE sku = NULLIF($2, '') AND id <> $1),
+	                           EXISTS (SELECT 1 FROM products WHERE barcode = NULLIF($3, '') AND id <> $1)
+	                           OR EXISTS (SELECT 1 FROM product_units WHERE barcode = NULLIF($3, '') AND product_id <> $1)`,
+		id, sku, barcode).Scan(&skuTaken, &barcodeTaken)
+	if err != nil {
+		return err
+	}
+	if skuTaken {
+		return errors.New("SKU " + sku + " is already in use")
+	}
+	if barcodeTaken {
+		return errors.New("Barcode " + barcode + " is already in use")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"go-kasir-api/models"
+	"testing"
+)
+
+func TestValidateProductOptions(t *testing.T) {
+	valid := []models.ProductOption{
+		{Name: "Size", Values: []string{"S", "M", "L"}},
+		{Name: "Color", Values: []string{"Black", "White"}},
+	}
+	if err := validateProductOptions(valid); err != nil {
+		t.Errorf("valid options: %v", err)
+	}
+
+	invalid := map[string][]models.ProductOption{
+		"empty name":      {{Name: " ", Values: []string{"S"}}},
+		"repeated name":   {{Name: "Size", Values: []string{"S"}}, {Name: "size", Values: []string{"M"}}},
+		"no values":       {{Name: "Size"}},
+		"empty value":     {{Name: "Size", Values: []string{"S", ""}}},
+		"repeated values": {{Name: "Size", Values: []string{"S", "s"}}},
+	}
+	for name, options := range invalid {
+		if err := validateProductOptions(options); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCheckOptionValues(t *testing.T) {
+	options := []models.ProductOption{
+		{Name: "Size", Values: []string{"S", "M"}},
+		{Name: "Color", Values: []string{"Black"}},
+	}
+	if err := checkOptionValues(options, map[string]string{"Size": "M", "Color": "Black"}); err != nil {
+		t.Errorf("valid values: %v", err)
+	}
+
+	invalid := map[string]map[string]string{
+		"missing option": {"Size": "M"},
+		"unknown option": {"Size": "M", "Fit": "Slim"},
+		"unknown value":  {"Size": "XL", "Color": "Black"},
+		"extra option":   {"Size": "M", "Color": "Black", "Fit": "Slim"},
+	}
+	for name, values := range invalid {
+		if err := checkOptionValues(options, values); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,656 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+type PurchaseOrderRepository struct {
+	db *sql.DB
+}
+
+func NewPurchaseOrderRepository(db *sql.DB) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{db: db}
+}
+
+func (r *PurchaseOrderRepository) GetAll(status string, supplierID int) ([]models.PurchaseOrder, error) {
+	args := []interface{}{}
+	query := `SELECT po.id, po.supplier_id, s.name, po.outlet_id, po.status, po.notes, po.expected_date, po.total_expected_cost, po.created_at, po.updated_at
+	          FROM purchase_orders po
+	          JOIN suppliers s ON s.id = po.supplier_id
+	          WHERE 1 = 1`
+	if status != "" {
+		args = append(args, status)
+		query += " AND po.status = $" + strconv.Itoa(len(args))
+	}
+	if supplierID != 0 {
+		args = append(args, supplierID)
+		query += " AND po.supplier_id = $" + strconv.Itoa(len(args))
+	}
+	query += " ORDER BY po.id DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]models.PurchaseOrder, 0)
+	index := make(map[int]int)
+	ids := make([]int64, 0)
+	for rows.Next() {
+		order, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[order.ID] = len(orders)
+		ids = append(ids, int64(order.ID))
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items, err := r.getItems(r.db, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		i := index[item.PurchaseOrderID]
+		orders[i].Items = append(orders[i].Items, item)
+	}
+	return orders, nil
+}
+
+func (r *PurchaseOrderRepository) GetByID(id int) (*models.PurchaseOrder, error) {
+	row := r.db.QueryRow(`SELECT po.id, po.supplier_id, s.name, po.outlet_id, po.status, po.notes, po.expected_date, po.total_expected_cost, po.created_at, po.updated_at
+	                      FROM purchase_orders po
+	                      JOIN suppliers s ON s.id = po.supplier_id
+	                      WHERE po.id = $1`, id)
+	order, err := scanPurchaseOrder(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("purchase order not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	order.Items, err = r.getItems(r.db, []int64{int64(id)}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	order.Receipts, err = r.getReceipts(id)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *PurchaseOrderRepository) Create(order models.PurchaseOrder) (*models.PurchaseOrder, error) {
+	items, err := mergePurchaseOrderItems(order.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	outletID, err := purchaseOrderOutlet(tx, order.OutletID)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int
+	err = tx.QueryRow(`INSERT INTO purchase_orders (supplier_id, outlet_id, status, notes, expected_date)
+	                   VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		order.SupplierID, outletID, models.PurchaseOrderDraft, order.Notes, order.ExpectedDate).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertPurchaseOrderItems(tx, id, items); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// Update replaces the header and lines of a draft order. Orders that were
+// already sent to the supplier can only be received or cancelled.
+func (r *PurchaseOrderRepository) Update(id int, order models.PurchaseOrder) (*models.PurchaseOrder, error) {
+	items, err := mergePurchaseOrderItems(order.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := requirePurchaseOrderStatus(tx, id, models.PurchaseOrderDraft); err != nil {
+		return nil, err
+	}
+	outletID, err := purchaseOrderOutlet(tx, order.OutletID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE purchase_orders SET supplier_id = $2, outlet_id = $3, notes = $4, expected_date = $5, updated_at = NOW()
+	                  WHERE id = $1`, id, order.SupplierID, outletID, order.Notes, order.ExpectedDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM purchase_order_items WHERE purchase_order_id = $1", id); err != nil {
+		return nil, err
+	}
+	if err := insertPurchaseOrderItems(tx, id, items); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+func (r *PurchaseOrderRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := requirePurchaseOrderStatus(tx, id, models.PurchaseOrderDraft); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM purchase_order_items WHERE purchase_order_id = $1", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM purchase_orders WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateStatus moves an order to status when its current status is one of from.
+func (r *PurchaseOrderRepository) UpdateStatus(id int, status string, from ...string) (*models.PurchaseOrder, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := requirePurchaseOrderStatus(tx, id, from...); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE purchase_orders SET status = $2, updated_at = NOW() WHERE id = $1", id, status)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// Receive books a delivery against an open order. Stock at the order's outlet
+// is increased, with a batch for every lot the goods arrived in, the
+// unit cost is recorded on the receipt and in the stock history and folded
+// into the product's average cost, and the order becomes partially_received or
+// received depending on what is still due.
+func (r *PurchaseOrderRepository) Receive(id int, req models.ReceiveRequest) (*models.GoodsReceipt, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = requirePurchaseOrderStatus(tx, id, models.PurchaseOrderOrdered, models.PurchaseOrderPartiallyReceived)
+	if err != nil {
+		return nil, err
+	}
+	var outletID int
+	if err := tx.QueryRow("SELECT outlet_id FROM purchase_orders WHERE id = $1", id).Scan(&outletID); err != nil {
+		return nil, err
+	}
+
+	orderItems, err := r.getItems(tx, []int64{int64(id)}, true)
+	if err != nil {
+		return nil, err
+	}
+	byProduct := make(map[int]*models.PurchaseOrderItem, len(orderItems))
+	for i := range orderItems {
+		byProduct[orderItems[i].ProductID] = &orderItems[i]
+	}
+
+	if len(req.Items) == 0 {
+		return nil, errors.New("Receipt must contain at least one item")
+	}
+
+	receipt := models.GoodsReceipt{PurchaseOrderID: id, Notes: req.Notes}
+	lots := make(map[int][]models.LotQuantity, len(req.Items))
+	seen := make(map[int]bool, len(req.Items))
+	for _, item := range req.Items {
+		orderItem, ok := byProduct[item.ProductID]
+		if !ok {
+			return nil, errors.New("Product " + strconv.Itoa(item.ProductID) + " is not on this purchase order")
+		}
+		if seen[item.ProductID] {
+			return nil, errors.New("Product " + strconv.Itoa(item.ProductID) + " is listed more than once")
+		}
+		seen[item.ProductID] = true
+		if item.Quantity <= 0 {
+			return nil, errors.New("Quantity must be greater than zero")
+		}
+		if orderItem.QuantityReceived+item.Quantity > orderItem.QuantityOrdered {
+			return nil, errors.New("Received quantity for product " + strconv.Itoa(item.ProductID) + " exceeds the ordered quantity")
+		}
+		if err := checkLots(item.Lots, item.Quantity); err != nil {
+			return nil, err
+		}
+		lots[item.ProductID] = item.Lots
+
+		unitCost := orderItem.ExpectedUnitCost
+		if item.UnitCost != nil {
+			unitCost = *item.UnitCost
+		}
+		if unitCost < 0 {
+			return nil, errors.New("Unit cost cannot be negative")
+		}
+		orderItem.QuantityReceived += item.Quantity
+
+		receipt.Items = append(receipt.Items, models.GoodsReceiptItem{
+			PurchaseOrderItemID: orderItem.ID,
+			ProductID:           item.ProductID,
+			Quantity:            item.Quantity,
+			UnitCost:            unitCost,
+		})
+	}
+
+	err = tx.QueryRow("INSERT INTO goods_receipts (purchase_order_id, notes) VALUES ($1, $2) RETURNING id, received_at",
+		id, req.Notes).Scan(&receipt.ID, &receipt.ReceivedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	orderItemIDs := make([]int64, len(receipt.Items))
+	productIDs := make([]int64, len(receipt.Items))
+	quantities := make([]models.Quantity, len(receipt.Items))
+	unitCosts := make([]int64, len(receipt.Items))
+	movements := make([]models.StockMovement, len(receipt.Items))
+	for i, item := range receipt.Items {
+		orderItemIDs[i] = int64(item.PurchaseOrderItemID)
+		productIDs[i] = int64(item.ProductID)
+		quantities[i] = item.Quantity
+		unitCosts[i] = int64(item.UnitCost)
+		unitCost := item.UnitCost
+		movements[i] = models.StockMovement{
+			ProductID:     item.ProductID,
+			OutletID:      outletID,
+			Quantity:      item.Quantity,
+			MovementType:  models.StockMovementPurchaseReceipt,
+			ReferenceType: "goods_receipt",
+			ReferenceID:   receipt.ID,
+			UnitCost:      &unitCost,
+		}
+	}
+
+	// Lock products in ID order, the same order checkout uses, before any
+	// write below takes a key share lock on them in request order.
+	_, err = tx.Exec("SELECT id FROM products WHERE id = ANY($1) ORDER BY id FOR UPDATE", pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`INSERT INTO goods_receipt_items (goods_receipt_id, purchase_order_item_id, product_id, quantity, unit_cost)
+	                       SELECT $1, v.item_id, v.product_id, v.quantity, v.unit_cost
+	                       FROM unnest($2::int[], $3::int[], $4::numeric[], $5::int[]) AS v(item_id, product_id, quantity, unit_cost)
+	                       RETURNING id, product_id`,
+		receipt.ID, pq.Array(orderItemIDs), pq.Array(productIDs), pq.Array(quantities), pq.Array(unitCosts))
+	if err != nil {
+		return nil, err
+	}
+	receiptItemIDs := make(map[int]int, len(receipt.Items))
+	for rows.Next() {
+		var itemID, productID int
+		if err := rows.Scan(&itemID, &productID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		receiptItemIDs[productID] = itemID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range receipt.Items {
+		receipt.Items[i].ID = receiptItemIDs[receipt.Items[i].ProductID]
+		receipt.Items[i].GoodsReceiptID = receipt.ID
+	}
+
+	_, err = tx.Exec(`UPDATE purchase_order_items i SET quantity_received = i.quantity_received + v.quantity
+	                  FROM unnest($1::int[], $2::numeric[]) AS v(id, quantity)
+	                  WHERE i.id = v.id`, pq.Array(orderItemIDs), pq.Array(quantities))
+	if err != nil {
+		return nil, err
+	}
+
+	// The cost price becomes the weighted average of the stock on hand at all
+	// outlets and the goods received. Stock at or below zero has no cost worth
+	// averaging.
+	_, err = tx.Exec(`UPDATE products p SET
+	                      cost_price = CASE
+	                          WHEN p.stock <= 0 THEN v.unit_cost
+	                          ELSE ROUND((p.stock * p.cost_price + v.quantity * v.unit_cost)::numeric / (p.stock + v.quantity))
+	                      END,
+	                      updated_at = NOW()
+	                  FROM unnest($1::int[], $2::numeric[], $3::int[]) AS v(product_id, quantity, unit_cost)
+	                  WHERE p.id = v.product_id`, pq.Array(productIDs), pq.Array(quantities), pq.Array(unitCosts))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := adjustOutletStock(tx, outletID, productIDs, quantities); err != nil {
+		return nil, err
+	}
+	for i, item := range receipt.Items {
+		if len(lots[item.ProductID]) == 0 {
+			continue
+		}
+		receipt.Items[i].Batches, err = insertBatches(tx, outletID, item.ProductID, &receipt.Items[i].ID, lots[item.ProductID])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordStockMovements(tx, movements); err != nil {
+		return nil, err
+	}
+
+	status := models.PurchaseOrderReceived
+	for _, item := range orderItems {
+		if item.QuantityReceived < item.QuantityOrdered {
+			status = models.PurchaseOrderPartiallyReceived
+			break
+		}
+	}
+	_, err = tx.Exec("UPDATE purchase_orders SET status = $2, updated_at = NOW() WHERE id = $1", id, status)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// GetOutstandingBySupplier sums what is still due on ordered and partially
+// received orders, valued at the expected unit cost. A non-nil outletID only
+// counts orders for that outlet, and a non-nil categoryID only the items of
+// products in that category or below it.
+func (r *PurchaseOrderRepository) GetOutstandingBySupplier(outletID *int, categoryID *int) ([]models.OutstandingPurchaseOrders, error) {
+	report := make([]models.OutstandingPurchaseOrders, 0)
+	err := r.EachOutstandingBySupplier(outletID, categoryID, func(line models.OutstandingPurchaseOrders) error {
+		report = append(report, line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// EachOutstandingBySupplier calls fn for every supplier line of the
+// outstanding purchase order report as it is read.
+func (r *PurchaseOrderRepository) EachOutstandingBySupplier(outletID *int, categoryID *int, fn func(models.OutstandingPurchaseOrders) error) error {
+	rows, err := r.db.Query(`
+		SELECT
+			s.id,
+			s.name,
+			COUNT(DISTINCT po.id),
+			COALESCE(SUM(i.quantity_ordered - i.quantity_received), 0),
+			COALESCE(ROUND(SUM((i.quantity_ordered - i.quantity_received) * i.expected_unit_cost))::bigint, 0),
+			MIN(po.expected_date)
+		FROM purchase_orders po
+		JOIN suppliers s ON s.id = po.supplier_id
+		JOIN purchase_order_items i ON i.purchase_order_id = po.id
+		JOIN products p ON p.id = i.product_id
+		WHERE po.status IN ($1, $2) AND ($3::int IS NULL OR po.outlet_id = $3)
+		  AND `+inCategory("p.category_id", "$4")+`
+		GROUP BY s.id, s.name
+		ORDER BY s.name ASC
+	`, models.PurchaseOrderOrdered, models.PurchaseOrderPartiallyReceived, outletID, categoryID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.OutstandingPurchaseOrders
+		var earliest sql.NullTime
+		err := rows.Scan(&line.SupplierID, &line.SupplierName, &line.OpenOrders,
+			&line.OutstandingQuantity, &line.OutstandingValue, &earliest)
+		if err != nil {
+			return err
+		}
+		if earliest.Valid {
+			line.EarliestExpected = &earliest.Time
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (r *PurchaseOrderRepository) getItems(q queryer, orderIDs []int64, forUpdate bool) ([]models.PurchaseOrderItem, error) {
+	items := make([]models.PurchaseOrderItem, 0)
+	if len(orderIDs) == 0 {
+		return items, nil
+	}
+
+	query := `SELECT i.id, i.purchase_order_id, i.product_id, p.name, i.quantity_ordered, i.quantity_received, i.expected_unit_cost
+	          FROM purchase_order_items i
+	          JOIN products p ON p.id = i.product_id
+	          WHERE i.purchase_order_id = ANY($1)
+	          ORDER BY i.purchase_order_id, i.id`
+	if forUpdate {
+		query += " FOR UPDATE OF i"
+	}
+	rows, err := q.Query(query, pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.PurchaseOrderItem
+		err := rows.Scan(&item.ID, &item.PurchaseOrderID, &item.ProductID, &item.ProductName,
+			&item.QuantityOrdered, &item.QuantityReceived, &item.ExpectedUnitCost)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *PurchaseOrderRepository) getReceipts(orderID int) ([]models.GoodsReceipt, error) {
+	rows, err := r.db.Query(`SELECT gr.id, gr.purchase_order_id, gr.notes, gr.received_at,
+	                                i.id, i.purchase_order_item_id, i.product_id, i.quantity, i.unit_cost
+	                         FROM goods_receipts gr
+	                         JOIN goods_receipt_items i ON i.goods_receipt_id = gr.id
+	                         WHERE gr.purchase_order_id = $1
+	                         ORDER BY gr.id, i.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := make([]models.GoodsReceipt, 0)
+	for rows.Next() {
+		var receipt models.GoodsReceipt
+		var item models.GoodsReceiptItem
+		err := rows.Scan(&receipt.ID, &receipt.PurchaseOrderID, &receipt.Notes, &receipt.ReceivedAt,
+			&item.ID, &item.PurchaseOrderItemID, &item.ProductID, &item.Quantity, &item.UnitCost)
+		if err != nil {
+			return nil, err
+		}
+		item.GoodsReceiptID = receipt.ID
+		if n := len(receipts); n > 0 && receipts[n-1].ID == receipt.ID {
+			receipts[n-1].Items = append(receipts[n-1].Items, item)
+			continue
+		}
+		receipt.Items = []models.GoodsReceiptItem{item}
+		receipts = append(receipts, receipt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	batches, err := r.db.Query(`SELECT `+stockBatchColumns+`
+	                            FROM stock_batches b
+	                            JOIN products p ON p.id = b.product_id
+	                            JOIN goods_receipt_items i ON i.id = b.goods_receipt_item_id
+	                            JOIN goods_receipts gr ON gr.id = i.goods_receipt_id
+	                            WHERE gr.purchase_order_id = $1
+	                            ORDER BY b.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer batches.Close()
+	byItem := make(map[int][]models.StockBatch)
+	for batches.Next() {
+		batch, err := scanStockBatch(batches)
+		if err != nil {
+			return nil, err
+		}
+		byItem[*batch.GoodsReceiptItemID] = append(byItem[*batch.GoodsReceiptItemID], batch)
+	}
+	if err := batches.Err(); err != nil {
+		return nil, err
+	}
+	for i := range receipts {
+		for j := range receipts[i].Items {
+			receipts[i].Items[j].Batches = byItem[receipts[i].Items[j].ID]
+		}
+	}
+	return receipts, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPurchaseOrder(row rowScanner) (models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	var expected sql.NullTime
+	err := row.Scan(&order.ID, &order.SupplierID, &order.SupplierName, &order.OutletID, &order.Status, &order.Notes,
+		&expected, &order.TotalExpectedCost, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	if expected.Valid {
+		order.ExpectedDate = &expected.Time
+	}
+	order.Items = make([]models.PurchaseOrderItem, 0)
+	return order, nil
+}
+
+// purchaseOrderOutlet resolves the outlet an order is for. Zero means the
+// default outlet.
+func purchaseOrderOutlet(tx *sql.Tx, outletID int) (int, error) {
+	if outletID == 0 {
+		return resolveOutlet(tx, nil)
+	}
+	return resolveOutlet(tx, &outletID)
+}
+
+// requirePurchaseOrderStatus locks the order row and checks that its status is
+// one of allowed.
+func requirePurchaseOrderStatus(tx *sql.Tx, id int, allowed ...string) error {
+	var status string
+	err := tx.QueryRow("SELECT status FROM purchase_orders WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return errors.New("purchase order not found")
+	}
+	if err != nil {
+		return err
+	}
+	for _, s := range allowed {
+		if status == s {
+			return nil
+		}
+	}
+	return errors.New("purchase order is " + status)
+}
+
+func insertPurchaseOrderItems(tx *sql.Tx, orderID int, items []models.PurchaseOrderItem) error {
+	productIDs := make([]int64, len(items))
+	quantities := make([]models.Quantity, len(items))
+	costs := make([]int64, len(items))
+	total := 0
+	for i, item := range items {
+		productIDs[i] = int64(item.ProductID)
+		quantities[i] = item.QuantityOrdered
+		costs[i] = int64(item.ExpectedUnitCost)
+		total += roundAmount(item.QuantityOrdered.Amount(item.ExpectedUnitCost), 1)
+	}
+
+	_, err := tx.Exec(`INSERT INTO purchase_order_items (purchase_order_id, product_id, quantity_ordered, expected_unit_cost)
+	                   SELECT $1, v.product_id, v.quantity, v.cost
+	                   FROM unnest($2::int[], $3::numeric[], $4::int[]) AS v(product_id, quantity, cost)`,
+		orderID, pq.Array(productIDs), pq.Array(quantities), pq.Array(costs))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE purchase_orders SET total_expected_cost = $2 WHERE id = $1", orderID, total)
+	return err
+}
+
+func mergePurchaseOrderItems(items []models.PurchaseOrderItem) ([]models.PurchaseOrderItem, error) {
+	if len(items) == 0 {
+		return nil, errors.New("Purchase order must contain at least one item")
+	}
+
+	merged := make([]models.PurchaseOrderItem, 0, len(items))
+	index := make(map[int]int, len(items))
+	for _, item := range items {
+		if item.QuantityOrdered <= 0 {
+			return nil, errors.New("Quantity must be greater than zero")
+		}
+		if item.ExpectedUnitCost < 0 {
+			return nil, errors.New("Expected unit cost cannot be negative")
+		}
+		if i, ok := index[item.ProductID]; ok {
+			if merged[i].ExpectedUnitCost != item.ExpectedUnitCost {
+				return nil, errors.New("Product " + strconv.Itoa(item.ProductID) + " is listed twice with different costs")
+			}
+			merged[i].QuantityOrdered += item.QuantityOrdered
+			continue
+		}
+		index[item.ProductID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged, nil
+}
@@ -0,0 +1,690 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-kasir-api/models"
+	"math"
+	"strings"
+	"time"
+)
+
+// dateLayout is the format of report dates in requests and responses.
+const dateLayout = "2006-01-02"
+
+type ReportRepository struct {
+	db       *sql.DB
+	calendar *BusinessCalendar
+}
+
+func NewReportRepository(db *sql.DB, calendar *BusinessCalendar) *ReportRepository {
+	return &ReportRepository{db: db, calendar: calendar}
+}
+
+// netRevenue sums the revenue of a group of transaction lines net of
+// discounts. A transaction's discount is spread over its lines in proportion
+// to their subtotals. The shares are kept exact and only the sum is rounded:
+// rounding each share down would leave the lines of a transaction adding up
+// to more than its total.
+const netRevenue = `ROUND(SUM(td.subtotal - COALESCE(t.discount_amount::numeric * td.subtotal / NULLIF(t.total_amount + t.discount_amount, 0), 0)))::bigint`
+
+// salesPeriod limits a sales query to the business days from start through
+// end, inclusive. from and to are the instants bounding those days. A non-nil
+// outletID also limits it to the sales of one outlet, and a non-nil categoryID
+// to the lines of products in that category or below it.
+type salesPeriod struct {
+	start      time.Time
+	end        time.Time
+	from       time.Time
+	to         time.Time
+	outletID   *int
+	categoryID *int
+}
+
+// period resolves business dates to a salesPeriod. Zero dates mean today.
+func (c *BusinessCalendar) period(start time.Time, end time.Time) salesPeriod {
+	if start.IsZero() && end.IsZero() {
+		start = c.Today()
+		end = start
+	}
+	from, to := c.Bounds(start, end)
+	return salesPeriod{start: start, end: end, from: from, to: to}
+}
+
+// period resolves business dates and an optional outlet and category to a
+// salesPeriod.
+func (r *ReportRepository) period(start time.Time, end time.Time, outletID *int, categoryID *int) salesPeriod {
+	period := r.calendar.period(start, end)
+	period.outletID = outletID
+	period.categoryID = categoryID
+	return period
+}
+
+// condition returns the filter on t.created_at, t.outlet_id and p.category_id,
+// numbering its placeholders after the arguments already in args. Queries
+// filtered by category must join the products of their lines as p. The
+// bounds are cast to TIMESTAMPTZ so their offset is honoured when compared
+// with the TIMESTAMP column.
+func (p salesPeriod) condition(args []interface{}) (string, []interface{}) {
+	args = append(args, p.from, p.to)
+	condition := fmt.Sprintf("t.created_at >= $%d::timestamptz AND t.created_at < $%d::timestamptz", len(args)-1, len(args))
+	if p.outletID != nil {
+		args = append(args, *p.outletID)
+		condition += fmt.Sprintf(" AND t.outlet_id = $%d", len(args))
+	}
+	if p.categoryID != nil {
+		args = append(args, *p.categoryID)
+		condition += fmt.Sprintf(" AND p.category_id IN (%s)", categorySubtree(fmt.Sprintf("$%d", len(args))))
+	}
+	return condition, args
+}
+
+// salesDimensions maps a breakdown dimension to its table, to the column of a
+// sale line that references it and to the rows of the table that are ranked.
+// Products roll variants up into their listing; variant ranks what was
+// actually sold. category is the column of a row that a category filter
+// applies to, if any.
+var salesDimensions = map[string]struct {
+	table    string
+	key      string
+	filter   string
+	category string
+}{
+	"product":  {table: "products", key: "COALESCE(p.parent_id, p.id)", filter: "d.parent_id IS NULL", category: "d.category_id"},
+	"variant":  {table: "products", key: "td.product_id", filter: "d.options = '[]'::jsonb", category: "d.category_id"},
+	"category": {table: "categories", key: "p.category_id", category: "d.id"},
+	"cashier":  {table: "cashiers", key: "t.cashier_id"},
+	"outlet":   {table: "outlets", key: "t.outlet_id"},
+}
+
+var rankMetrics = map[string]string{"quantity": "quantity_sold", "revenue": "revenue"}
+
+// CheckRankBy returns an error unless rankBy is a metric the sales breakdowns
+// can rank by.
+func CheckRankBy(rankBy string) error {
+	if _, ok := rankMetrics[rankBy]; !ok {
+		return errors.New("rank_by must be quantity or revenue")
+	}
+	return nil
+}
+
+type salesBreakdownQuery struct {
+	dimension string
+	rankBy    string
+	ascending bool
+	// soldOnly drops rows without sales in the period. Otherwise every row of
+	// the dimension is returned, so unsold products rank at the bottom.
+	soldOnly bool
+	limit    int
+}
+
+// querySalesBreakdown ranks products, variants, categories, cashiers or
+// outlets by quantity or revenue. It also returns the revenue of the whole period, before the limit is
+// applied.
+func querySalesBreakdown(q queryer, period salesPeriod, b salesBreakdownQuery) ([]models.SalesBreakdownLine, int, error) {
+	lines := make([]models.SalesBreakdownLine, 0)
+	total := 0
+	err := eachSalesBreakdownLine(q, period, b, func(line models.SalesBreakdownLine, periodRevenue int) error {
+		total = periodRevenue
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return lines, total, nil
+}
+
+// eachSalesBreakdownLine calls fn with each ranked line as it is read, along
+// with the revenue of the whole period. Ties are broken by name and then ID so
+// the order is stable.
+func eachSalesBreakdownLine(q queryer, period salesPeriod, b salesBreakdownQuery, fn func(models.SalesBreakdownLine, int) error) error {
+	dimension, ok := salesDimensions[b.dimension]
+	if !ok {
+		return errors.New("dimension must be product, variant, category, cashier or outlet")
+	}
+	if err := CheckRankBy(b.rankBy); err != nil {
+		return err
+	}
+	metric := rankMetrics[b.rankBy]
+	direction := "DESC"
+	if b.ascending {
+		direction = "ASC"
+	}
+
+	condition, args := period.condition(nil)
+	query := `
+		SELECT d.id, d.name,
+		       COALESCE(s.quantity_sold, 0) AS quantity_sold,
+		       COALESCE(s.revenue, 0) AS revenue,
+		       COALESCE(s.transactions, 0),
+		       COALESCE(SUM(s.revenue) OVER (), 0)
+		FROM ` + dimension.table + ` d
+		LEFT JOIN (
+			SELECT ` + dimension.key + ` AS id,
+			       SUM(td.quantity) AS quantity_sold,
+			       ` + netRevenue + ` AS revenue,
+			       COUNT(DISTINCT t.id) AS transactions
+			FROM transaction_details td
+			JOIN transactions t ON t.id = td.transaction_id
+			JOIN products p ON p.id = td.product_id
+			WHERE ` + condition + `
+			GROUP BY 1
+		) s ON s.id = d.id`
+	var filters []string
+	if dimension.filter != "" {
+		filters = append(filters, dimension.filter)
+	}
+	if period.categoryID != nil && dimension.category != "" {
+		args = append(args, *period.categoryID)
+		filters = append(filters, dimension.category+" IN ("+categorySubtree(fmt.Sprintf("$%d", len(args)))+")")
+	}
+	if b.soldOnly {
+		filters = append(filters, "s.id IS NOT NULL")
+	}
+	if len(filters) > 0 {
+		query += `
+		WHERE ` + strings.Join(filters, " AND ")
+	}
+	query += `
+		ORDER BY ` + metric + ` ` + direction + `, d.name ASC, d.id ASC`
+	if b.limit > 0 {
+		args = append(args, b.limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	rank := 0
+	for rows.Next() {
+		var line models.SalesBreakdownLine
+		var total int
+		err := rows.Scan(&line.ID, &line.Name, &line.QuantitySold, &line.Revenue, &line.TransactionCount, &total)
+		if err != nil {
+			return err
+		}
+		rank++
+		line.Rank = rank
+		line.RevenueShare = marginPercent(total, line.Revenue)
+		if err := fn(line, total); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type profitGrouping struct {
+	id    string
+	name  string
+	order string
+}
+
+// profitGroupings maps group_by to its SQL. {local} stands for the sale time
+// on the store's business calendar. product rolls variants up into their
+// listing (lp); variant keeps them apart.
+var profitGroupings = map[string]profitGrouping{
+	"product":  {id: "COALESCE(lp.id, p.id)", name: "COALESCE(lp.name, p.name)", order: "gross_profit DESC, 2 ASC"},
+	"variant":  {id: "p.id", name: "p.name", order: "gross_profit DESC, 2 ASC"},
+	"category": {id: "c.id", name: "c.name", order: "gross_profit DESC, 2 ASC"},
+	"outlet":   {id: "o.id", name: "o.name", order: "gross_profit DESC, 2 ASC"},
+	"day":      {id: "0", name: "to_char(date_trunc('day', {local}), 'YYYY-MM-DD')", order: "2 ASC"},
+	"week":     {id: "0", name: "to_char(date_trunc('week', {local}), 'YYYY-MM-DD')", order: "2 ASC"},
+	"month":    {id: "0", name: "to_char(date_trunc('month', {local}), 'YYYY-MM')", order: "2 ASC"},
+}
+
+// GetProfit returns revenue, cost of goods sold and gross profit grouped by
+// product, variant, category, outlet or period. Costs come from the cost price
+// snapshotted on each transaction line.
+func (r *ReportRepository) GetProfit(start time.Time, end time.Time, outletID *int, categoryID *int, groupBy string) (*models.ProfitReport, error) {
+	period := r.period(start, end, outletID, categoryID)
+	report := &models.ProfitReport{GroupBy: groupBy, StartDate: period.start.Format(dateLayout), EndDate: period.end.Format(dateLayout), OutletID: outletID, CategoryID: categoryID, Lines: make([]models.ProfitReportLine, 0)}
+	err := r.EachProfitLine(start, end, outletID, categoryID, groupBy, func(line models.ProfitReportLine) error {
+		report.Revenue += line.Revenue
+		report.COGS += line.COGS
+		report.GrossProfit += line.GrossProfit
+		report.Lines = append(report.Lines, line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.MarginPercent = marginPercent(report.Revenue, report.GrossProfit)
+	return report, nil
+}
+
+// CheckProfitGroupBy returns an error unless groupBy is one of the groupings
+// of the profit report.
+func CheckProfitGroupBy(groupBy string) error {
+	if _, ok := profitGroupings[groupBy]; !ok {
+		return errors.New("group_by must be product, variant, category, outlet, day, week or month")
+	}
+	return nil
+}
+
+// EachProfitLine calls fn for every line of the profit report as it is read.
+func (r *ReportRepository) EachProfitLine(start time.Time, end time.Time, outletID *int, categoryID *int, groupBy string, fn func(models.ProfitReportLine) error) error {
+	if err := CheckProfitGroupBy(groupBy); err != nil {
+		return err
+	}
+	grouping := profitGroupings[groupBy]
+
+	condition, args := r.period(start, end, outletID, categoryID).condition(nil)
+	name := strings.ReplaceAll(grouping.name, "{local}", r.calendar.localTime("t.created_at", true))
+	rows, err := r.db.Query(`
+		SELECT `+grouping.id+`, `+name+`,
+		       SUM(td.quantity),
+		       `+netRevenue+` AS revenue,
+		       ROUND(SUM(td.quantity * td.unit_cost))::bigint AS cogs,
+		       `+netRevenue+` - ROUND(SUM(td.quantity * td.unit_cost))::bigint AS gross_profit
+		FROM transaction_details td
+		JOIN transactions t ON t.id = td.transaction_id
+		JOIN products p ON p.id = td.product_id
+		LEFT JOIN products lp ON lp.id = p.parent_id
+		JOIN categories c ON c.id = p.category_id
+		JOIN outlets o ON o.id = t.outlet_id
+		WHERE `+condition+`
+		GROUP BY 1, 2
+		ORDER BY `+grouping.order, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.ProfitReportLine
+		err := rows.Scan(&line.GroupID, &line.GroupName, &line.QuantitySold, &line.Revenue, &line.COGS, &line.GrossProfit)
+		if err != nil {
+			return err
+		}
+		line.MarginPercent = marginPercent(line.Revenue, line.GrossProfit)
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetSalesBreakdown ranks the products, variants, categories, cashiers or
+// outlets of a period. With a limit it returns the top or bottom N; without one, the full
+// breakdown.
+func (r *ReportRepository) GetSalesBreakdown(start time.Time, end time.Time, outletID *int, categoryID *int, dimension string, rankBy string, ascending bool, limit int) (*models.SalesBreakdown, error) {
+	period := r.period(start, end, outletID, categoryID)
+	lines, total, err := querySalesBreakdown(r.db, period, breakdownQuery(dimension, rankBy, ascending, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	order := "desc"
+	if ascending {
+		order = "asc"
+	}
+	return &models.SalesBreakdown{
+		Dimension:  dimension,
+		RankBy:     rankBy,
+		Order:      order,
+		StartDate:  period.start.Format(dateLayout),
+		EndDate:    period.end.Format(dateLayout),
+		OutletID:   outletID,
+		CategoryID: categoryID,
+		Revenue:    total,
+		Lines:      lines,
+	}, nil
+}
+
+// EachSalesBreakdownLine calls fn for every ranked line as it is read.
+func (r *ReportRepository) EachSalesBreakdownLine(start time.Time, end time.Time, outletID *int, categoryID *int, dimension string, rankBy string, ascending bool, limit int, fn func(models.SalesBreakdownLine) error) error {
+	return eachSalesBreakdownLine(r.db, r.period(start, end, outletID, categoryID), breakdownQuery(dimension, rankBy, ascending, limit),
+		func(line models.SalesBreakdownLine, _ int) error { return fn(line) })
+}
+
+// breakdownQuery builds a ranking. A top list only ranks what sold. A bottom
+// list keeps unsold rows, which are the slowest of all.
+func breakdownQuery(dimension string, rankBy string, ascending bool, limit int) salesBreakdownQuery {
+	return salesBreakdownQuery{
+		dimension: dimension,
+		rankBy:    rankBy,
+		ascending: ascending,
+		soldOnly:  !ascending && limit > 0,
+		limit:     limit,
+	}
+}
+
+// GetDeadStock lists products still in stock that have not sold in the last
+// days days, including products that never sold, by stock value at cost. A
+// non-nil outletID looks at the stock and sales of that outlet only; a non-nil
+// categoryID at the products in that category or below it.
+func (r *ReportRepository) GetDeadStock(days int, outletID *int, categoryID *int) (*models.DeadStockReport, error) {
+	report := &models.DeadStockReport{Days: days, OutletID: outletID, CategoryID: categoryID, Items: make([]models.DeadStockItem, 0)}
+	err := r.EachDeadStockItem(days, outletID, categoryID, func(item models.DeadStockItem) error {
+		report.StockValue += item.StockValue
+		report.Items = append(report.Items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// EachDeadStockItem calls fn for every line of the dead-stock report as it is
+// read.
+func (r *ReportRepository) EachDeadStockItem(days int, outletID *int, categoryID *int, fn func(models.DeadStockItem) error) error {
+	rows, err := r.db.Query(`
+		SELECT p.id, p.name, p.category_id, st.stock, ROUND(st.stock * p.cost_price)::bigint AS stock_value,
+		       s.last_sold_at, EXTRACT(DAY FROM LOCALTIMESTAMP - s.last_sold_at)::int
+		FROM products p
+		CROSS JOIN LATERAL (`+stockAt("$2")+`) st
+		LEFT JOIN (
+			SELECT td.product_id, MAX(t.created_at) AS last_sold_at
+			FROM transaction_details td
+			JOIN transactions t ON t.id = td.transaction_id
+			WHERE $2::int IS NULL OR t.outlet_id = $2
+			GROUP BY td.product_id
+		) s ON s.product_id = p.id
+		WHERE st.stock > 0
+		  AND (s.last_sold_at IS NULL OR s.last_sold_at < LOCALTIMESTAMP - make_interval(days => $1))
+		  AND `+inCategory("p.category_id", "$3")+`
+		ORDER BY stock_value DESC, p.name ASC, p.id ASC
+	`, days, outletID, categoryID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.DeadStockItem
+		var lastSold sql.NullTime
+		var daysSince sql.NullInt64
+		err := rows.Scan(&item.ProductID, &item.ProductName, &item.CategoryID, &item.Stock, &item.StockValue, &lastSold, &daysSince)
+		if err != nil {
+			return err
+		}
+		if lastSold.Valid {
+			item.LastSoldAt = &lastSold.Time
+			d := int(daysSince.Int64)
+			item.DaysSinceLastSale = &d
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+var seriesIntervals = map[string]bool{"hour": true, "day": true, "week": true, "month": true}
+
+// maxSeriesBuckets keeps a long range at a fine interval, such as a year by
+// the hour, from producing an unbounded response.
+const maxSeriesBuckets = 5000
+
+// CheckSalesSeries returns an error unless interval is one the sales series
+// supports and the business days from start through end fit in
+// maxSeriesBuckets of it.
+func CheckSalesSeries(start time.Time, end time.Time, interval string) error {
+	if !seriesIntervals[interval] {
+		return errors.New("interval must be hour, day, week or month")
+	}
+	if seriesBuckets(start, end, interval) > maxSeriesBuckets {
+		return errors.New("date range is too long for this interval")
+	}
+	return nil
+}
+
+// seriesBuckets counts the buckets of interval that the business days from
+// start through end touch. Weeks start on Monday.
+func seriesBuckets(start time.Time, end time.Time, interval string) int {
+	days := int(end.Sub(start).Hours()/24) + 1
+	switch interval {
+	case "hour":
+		return days * 24
+	case "week":
+		monday := start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+		return int(end.Sub(monday).Hours()/24)/7 + 1
+	case "month":
+		return (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month()) + 1
+	}
+	return days
+}
+
+// GetSalesSeries buckets sales on the business days from start through end by
+// interval. Buckets without sales are returned with zeros so charts have no
+// gaps. Weeks start on Monday. Hours are wall-clock hours in the store's
+// timezone; longer buckets follow the business-day cutoff.
+func (r *ReportRepository) GetSalesSeries(start time.Time, end time.Time, outletID *int, categoryID *int, interval string) (*models.SalesSeries, error) {
+	series := &models.SalesSeries{Interval: interval, Start: start.Format(dateLayout), End: end.Format(dateLayout), OutletID: outletID, CategoryID: categoryID, Points: make([]models.SalesSeriesPoint, 0)}
+	err := r.EachSalesSeriesPoint(start, end, outletID, categoryID, interval, func(point models.SalesSeriesPoint) error {
+		series.Points = append(series.Points, point)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// EachSalesSeriesPoint calls fn for every bucket of the sales series as it is
+// read. Filtered by category, revenue and transactions count only the lines
+// of products in the category.
+func (r *ReportRepository) EachSalesSeriesPoint(start time.Time, end time.Time, outletID *int, categoryID *int, interval string, fn func(models.SalesSeriesPoint) error) error {
+	if err := CheckSalesSeries(start, end, interval); err != nil {
+		return err
+	}
+
+	// Buckets are generated in store wall-clock time. Shifted time puts the
+	// cutoff at midnight; hourly buckets keep real hours, so their range
+	// starts at the cutoff instead.
+	shifted := interval != "hour"
+	lower, upper := start, end.AddDate(0, 0, 1)
+	if !shifted {
+		cutoff := time.Duration(r.calendar.cutoffHour) * time.Hour
+		lower, upper = lower.Add(cutoff), upper.Add(cutoff)
+	}
+	const wallClock = "2006-01-02 15:04:05"
+	period := r.calendar.period(start, end)
+
+	local := r.calendar.localTime("t.created_at", shifted)
+	sales := `
+			SELECT date_trunc($3, ` + local + `) AS bucket, COUNT(*) AS transactions, SUM(t.total_amount) AS revenue
+			FROM transactions t
+			WHERE t.created_at >= $4::timestamptz AND t.created_at < $5::timestamptz
+			  AND ($6::int IS NULL OR t.outlet_id = $6)
+			GROUP BY 1`
+	if categoryID != nil {
+		sales = `
+			SELECT date_trunc($3, ` + local + `) AS bucket, COUNT(DISTINCT t.id) AS transactions, ` + netRevenue + ` AS revenue
+			FROM transaction_details td
+			JOIN transactions t ON t.id = td.transaction_id
+			JOIN products p ON p.id = td.product_id
+			WHERE t.created_at >= $4::timestamptz AND t.created_at < $5::timestamptz
+			  AND ($6::int IS NULL OR t.outlet_id = $6)
+			  AND ` + inCategory("p.category_id", "$7") + `
+			GROUP BY 1`
+	}
+	rows, err := r.db.Query(`
+		WITH buckets AS (
+			SELECT generate_series(
+				date_trunc($3, $1::timestamp),
+				$2::timestamp - interval '1 microsecond',
+				('1 ' || $3)::interval
+			) AS bucket
+		),
+		sales AS (`+sales+`
+		),
+		items AS (
+			SELECT date_trunc($3, `+local+`) AS bucket, SUM(td.quantity) AS quantity
+			FROM transaction_details td
+			JOIN transactions t ON t.id = td.transaction_id
+			JOIN products p ON p.id = td.product_id
+			WHERE t.created_at >= $4::timestamptz AND t.created_at < $5::timestamptz
+			  AND ($6::int IS NULL OR t.outlet_id = $6)
+			  AND `+inCategory("p.category_id", "$7")+`
+			GROUP BY 1
+		)
+		SELECT `+r.calendar.instant("b.bucket", shifted)+`, COALESCE(s.transactions, 0), COALESCE(s.revenue, 0), COALESCE(i.quantity, 0)
+		FROM buckets b
+		LEFT JOIN sales s ON s.bucket = b.bucket
+		LEFT JOIN items i ON i.bucket = b.bucket
+		ORDER BY b.bucket ASC
+	`, lower.Format(wallClock), upper.Format(wallClock), interval, period.from, period.to, outletID, categoryID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var point models.SalesSeriesPoint
+		err := rows.Scan(&point.BucketStart, &point.TransactionCount, &point.Revenue, &point.ItemsSold)
+		if err != nil {
+			return err
+		}
+		point.BucketStart = point.BucketStart.In(r.calendar.location)
+		if point.TransactionCount > 0 {
+			point.AverageBasketValue = point.Revenue / point.TransactionCount
+			point.AverageBasketSize = math.Round(point.ItemsSold.Float64()/float64(point.TransactionCount)*100) / 100
+		}
+		if err := fn(point); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetTransactionReport returns the sales summary for the business days from
+// start through end. Zero dates mean today; a nil outletID means all outlets.
+// A non-nil categoryID sums only the lines of products in that category or
+// below it, and counts the transactions that have any.
+func (r *ReportRepository) GetTransactionReport(start time.Time, end time.Time, outletID *int, categoryID *int) (*models.TransactionReport, error) {
+	period := r.period(start, end, outletID, categoryID)
+	condition, args := period.condition(nil)
+
+	// Get total revenue and transaction count for the period
+	var revenue, totalTransaction int
+	query := `
+		SELECT 
+			COALESCE(SUM(t.total_amount), 0), 
+			COUNT(*) 
+		FROM transactions t
+		WHERE ` + condition
+	if categoryID != nil {
+		query = `
+		SELECT COALESCE(` + netRevenue + `, 0), COUNT(DISTINCT t.id)
+		FROM transaction_details td
+		JOIN transactions t ON t.id = td.transaction_id
+		JOIN products p ON p.id = td.product_id
+		WHERE ` + condition
+	}
+	err := r.db.QueryRow(query, args...).Scan(&revenue, &totalTransaction)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get cost of goods sold for the period
+	var totalCOGS int
+	err = r.db.QueryRow(`
+		SELECT COALESCE(ROUND(SUM(td.quantity * td.unit_cost))::bigint, 0)
+		FROM transaction_details td
+		JOIN transactions t ON t.id = td.transaction_id
+		JOIN products p ON p.id = td.product_id
+		WHERE `+condition, args...).Scan(&totalCOGS)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get best-selling product (by quantity sold in the period). If no product
+	// sold, keep bestSelling as zero value.
+	top, _, err := querySalesBreakdown(r.db, period, salesBreakdownQuery{dimension: "product", rankBy: "quantity", soldOnly: true, limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	var bestSelling models.BestSellingProduct
+	if len(top) > 0 {
+		bestSelling = models.BestSellingProduct{ProductName: top[0].Name, QuantitySold: top[0].QuantitySold}
+	}
+
+	return &models.TransactionReport{
+		TotalRevenue:       revenue,
+		TotalTransaction:   totalTransaction,
+		TotalCOGS:          totalCOGS,
+		GrossProfit:        revenue - totalCOGS,
+		MarginPercent:      marginPercent(revenue, revenue-totalCOGS),
+		BestSellingProduct: bestSelling,
+	}, nil
+}
+
+// GetTransactions lists the transactions of the business days from start
+// through end with their details, oldest first. Filtered by category, only
+// the lines of products in it are listed.
+func (r *ReportRepository) GetTransactions(start time.Time, end time.Time, outletID *int, categoryID *int) ([]models.Transaction, error) {
+	transactions := make([]models.Transaction, 0)
+	err := r.EachTransactionLine(start, end, outletID, categoryID, func(transaction models.Transaction, detail models.TransactionDetail) error {
+		if n := len(transactions); n > 0 && transactions[n-1].ID == transaction.ID {
+			transactions[n-1].Details = append(transactions[n-1].Details, detail)
+			return nil
+		}
+		transaction.Details = []models.TransactionDetail{detail}
+		transactions = append(transactions, transaction)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// EachTransactionLine calls fn for every transaction line of the period as it
+// is read, together with its transaction. Lines of a transaction are adjacent.
+func (r *ReportRepository) EachTransactionLine(start time.Time, end time.Time, outletID *int, categoryID *int, fn func(models.Transaction, models.TransactionDetail) error) error {
+	condition, args := r.period(start, end, outletID, categoryID).condition(nil)
+	rows, err := r.db.Query(`
+		SELECT t.id, t.customer_id, t.cashier_id, t.outlet_id, t.shift_id, t.payment_method, t.total_amount, t.discount_amount, t.points_redeemed,
+		       t.points_payment, t.points_earned, t.price_list_id, t.created_at,
+		       td.id, td.product_id, p.name, td.quantity, td.subtotal, td.unit_cost, td.bundle_id, td.bundle_quantity
+		FROM transactions t
+		JOIN transaction_details td ON td.transaction_id = t.id
+		JOIN products p ON p.id = td.product_id
+		WHERE `+condition+`
+		ORDER BY t.created_at ASC, t.id ASC, td.id ASC`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		var detail models.TransactionDetail
+		var customer, cashier sql.NullInt64
+		err := rows.Scan(&transaction.ID, &customer, &cashier, &transaction.OutletID, &transaction.ShiftID, &transaction.PaymentMethod, &transaction.TotalAmount, &transaction.DiscountAmount,
+			&transaction.PointsRedeemed, &transaction.PointsPayment, &transaction.PointsEarned, &transaction.PriceListID, &transaction.CreatedAt,
+			&detail.ID, &detail.ProductID, &detail.ProductName, &detail.Quantity, &detail.Subtotal, &detail.UnitCost, &detail.BundleID, &detail.BundleQuantity)
+		if err != nil {
+			return err
+		}
+		if customer.Valid {
+			id := int(customer.Int64)
+			transaction.CustomerID = &id
+		}
+		if cashier.Valid {
+			id := int(cashier.Int64)
+			transaction.CashierID = &id
+		}
+		detail.TransactionID = transaction.ID
+		if err := fn(transaction, detail); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// marginPercent returns profit as a percentage of revenue, rounded to two
+// decimals.
+func marginPercent(revenue int, profit int) float64 {
+	if revenue == 0 {
+		return 0
+	}
+	return math.Round(float64(profit)/float64(revenue)*10000) / 100
+}
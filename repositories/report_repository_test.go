@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeriesBuckets(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		start, end string
+		interval   string
+		want       int
+	}{
+		{"2026-03-02", "2026-03-02", "hour", 24},
+		{"2026-03-01", "2026-03-31", "day", 31},
+		// 2026-03-04 is a Wednesday; its week starts on 2 March.
+		{"2026-03-04", "2026-03-08", "week", 1},
+		{"2026-03-04", "2026-03-09", "week", 2},
+		{"2026-01-31", "2026-03-01", "month", 3},
+		{"2025-12-15", "2026-01-15", "month", 2},
+	}
+	for _, tt := range tests {
+		if got := seriesBuckets(date(tt.start), date(tt.end), tt.interval); got != tt.want {
+			t.Errorf("seriesBuckets(%s, %s, %s) = %d, want %d", tt.start, tt.end, tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSalesSeries(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := CheckSalesSeries(start, start.AddDate(1, 0, 0), "day"); err != nil {
+		t.Errorf("a year by the day was rejected: %v", err)
+	}
+	if err := CheckSalesSeries(start, start.AddDate(1, 0, 0), "hour"); err == nil {
+		t.Error("a year by the hour was accepted")
+	}
+	if err := CheckSalesSeries(start, start, "quarter"); err == nil {
+		t.Error("an unknown interval was accepted")
+	}
+}
@@ -0,0 +1,301 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+	"strconv"
+)
+
+type ShiftRepository struct {
+	db *sql.DB
+}
+
+func NewShiftRepository(db *sql.DB) *ShiftRepository {
+	return &ShiftRepository{db: db}
+}
+
+const shiftColumns = `s.id, s.cashier_id, c.name, s.outlet_id, s.status, s.opening_float, s.expected_cash, s.counted_cash,
+	s.notes, s.closing_notes, s.opened_at, s.closed_at`
+
+const shiftFrom = " FROM cashier_shifts s JOIN cashiers c ON c.id = s.cashier_id"
+
+// GetAll lists shifts, newest first. A non-zero cashierID keeps only that
+// cashier's shifts.
+func (r *ShiftRepository) GetAll(status string, cashierID int) ([]models.Shift, error) {
+	args := []interface{}{}
+	query := "SELECT " + shiftColumns + shiftFrom + " WHERE 1 = 1"
+	if status != "" {
+		args = append(args, status)
+		query += " AND s.status = $" + strconv.Itoa(len(args))
+	}
+	if cashierID != 0 {
+		args = append(args, cashierID)
+		query += " AND s.cashier_id = $" + strconv.Itoa(len(args))
+	}
+	query += " ORDER BY s.id DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shifts := make([]models.Shift, 0)
+	for rows.Next() {
+		shift, err := scanShift(rows)
+		if err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, shift)
+	}
+	return shifts, rows.Err()
+}
+
+func (r *ShiftRepository) GetByID(id int) (*models.Shift, error) {
+	shift, err := scanShift(r.db.QueryRow("SELECT "+shiftColumns+shiftFrom+" WHERE s.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("shift not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// Open starts a shift for a cashier. A cashier can only have one open shift
+// at a time.
+func (r *ShiftRepository) Open(req models.OpenShiftRequest) (*models.Shift, error) {
+	if req.OpeningFloat < 0 {
+		return nil, errors.New("Opening float cannot be negative")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Locking the cashier row serializes concurrent attempts to open a shift.
+	var active bool
+	var cashierOutlet sql.NullInt64
+	err = tx.QueryRow("SELECT active, outlet_id FROM cashiers WHERE id = $1 FOR UPDATE", req.CashierID).Scan(&active, &cashierOutlet)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("Cashier not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, errors.New("Cashier is inactive")
+	}
+
+	outletID := req.OutletID
+	if cashierOutlet.Valid {
+		id := int(cashierOutlet.Int64)
+		if outletID != nil && *outletID != id {
+			return nil, errors.New("Cashier does not work at this outlet")
+		}
+		outletID = &id
+	}
+	outlet, err := resolveOutlet(tx, outletID)
+	if err != nil {
+		return nil, err
+	}
+
+	var open bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM cashier_shifts WHERE cashier_id = $1 AND status = $2)",
+		req.CashierID, models.ShiftOpen).Scan(&open)
+	if err != nil {
+		return nil, err
+	}
+	if open {
+		return nil, errors.New("Cashier already has an open shift")
+	}
+
+	var id int
+	err = tx.QueryRow(`INSERT INTO cashier_shifts (cashier_id, outlet_id, status, opening_float, notes)
+	                   VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		req.CashierID, outlet, models.ShiftOpen, req.OpeningFloat, req.Notes).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// AddCashMovement records a pay-in or pay-out on an open shift.
+func (r *ShiftRepository) AddCashMovement(shiftID int, movement models.CashMovement) (*models.CashMovement, error) {
+	if movement.Type != models.CashPayIn && movement.Type != models.CashPayOut {
+		return nil, errors.New("type must be pay_in or pay_out")
+	}
+	if movement.Amount <= 0 {
+		return nil, errors.New("Amount must be greater than zero")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenShift(tx, shiftID, "FOR SHARE"); err != nil {
+		return nil, err
+	}
+
+	movement.ShiftID = shiftID
+	err = tx.QueryRow(`INSERT INTO shift_cash_movements (shift_id, type, amount, reason)
+	                   VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		shiftID, movement.Type, movement.Amount, movement.Reason).Scan(&movement.ID, &movement.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+// Close records the counted cash and closes the shift. The returned report
+// is the shift's Z report. Sales and pay-ins hold a share lock on the shift,
+// so none can be booked to it once this has the row.
+func (r *ShiftRepository) Close(id int, req models.CloseShiftRequest) (*models.ShiftReport, error) {
+	if req.CountedCash == nil {
+		return nil, errors.New("counted_cash is required")
+	}
+	if *req.CountedCash < 0 {
+		return nil, errors.New("Counted cash cannot be negative")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenShift(tx, id, "FOR UPDATE"); err != nil {
+		return nil, err
+	}
+	shift, err := scanShift(tx.QueryRow("SELECT "+shiftColumns+shiftFrom+" WHERE s.id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	report, err := buildShiftReport(tx, shift)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE cashier_shifts SET status = $2, expected_cash = $3, counted_cash = $4, closing_notes = $5, closed_at = NOW()
+	                  WHERE id = $1`, id, models.ShiftClosed, report.ExpectedCash, *req.CountedCash, req.Notes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetReport(id)
+}
+
+// GetReport returns an X report for an open shift and the Z report for a
+// closed one.
+func (r *ShiftRepository) GetReport(id int) (*models.ShiftReport, error) {
+	shift, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return buildShiftReport(r.db, *shift)
+}
+
+type shiftQueryer interface {
+	queryer
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func buildShiftReport(q shiftQueryer, shift models.Shift) (*models.ShiftReport, error) {
+	report := &models.ShiftReport{Type: models.ShiftReportX, Shift: shift, CashMovements: make([]models.CashMovement, 0)}
+
+	// What the customer paid with points never reaches the drawer.
+	err := q.QueryRow(`SELECT COUNT(*),
+	                          COALESCE(SUM(total_amount - points_payment), 0),
+	                          COALESCE(SUM(total_amount - points_payment) FILTER (WHERE payment_method = $2), 0),
+	                          COALESCE(SUM(points_payment), 0)
+	                   FROM transactions
+	                   WHERE shift_id = $1`, shift.ID, models.PaymentCash).
+		Scan(&report.TransactionCount, &report.TotalSales, &report.CashSales, &report.PointsPayments)
+	if err != nil {
+		return nil, err
+	}
+	report.NonCashSales = report.TotalSales - report.CashSales
+
+	rows, err := q.Query(`SELECT id, shift_id, type, amount, reason, created_at
+	                      FROM shift_cash_movements
+	                      WHERE shift_id = $1
+	                      ORDER BY id`, shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var movement models.CashMovement
+		err := rows.Scan(&movement.ID, &movement.ShiftID, &movement.Type, &movement.Amount, &movement.Reason, &movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if movement.Type == models.CashPayIn {
+			report.PayIns += movement.Amount
+		} else {
+			report.PayOuts += movement.Amount
+		}
+		report.CashMovements = append(report.CashMovements, movement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.ExpectedCash = shift.OpeningFloat + report.CashSales + report.PayIns - report.PayOuts
+	if shift.Status == models.ShiftClosed {
+		report.Type = models.ShiftReportZ
+		report.CountedCash = shift.CountedCash
+		if shift.CountedCash != nil {
+			overShort := *shift.CountedCash - report.ExpectedCash
+			report.OverShort = &overShort
+		}
+	}
+	return report, nil
+}
+
+// lockOpenShift locks the shift row with the given lock clause and checks
+// that the shift is still open.
+func lockOpenShift(tx *sql.Tx, id int, lock string) error {
+	var status string
+	err := tx.QueryRow("SELECT status FROM cashier_shifts WHERE id = $1 "+lock, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return errors.New("shift not found")
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.ShiftOpen {
+		return errors.New("shift is " + status)
+	}
+	return nil
+}
+
+func scanShift(row rowScanner) (models.Shift, error) {
+	var shift models.Shift
+	var closedAt sql.NullTime
+	err := row.Scan(&shift.ID, &shift.CashierID, &shift.CashierName, &shift.OutletID, &shift.Status, &shift.OpeningFloat,
+		&shift.ExpectedCash, &shift.CountedCash, &shift.Notes, &shift.ClosingNotes, &shift.OpenedAt, &closedAt)
+	if err != nil {
+		return models.Shift{}, err
+	}
+	if closedAt.Valid {
+		shift.ClosedAt = &closedAt.Time
+	}
+	return shift, nil
+}
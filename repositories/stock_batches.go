@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Batches track the lots of a product at an outlet. Stock that arrived
+// without a lot is in no batch, so an outlet's batches add up to at most its
+// stock. Stock leaves first-expired-first-out: batches by expiry date, those
+// without one after the rest, and stock outside of any batch last. Batches
+// are only written while the product's row is locked, like its stock.
+
+// stockBatch is a batch with stock left, as taking stock needs it.
+type stockBatch struct {
+	id        int
+	productID int
+	lotNumber string
+	expiry    *time.Time
+	quantity  models.Quantity
+}
+
+// batchTake is a quantity taken from a batch.
+type batchTake struct {
+	batch    stockBatch
+	quantity models.Quantity
+}
+
+// outletBatches returns the batches with stock left of products at an
+// outlet, keyed by product and in the order stock leaves them.
+func outletBatches(tx *sql.Tx, outletID int, productIDs []int64) (map[int][]stockBatch, error) {
+	rows, err := tx.Query(`SELECT id, product_id, lot_number, expiry_date, quantity
+	                       FROM stock_batches
+	                       WHERE outlet_id = $1 AND product_id = ANY($2) AND quantity > 0
+	                       ORDER BY product_id, expiry_date ASC NULLS LAST, id`, outletID, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := make(map[int][]stockBatch)
+	for rows.Next() {
+		var batch stockBatch
+		if err := rows.Scan(&batch.id, &batch.productID, &batch.lotNumber, &batch.expiry, &batch.quantity); err != nil {
+			return nil, err
+		}
+		batches[batch.productID] = append(batches[batch.productID], batch)
+	}
+	return batches, rows.Err()
+}
+
+// isExpired reports whether a batch expired before today.
+func (b stockBatch) isExpired(today time.Time) bool {
+	return b.expiry != nil && b.expiry.Before(today)
+}
+
+// expiredQuantity is the stock in batches that expired before today.
+func expiredQuantity(batches []stockBatch, today time.Time) models.Quantity {
+	var expired models.Quantity
+	for _, batch := range batches {
+		if batch.isExpired(today) {
+			expired += batch.quantity
+		}
+	}
+	return expired
+}
+
+// takeBatches picks which batches a quantity leaves, in order. With today
+// set, batches that expired before it are passed over. What the batches do
+// not cover comes from stock outside of any batch and is not returned.
+func takeBatches(batches []stockBatch, quantity models.Quantity, today *time.Time) []batchTake {
+	var takes []batchTake
+	for _, batch := range batches {
+		if quantity <= 0 {
+			break
+		}
+		if today != nil && batch.isExpired(*today) {
+			continue
+		}
+		take := batch.quantity
+		if take > quantity {
+			take = quantity
+		}
+		takes = append(takes, batchTake{batch: batch, quantity: take})
+		quantity -= take
+	}
+	return takes
+}
+
+// saveBatchTakes takes stock out of batches.
+func saveBatchTakes(tx *sql.Tx, takes []batchTake) error {
+	if len(takes) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(takes))
+	quantities := make([]models.Quantity, len(takes))
+	for i, take := range takes {
+		ids[i] = int64(take.batch.id)
+		quantities[i] = take.quantity
+	}
+	_, err := tx.Exec(`UPDATE stock_batches b SET quantity = b.quantity - v.quantity
+	                   FROM unnest($1::int[], $2::numeric[]) AS v(id, quantity)
+	                   WHERE b.id = v.id`, pq.Array(ids), pq.Array(quantities))
+	return err
+}
+
+// checkLots validates the lots a quantity of a product arrives in. Lots are
+// optional, but when given they must add up to the whole quantity.
+func checkLots(lots []models.LotQuantity, quantity models.Quantity) error {
+	if len(lots) == 0 {
+		return nil
+	}
+	var total models.Quantity
+	for _, lot := range lots {
+		if strings.TrimSpace(lot.LotNumber) == "" {
+			return errors.New("Lot number is required")
+		}
+		if lot.Quantity <= 0 {
+			return errors.New("Quantity of lot " + lot.LotNumber + " must be greater than zero")
+		}
+		total += lot.Quantity
+	}
+	if total != quantity {
+		return errors.New("Lots must add up to the quantity received")
+	}
+	return nil
+}
+
+// insertBatches adds lots of a product to an outlet's stock as batches. The
+// stock itself is added by adjustOutletStock.
+func insertBatches(tx *sql.Tx, outletID int, productID int, goodsReceiptItemID *int, lots []models.LotQuantity) ([]models.StockBatch, error) {
+	batches := make([]models.StockBatch, 0, len(lots))
+	for _, lot := range lots {
+		batch := models.StockBatch{
+			ProductID:          productID,
+			OutletID:           outletID,
+			LotNumber:          strings.TrimSpace(lot.LotNumber),
+			ExpiryDate:         lot.ExpiryDate,
+			Quantity:           lot.Quantity,
+			ReceivedQuantity:   lot.Quantity,
+			GoodsReceiptItemID: goodsReceiptItemID,
+		}
+		err := tx.QueryRow(`INSERT INTO stock_batches (product_id, outlet_id, lot_number, expiry_date, quantity, received_quantity, goods_receipt_item_id)
+		                    VALUES ($1, $2, $3, $4, $5, $5, $6) RETURNING id, received_at`,
+			productID, outletID, batch.LotNumber, lot.ExpiryDate, lot.Quantity, goodsReceiptItemID).Scan(&batch.ID, &batch.ReceivedAt)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+const stockBatchColumns = `b.id, b.product_id, p.name, b.outlet_id, b.lot_number, b.expiry_date, b.quantity, b.received_quantity,
+	b.goods_receipt_item_id, b.received_at`
+
+func scanStockBatch(row rowScanner) (models.StockBatch, error) {
+	var batch models.StockBatch
+	err := row.Scan(&batch.ID, &batch.ProductID, &batch.ProductName, &batch.OutletID, &batch.LotNumber, &batch.ExpiryDate,
+		&batch.Quantity, &batch.ReceivedQuantity, &batch.GoodsReceiptItemID, &batch.ReceivedAt)
+	return batch, err
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"go-kasir-api/models"
+	"testing"
+	"time"
+)
+
+func TestTakeBatches(t *testing.T) {
+	date := func(day int) *time.Time {
+		d := time.Date(2026, time.March, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	today := *date(10)
+	batches := []stockBatch{
+		{id: 1, expiry: date(9), quantity: models.Whole(4)},
+		{id: 2, expiry: date(10), quantity: models.Whole(3)},
+		{id: 3, expiry: date(20), quantity: models.Whole(5)},
+		{id: 4, quantity: models.Whole(2)},
+	}
+
+	if got := expiredQuantity(batches, today); got != models.Whole(4) {
+		t.Errorf("expiredQuantity = %s, want 4", got)
+	}
+
+	tests := []struct {
+		name     string
+		quantity models.Quantity
+		today    *time.Time
+		want     map[int]models.Quantity
+	}{
+		{"sale skips expired", models.Whole(6), &today, map[int]models.Quantity{2: models.Whole(3), 3: models.Whole(3)}},
+		{"write-off takes expired first", models.Whole(6), nil, map[int]models.Quantity{1: models.Whole(4), 2: models.Whole(2)}},
+		{"rest is outside batches", models.Whole(20), &today, map[int]models.Quantity{2: models.Whole(3), 3: models.Whole(5), 4: models.Whole(2)}},
+	}
+	for _, tt := range tests {
+		takes := takeBatches(batches, tt.quantity, tt.today)
+		if len(takes) != len(tt.want) {
+			t.Errorf("%s: took from %d batches, want %d", tt.name, len(takes), len(tt.want))
+			continue
+		}
+		for _, take := range takes {
+			if want := tt.want[take.batch.id]; take.quantity != want {
+				t.Errorf("%s: took %s from batch %d, want %s", tt.name, take.quantity, take.batch.id, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"database/sql"
+	"go-kasir-api/models"
+
+	"github.com/lib/pq"
+)
+
+type StockMovementRepository struct {
+	db *sql.DB
+}
+
+func NewStockMovementRepository(db *sql.DB) *StockMovementRepository {
+	return &StockMovementRepository{db: db}
+}
+
+func (r *StockMovementRepository) GetByProductID(productID int) ([]models.StockMovement, error) {
+	rows, err := r.db.Query(`SELECT id, product_id, outlet_id, quantity, movement_type, reference_type, reference_id, unit_cost, changed_by, created_at
+	                         FROM stock_movements
+	                         WHERE product_id = $1
+	                         ORDER BY created_at DESC, id DESC`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := make([]models.StockMovement, 0)
+	for rows.Next() {
+		var movement models.StockMovement
+		var unitCost sql.NullInt64
+		err := rows.Scan(&movement.ID, &movement.ProductID, &movement.OutletID, &movement.Quantity, &movement.MovementType,
+			&movement.ReferenceType, &movement.ReferenceID, &unitCost, &movement.ChangedBy, &movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if unitCost.Valid {
+			cost := int(unitCost.Int64)
+			movement.UnitCost = &cost
+		}
+		movements = append(movements, movement)
+	}
+	return movements, rows.Err()
+}
+
+// recordStockMovements writes all movements of one document in a single
+// INSERT inside the caller's transaction.
+func recordStockMovements(tx *sql.Tx, movements []models.StockMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+
+	productIDs := make([]int64, len(movements))
+	outletIDs := make([]int64, len(movements))
+	quantities := make([]models.Quantity, len(movements))
+	types := make([]string, len(movements))
+	referenceTypes := make([]string, len(movements))
+	referenceIDs := make([]int64, len(movements))
+	unitCosts := make([]sql.NullInt64, len(movements))
+	changedBy := make([]string, len(movements))
+	for i, movement := range movements {
+		productIDs[i] = int64(movement.ProductID)
+		outletIDs[i] = int64(movement.OutletID)
+		quantities[i] = movement.Quantity
+		types[i] = movement.MovementType
+		referenceTypes[i] = movement.ReferenceType
+		referenceIDs[i] = int64(movement.ReferenceID)
+		if movement.UnitCost != nil {
+			unitCosts[i] = sql.NullInt64{Int64: int64(*movement.UnitCost), Valid: true}
+		}
+		changedBy[i] = movement.ChangedBy
+	}
+
+	_, err := tx.Exec(`INSERT INTO stock_movements (product_id, outlet_id, quantity, movement_type, reference_type, reference_id, unit_cost, changed_by)
+	                   SELECT * FROM unnest($1::int[], $2::int[], $3::numeric[], $4::text[], $5::text[], $6::int[], $7::int[], $8::text[])`,
+		pq.Array(productIDs), pq.Array(outletIDs), pq.Array(quantities), pq.Array(types), pq.Array(referenceTypes),
+		pq.Array(referenceIDs), pq.Array(unitCosts), pq.Array(changedBy))
+	return err
+}
@@ -0,0 +1,568 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+type StockTransferRepository struct {
+	db *sql.DB
+}
+
+func NewStockTransferRepository(db *sql.DB) *StockTransferRepository {
+	return &StockTransferRepository{db: db}
+}
+
+const stockTransferColumns = `id, source_outlet_id, destination_outlet_id, status, notes, receive_notes,
+	created_at, updated_at, dispatched_at, received_at`
+
+// GetAll lists transfers, newest first. A non-zero outletID keeps transfers
+// into or out of that outlet.
+func (r *StockTransferRepository) GetAll(status string, outletID int) ([]models.StockTransfer, error) {
+	args := []interface{}{}
+	query := "SELECT " + stockTransferColumns + " FROM stock_transfers WHERE 1 = 1"
+	if status != "" {
+		args = append(args, status)
+		query += " AND status = $" + strconv.Itoa(len(args))
+	}
+	if outletID != 0 {
+		args = append(args, outletID)
+		n := strconv.Itoa(len(args))
+		query += " AND (source_outlet_id = $" + n + " OR destination_outlet_id = $" + n + ")"
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]models.StockTransfer, 0)
+	index := make(map[int]int)
+	ids := make([]int64, 0)
+	for rows.Next() {
+		transfer, err := scanStockTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[transfer.ID] = len(transfers)
+		ids = append(ids, int64(transfer.ID))
+		transfers = append(transfers, transfer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items, err := getStockTransferItems(r.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		i := index[item.StockTransferID]
+		transfers[i].Items = append(transfers[i].Items, item)
+	}
+	return transfers, nil
+}
+
+func (r *StockTransferRepository) GetByID(id int) (*models.StockTransfer, error) {
+	transfer, err := scanStockTransfer(r.db.QueryRow("SELECT "+stockTransferColumns+" FROM stock_transfers WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("stock transfer not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transfer.Items, err = getStockTransferItems(r.db, []int64{int64(id)})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (r *StockTransferRepository) Create(transfer models.StockTransfer) (*models.StockTransfer, error) {
+	items, err := mergeStockTransferItems(transfer.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkTransferOutlets(tx, transfer); err != nil {
+		return nil, err
+	}
+
+	var id int
+	err = tx.QueryRow(`INSERT INTO stock_transfers (source_outlet_id, destination_outlet_id, status, notes)
+	                   VALUES ($1, $2, $3, $4) RETURNING id`,
+		transfer.SourceOutletID, transfer.DestinationOutletID, models.StockTransferDraft, transfer.Notes).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertStockTransferItems(tx, id, items); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// Update replaces the outlets, notes and lines of a draft transfer.
+func (r *StockTransferRepository) Update(id int, transfer models.StockTransfer) (*models.StockTransfer, error) {
+	items, err := mergeStockTransferItems(transfer.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, _, err := requireStockTransferStatus(tx, id, models.StockTransferDraft); err != nil {
+		return nil, err
+	}
+	if err := checkTransferOutlets(tx, transfer); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE stock_transfers SET source_outlet_id = $2, destination_outlet_id = $3, notes = $4, updated_at = NOW()
+	                  WHERE id = $1`, id, transfer.SourceOutletID, transfer.DestinationOutletID, transfer.Notes)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM stock_transfer_items WHERE stock_transfer_id = $1", id); err != nil {
+		return nil, err
+	}
+	if err := insertStockTransferItems(tx, id, items); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// Delete removes a draft transfer. Dispatched transfers have moved stock and
+// are kept.
+func (r *StockTransferRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, _, err := requireStockTransferStatus(tx, id, models.StockTransferDraft); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM stock_transfer_items WHERE stock_transfer_id = $1", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM stock_transfers WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Dispatch takes the goods out of the source outlet's stock and puts the
+// transfer in transit. Each line records the product's cost price at that
+// moment and the lots the goods left. It fails without changing anything if
+// the source is short of any product.
+func (r *StockTransferRepository) Dispatch(id int) (*models.StockTransfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	source, _, err := requireStockTransferStatus(tx, id, models.StockTransferDraft)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := resolveOutlet(tx, &source); err != nil {
+		return nil, err
+	}
+
+	items, err := getStockTransferItems(tx, []int64{int64(id)})
+	if err != nil {
+		return nil, err
+	}
+	productIDs := make([]int64, len(items))
+	for i, item := range items {
+		productIDs[i] = int64(item.ProductID)
+	}
+
+	// Lock products in ID order, the same order checkout uses.
+	costs, err := lockProductCosts(tx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	atSource, err := outletProducts(tx, source, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	deltas := make([]models.Quantity, len(items))
+	unitCosts := make([]int64, len(items))
+	movements := make([]models.StockMovement, len(items))
+	for i, item := range items {
+		if atSource[item.ProductID].stock < item.Quantity {
+			return nil, errors.New("Insufficient stock of product " + strconv.Itoa(item.ProductID) + " at the source outlet")
+		}
+		unitCost := costs[item.ProductID]
+		deltas[i] = -item.Quantity
+		unitCosts[i] = int64(unitCost)
+		movements[i] = models.StockMovement{
+			ProductID:     item.ProductID,
+			OutletID:      source,
+			Quantity:      -item.Quantity,
+			MovementType:  models.StockMovementTransferOut,
+			ReferenceType: "stock_transfer",
+			ReferenceID:   id,
+			UnitCost:      &unitCost,
+		}
+	}
+
+	takes, err := adjustOutletStock(tx, source, productIDs, deltas)
+	if err != nil {
+		return nil, err
+	}
+	for _, take := range takes {
+		_, err := tx.Exec(`INSERT INTO stock_transfer_lots (stock_transfer_id, product_id, lot_number, expiry_date, quantity)
+		                   VALUES ($1, $2, $3, $4, $5)`, id, take.batch.productID, take.batch.lotNumber, take.batch.expiry, take.quantity)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(`UPDATE stock_transfer_items i SET unit_cost = v.unit_cost
+	                  FROM unnest($2::int[], $3::int[]) AS v(product_id, unit_cost)
+	                  WHERE i.stock_transfer_id = $1 AND i.product_id = v.product_id`,
+		id, pq.Array(productIDs), pq.Array(unitCosts))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordStockMovements(tx, movements); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE stock_transfers SET status = $2, dispatched_at = NOW(), updated_at = NOW() WHERE id = $1",
+		id, models.StockTransferInTransit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// Receive books what arrived at the destination outlet and closes the
+// transfer. A shortage is stored on the line as a discrepancy; the
+// destination is only credited with what arrived, which cannot be more than
+// was sent. The lots
+// that were sent become batches at the destination, earliest expiry first,
+// so a shortage is taken from the lots that expire last.
+func (r *StockTransferRepository) Receive(id int, req models.ReceiveTransferRequest) (*models.StockTransfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, destination, err := requireStockTransferStatus(tx, id, models.StockTransferInTransit)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := getStockTransferItems(tx, []int64{int64(id)})
+	if err != nil {
+		return nil, err
+	}
+	received := make(map[int]models.Quantity, len(items))
+	for _, item := range items {
+		received[item.ProductID] = item.Quantity
+	}
+	seen := make(map[int]bool, len(req.Items))
+	for _, item := range req.Items {
+		if _, ok := received[item.ProductID]; !ok {
+			return nil, errors.New("Product " + strconv.Itoa(item.ProductID) + " is not on this transfer")
+		}
+		if seen[item.ProductID] {
+			return nil, errors.New("Product " + strconv.Itoa(item.ProductID) + " is listed more than once")
+		}
+		seen[item.ProductID] = true
+		if item.Quantity < 0 {
+			return nil, errors.New("Received quantity cannot be negative")
+		}
+		// Goods that were never sent have no source or cost, so an overage
+		// cannot be received here; book it with a stocktake instead.
+		if sent := received[item.ProductID]; item.Quantity > sent {
+			return nil, errors.New("Product " + strconv.Itoa(item.ProductID) + " was sent " + sent.String() +
+				"; more than that cannot be received")
+		}
+		received[item.ProductID] = item.Quantity
+	}
+
+	productIDs := make([]int64, len(items))
+	quantities := make([]models.Quantity, len(items))
+	var creditedIDs []int64
+	var credited []models.Quantity
+	var movements []models.StockMovement
+	for i, item := range items {
+		quantity := received[item.ProductID]
+		productIDs[i] = int64(item.ProductID)
+		quantities[i] = quantity
+		if quantity == 0 {
+			continue
+		}
+		unitCost := item.UnitCost
+		creditedIDs = append(creditedIDs, int64(item.ProductID))
+		credited = append(credited, quantity)
+		movements = append(movements, models.StockMovement{
+			ProductID:     item.ProductID,
+			OutletID:      destination,
+			Quantity:      quantity,
+			MovementType:  models.StockMovementTransferIn,
+			ReferenceType: "stock_transfer",
+			ReferenceID:   id,
+			UnitCost:      &unitCost,
+		})
+	}
+
+	// Lock products in ID order, the same order checkout uses.
+	if _, err := lockProductCosts(tx, creditedIDs); err != nil {
+		return nil, err
+	}
+	if _, err := adjustOutletStock(tx, destination, creditedIDs, credited); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		left := received[item.ProductID]
+		var lots []models.LotQuantity
+		for _, lot := range item.Lots {
+			if left <= 0 {
+				break
+			}
+			if lot.Quantity > left {
+				lot.Quantity = left
+			}
+			left -= lot.Quantity
+			lots = append(lots, lot)
+		}
+		if _, err := insertBatches(tx, destination, item.ProductID, nil, lots); err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(`UPDATE stock_transfer_items i SET quantity_received = v.quantity
+	                  FROM unnest($2::int[], $3::numeric[]) AS v(product_id, quantity)
+	                  WHERE i.stock_transfer_id = $1 AND i.product_id = v.product_id`,
+		id, pq.Array(productIDs), pq.Array(quantities))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordStockMovements(tx, movements); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE stock_transfers SET status = $2, receive_notes = $3, received_at = NOW(), updated_at = NOW() WHERE id = $1",
+		id, models.StockTransferReceived, req.Notes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// lockProductCosts locks products in ID order and returns their cost prices.
+func lockProductCosts(tx *sql.Tx, productIDs []int64) (map[int]int, error) {
+	rows, err := tx.Query("SELECT id, cost_price FROM products WHERE id = ANY($1) ORDER BY id FOR UPDATE", pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	costs := make(map[int]int, len(productIDs))
+	for rows.Next() {
+		var id, cost int
+		if err := rows.Scan(&id, &cost); err != nil {
+			return nil, err
+		}
+		costs[id] = cost
+	}
+	return costs, rows.Err()
+}
+
+// checkTransferOutlets makes sure a transfer goes between two different,
+// active outlets.
+func checkTransferOutlets(tx *sql.Tx, transfer models.StockTransfer) error {
+	if transfer.SourceOutletID == 0 || transfer.DestinationOutletID == 0 {
+		return errors.New("source_outlet_id and destination_outlet_id are required")
+	}
+	if transfer.SourceOutletID == transfer.DestinationOutletID {
+		return errors.New("Source and destination outlets must differ")
+	}
+	if _, err := resolveOutlet(tx, &transfer.SourceOutletID); err != nil {
+		return err
+	}
+	_, err := resolveOutlet(tx, &transfer.DestinationOutletID)
+	return err
+}
+
+// requireStockTransferStatus locks the transfer row, checks that its status
+// is one of allowed and returns its source and destination outlets.
+func requireStockTransferStatus(tx *sql.Tx, id int, allowed ...string) (int, int, error) {
+	var status string
+	var source, destination int
+	err := tx.QueryRow("SELECT status, source_outlet_id, destination_outlet_id FROM stock_transfers WHERE id = $1 FOR UPDATE", id).
+		Scan(&status, &source, &destination)
+	if err == sql.ErrNoRows {
+		return 0, 0, errors.New("stock transfer not found")
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, s := range allowed {
+		if status == s {
+			return source, destination, nil
+		}
+	}
+	return 0, 0, errors.New("stock transfer is " + status)
+}
+
+func getStockTransferItems(q queryer, transferIDs []int64) ([]models.StockTransferItem, error) {
+	items := make([]models.StockTransferItem, 0)
+	if len(transferIDs) == 0 {
+		return items, nil
+	}
+
+	rows, err := q.Query(`SELECT i.id, i.stock_transfer_id, i.product_id, p.name, i.quantity, i.quantity_received, i.unit_cost
+	                      FROM stock_transfer_items i
+	                      JOIN products p ON p.id = i.product_id
+	                      WHERE i.stock_transfer_id = ANY($1)
+	                      ORDER BY i.stock_transfer_id, i.id`, pq.Array(transferIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.StockTransferItem
+		err := rows.Scan(&item.ID, &item.StockTransferID, &item.ProductID, &item.ProductName, &item.Quantity, &item.QuantityReceived, &item.UnitCost)
+		if err != nil {
+			return nil, err
+		}
+		if item.QuantityReceived != nil {
+			item.Discrepancy = *item.QuantityReceived - item.Quantity
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	lots, err := q.Query(`SELECT stock_transfer_id, product_id, lot_number, expiry_date, quantity
+	                      FROM stock_transfer_lots
+	                      WHERE stock_transfer_id = ANY($1)
+	                      ORDER BY stock_transfer_id, product_id, expiry_date ASC NULLS LAST, id`, pq.Array(transferIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer lots.Close()
+	type itemKey struct{ transferID, productID int }
+	byItem := make(map[itemKey][]models.LotQuantity)
+	for lots.Next() {
+		var key itemKey
+		var lot models.LotQuantity
+		if err := lots.Scan(&key.transferID, &key.productID, &lot.LotNumber, &lot.ExpiryDate, &lot.Quantity); err != nil {
+			return nil, err
+		}
+		byItem[key] = append(byItem[key], lot)
+	}
+	if err := lots.Err(); err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Lots = byItem[itemKey{items[i].StockTransferID, items[i].ProductID}]
+	}
+	return items, nil
+}
+
+func insertStockTransferItems(tx *sql.Tx, transferID int, items []models.StockTransferItem) error {
+	productIDs := make([]int64, len(items))
+	quantities := make([]models.Quantity, len(items))
+	for i, item := range items {
+		productIDs[i] = int64(item.ProductID)
+		quantities[i] = item.Quantity
+	}
+
+	result, err := tx.Exec(`INSERT INTO stock_transfer_items (stock_transfer_id, product_id, quantity)
+	                        SELECT $1, v.product_id, v.quantity
+	                        FROM unnest($2::int[], $3::numeric[]) AS v(product_id, quantity)
+	                        JOIN products p ON p.id = v.product_id`,
+		transferID, pq.Array(productIDs), pq.Array(quantities))
+	if err != nil {
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted != int64(len(items)) {
+		return errors.New("Product not found")
+	}
+	return nil
+}
+
+func mergeStockTransferItems(items []models.StockTransferItem) ([]models.StockTransferItem, error) {
+	if len(items) == 0 {
+		return nil, errors.New("Stock transfer must contain at least one item")
+	}
+
+	merged := make([]models.StockTransferItem, 0, len(items))
+	index := make(map[int]int, len(items))
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, errors.New("Quantity must be greater than zero")
+		}
+		if i, ok := index[item.ProductID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged, nil
+}
+
+func scanStockTransfer(row rowScanner) (models.StockTransfer, error) {
+	var transfer models.StockTransfer
+	var dispatchedAt, receivedAt sql.NullTime
+	err := row.Scan(&transfer.ID, &transfer.SourceOutletID, &transfer.DestinationOutletID, &transfer.Status, &transfer.Notes,
+		&transfer.ReceiveNotes, &transfer.CreatedAt, &transfer.UpdatedAt, &dispatchedAt, &receivedAt)
+	if err != nil {
+		return models.StockTransfer{}, err
+	}
+	if dispatchedAt.Valid {
+		transfer.DispatchedAt = &dispatchedAt.Time
+	}
+	if receivedAt.Valid {
+		transfer.ReceivedAt = &receivedAt.Time
+	}
+	transfer.Items = make([]models.StockTransferItem, 0)
+	return transfer, nil
+}
@@ -0,0 +1,452 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+type StocktakeRepository struct {
+	db *sql.DB
+}
+
+func NewStocktakeRepository(db *sql.DB) *StocktakeRepository {
+	return &StocktakeRepository{db: db}
+}
+
+const stocktakeColumns = `s.id, s.outlet_id, s.status, s.category_ids, s.notes, s.created_at, s.finalized_at,
+	(SELECT COUNT(*) FROM stocktake_items si WHERE si.stocktake_id = s.id)`
+
+func (r *StocktakeRepository) GetAll(status string) ([]models.Stocktake, error) {
+	args := []interface{}{}
+	query := "SELECT " + stocktakeColumns + " FROM stocktakes s"
+	if status != "" {
+		query += " WHERE s.status = $1"
+		args = append(args, status)
+	}
+	query += " ORDER BY s.id DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocktakes := make([]models.Stocktake, 0)
+	for rows.Next() {
+		stocktake, err := scanStocktake(rows)
+		if err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, stocktake)
+	}
+	return stocktakes, rows.Err()
+}
+
+func (r *StocktakeRepository) GetByID(id int) (*models.Stocktake, error) {
+	row := r.db.QueryRow("SELECT "+stocktakeColumns+" FROM stocktakes s WHERE s.id = $1", id)
+	stocktake, err := scanStocktake(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("stocktake not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stocktake, nil
+}
+
+// Open starts a count and snapshots the outlet's stock of every product in
+// scope: the products in the given categories or below them, or all products.
+// A product can only be part of one open stocktake per outlet at a time.
+func (r *StocktakeRepository) Open(stocktake models.Stocktake) (*models.Stocktake, error) {
+	categoryIDs := make([]int64, len(stocktake.CategoryIDs))
+	for i, id := range stocktake.CategoryIDs {
+		categoryIDs[i] = int64(id)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serialize concurrent opens so the overlap check below cannot race.
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('stocktakes'))"); err != nil {
+		return nil, err
+	}
+	var outletID int
+	if stocktake.OutletID == 0 {
+		outletID, err = resolveOutlet(tx, nil)
+	} else {
+		outletID, err = resolveOutlet(tx, &stocktake.OutletID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var overlapping bool
+	err = tx.QueryRow(`SELECT EXISTS (
+	                       SELECT 1
+	                       FROM stocktake_items si
+	                       JOIN stocktakes s ON s.id = si.stocktake_id
+	                       JOIN products p ON p.id = si.product_id
+	                       WHERE s.status = $1 AND s.outlet_id = $3
+	                         AND (cardinality($2::int[]) = 0 OR p.category_id IN (`+categorySubtrees("$2")+`))
+	                   )`, models.StocktakeOpen, pq.Array(categoryIDs), outletID).Scan(&overlapping)
+	if err != nil {
+		return nil, err
+	}
+	if overlapping {
+		return nil, errors.New("Another open stocktake already covers some of these products")
+	}
+
+	var id int
+	err = tx.QueryRow("INSERT INTO stocktakes (outlet_id, status, category_ids, notes) VALUES ($1, $2, $3, $4) RETURNING id",
+		outletID, models.StocktakeOpen, pq.Array(categoryIDs), stocktake.Notes).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`INSERT INTO stocktake_items (stocktake_id, product_id, snapshot_stock, expected_stock)
+	                        SELECT $1, p.id, COALESCE(os.stock, 0), COALESCE(os.stock, 0)
+	                        FROM products p
+	                        LEFT JOIN outlet_stock os ON os.product_id = p.id AND os.outlet_id = $3
+	                        WHERE p.options = '[]'::jsonb
+	                          AND NOT EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.bundle_id = p.id)
+	                          AND (cardinality($2::int[]) = 0 OR p.category_id IN (`+categorySubtrees("$2")+`))`, id, pq.Array(categoryIDs), outletID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if items == 0 {
+		return nil, errors.New("No products in the stocktake scope")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// SubmitCounts stores the counts of one device and records the outlet's stock
+// at that moment as the expected quantity. Several devices can submit at once.
+func (r *StocktakeRepository) SubmitCounts(id int, req models.StocktakeCountRequest) (*models.Stocktake, error) {
+	if req.DeviceID == "" {
+		return nil, errors.New("device_id is required")
+	}
+	if len(req.Counts) == 0 {
+		return nil, errors.New("Counts must contain at least one product")
+	}
+
+	// The same device may list a product twice, e.g. from two shelves.
+	totals := make(map[int]models.Quantity, len(req.Counts))
+	for _, count := range req.Counts {
+		if count.Quantity < 0 {
+			return nil, errors.New("Counted quantity cannot be negative")
+		}
+		totals[count.ProductID] += count.Quantity
+	}
+	productIDs := make([]int64, 0, len(totals))
+	for productID := range totals {
+		productIDs = append(productIDs, int64(productID))
+	}
+	sort.Slice(productIDs, func(i, j int) bool { return productIDs[i] < productIDs[j] })
+	quantities := make([]models.Quantity, len(productIDs))
+	for i, productID := range productIDs {
+		quantities[i] = totals[int(productID)]
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	outletID, err := lockStocktake(tx, id, "FOR SHARE")
+	if err != nil {
+		return nil, err
+	}
+
+	var inScope int
+	err = tx.QueryRow("SELECT COUNT(*) FROM stocktake_items WHERE stocktake_id = $1 AND product_id = ANY($2)",
+		id, pq.Array(productIDs)).Scan(&inScope)
+	if err != nil {
+		return nil, err
+	}
+	if inScope != len(productIDs) {
+		return nil, errors.New("Some products are not part of this stocktake")
+	}
+
+	_, err = tx.Exec(`INSERT INTO stocktake_counts (stocktake_id, product_id, device_id, quantity)
+	                  SELECT $1, v.product_id, $2, v.quantity
+	                  FROM unnest($3::int[], $4::numeric[]) AS v(product_id, quantity)
+	                  ON CONFLICT (stocktake_id, product_id, device_id)
+	                  DO UPDATE SET quantity = EXCLUDED.quantity, counted_at = NOW()`,
+		id, req.DeviceID, pq.Array(productIDs), pq.Array(quantities))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE stocktake_items si SET counted_at = NOW(),
+	                      expected_stock = COALESCE((SELECT os.stock FROM outlet_stock os WHERE os.outlet_id = $3 AND os.product_id = si.product_id), 0)
+	                  WHERE si.stocktake_id = $1 AND si.product_id = ANY($2)`,
+		id, pq.Array(productIDs), outletID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+func (r *StocktakeRepository) GetVariance(id int) (*models.StocktakeVariance, error) {
+	stocktake, err := r.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return getStocktakeVariance(r.db, stocktake.ID, stocktake.Status)
+}
+
+// EachVarianceLine calls fn for every line of a stocktake's variance report as
+// it is read.
+func (r *StocktakeRepository) EachVarianceLine(id int, fn func(models.StocktakeVarianceLine) error) error {
+	if _, err := r.GetByID(id); err != nil {
+		return err
+	}
+	return eachStocktakeVarianceLine(r.db, id, fn)
+}
+
+// Finalize posts the variance of every counted product as a stock adjustment
+// at the stocktake's outlet in one transaction. The variance is applied to the
+// current stock, so sales made after a product was counted are kept.
+func (r *StocktakeRepository) Finalize(id int, req models.FinalizeStocktakeRequest) (*models.StocktakeVariance, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	outletID, err := lockStocktake(tx, id, "FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+
+	variance, err := getStocktakeVariance(tx, id, models.StocktakeFinalized)
+	if err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]int64, 0, len(variance.Lines))
+	for _, line := range variance.Lines {
+		if line.CountedQuantity != nil || req.ZeroUncounted {
+			productIDs = append(productIDs, int64(line.ProductID))
+		}
+	}
+
+	rows, err := tx.Query("SELECT id FROM products WHERE id = ANY($1) ORDER BY id FOR UPDATE", pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	locked := make(map[int]bool, len(productIDs))
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		locked[productID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	atOutlet, err := outletProducts(tx, outletID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var adjustedIDs []int64
+	var deltas []models.Quantity
+	var movements []models.StockMovement
+	for _, line := range variance.Lines {
+		if !locked[line.ProductID] {
+			continue
+		}
+		stock := atOutlet[line.ProductID].stock
+		newStock := models.Quantity(0)
+		if line.CountedQuantity != nil {
+			newStock = stock + line.Variance
+			if newStock < 0 {
+				newStock = 0
+			}
+		}
+		delta := newStock - stock
+		if delta == 0 {
+			continue
+		}
+		unitCost := line.UnitCost
+		adjustedIDs = append(adjustedIDs, int64(line.ProductID))
+		deltas = append(deltas, delta)
+		movements = append(movements, models.StockMovement{
+			ProductID:     line.ProductID,
+			OutletID:      outletID,
+			Quantity:      delta,
+			MovementType:  models.StockMovementStocktakeAdjustment,
+			ReferenceType: "stocktake",
+			ReferenceID:   id,
+			UnitCost:      &unitCost,
+		})
+	}
+
+	if len(adjustedIDs) > 0 {
+		if _, err := adjustOutletStock(tx, outletID, adjustedIDs, deltas); err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`UPDATE stocktake_items si SET adjustment = v.delta
+		                  FROM unnest($2::int[], $3::numeric[]) AS v(product_id, delta)
+		                  WHERE si.stocktake_id = $1 AND si.product_id = v.product_id`,
+			id, pq.Array(adjustedIDs), pq.Array(deltas))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := recordStockMovements(tx, movements); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE stocktakes SET status = $2, finalized_at = NOW() WHERE id = $1", id, models.StocktakeFinalized)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return variance, nil
+}
+
+func (r *StocktakeRepository) Cancel(id int) (*models.Stocktake, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockStocktake(tx, id, "FOR UPDATE"); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE stocktakes SET status = $2 WHERE id = $1", id, models.StocktakeCancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// lockStocktake locks the stocktake row, checks that it is still open and
+// returns its outlet.
+func lockStocktake(tx *sql.Tx, id int, lock string) (int, error) {
+	var status string
+	var outletID int
+	err := tx.QueryRow("SELECT status, outlet_id FROM stocktakes WHERE id = $1 "+lock, id).Scan(&status, &outletID)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("stocktake not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if status != models.StocktakeOpen {
+		return 0, errors.New("stocktake is " + status)
+	}
+	return outletID, nil
+}
+
+// getStocktakeVariance values each line at the product's average cost price.
+func getStocktakeVariance(q queryer, id int, status string) (*models.StocktakeVariance, error) {
+	variance := &models.StocktakeVariance{StocktakeID: id, Status: status, Lines: make([]models.StocktakeVarianceLine, 0)}
+	err := eachStocktakeVarianceLine(q, id, func(line models.StocktakeVarianceLine) error {
+		if line.CountedQuantity != nil {
+			variance.CountedProducts++
+			variance.TotalVarianceQuantity += line.Variance
+			variance.TotalVarianceValue += line.VarianceValue
+		} else {
+			variance.UncountedProducts++
+		}
+		variance.Lines = append(variance.Lines, line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return variance, nil
+}
+
+func eachStocktakeVarianceLine(q queryer, id int, fn func(models.StocktakeVarianceLine) error) error {
+	rows, err := q.Query(`
+		SELECT si.product_id, p.name, p.category_id, si.snapshot_stock, si.expected_stock,
+		       c.counted, p.cost_price
+		FROM stocktake_items si
+		JOIN products p ON p.id = si.product_id
+		LEFT JOIN (
+			SELECT product_id, SUM(quantity) AS counted
+			FROM stocktake_counts
+			WHERE stocktake_id = $1
+			GROUP BY product_id
+		) c ON c.product_id = si.product_id
+		WHERE si.stocktake_id = $1
+		ORDER BY p.name ASC, p.id ASC
+	`, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.StocktakeVarianceLine
+		err := rows.Scan(&line.ProductID, &line.ProductName, &line.CategoryID, &line.SnapshotStock,
+			&line.ExpectedStock, &line.CountedQuantity, &line.UnitCost)
+		if err != nil {
+			return err
+		}
+		if line.CountedQuantity != nil {
+			line.Variance = *line.CountedQuantity - line.ExpectedStock
+			line.VarianceValue = roundAmount(line.Variance.Amount(line.UnitCost), 1)
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanStocktake(row rowScanner) (models.Stocktake, error) {
+	var stocktake models.Stocktake
+	var categoryIDs pq.Int64Array
+	var finalizedAt sql.NullTime
+	err := row.Scan(&stocktake.ID, &stocktake.OutletID, &stocktake.Status, &categoryIDs, &stocktake.Notes,
+		&stocktake.CreatedAt, &finalizedAt, &stocktake.ItemCount)
+	if err != nil {
+		return models.Stocktake{}, err
+	}
+	stocktake.CategoryIDs = make([]int, len(categoryIDs))
+	for i, id := range categoryIDs {
+		stocktake.CategoryIDs[i] = int(id)
+	}
+	if finalizedAt.Valid {
+		stocktake.FinalizedAt = &finalizedAt.Time
+	}
+	return stocktake, nil
+}
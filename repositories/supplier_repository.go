@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"go-kasir-api/models"
+)
+
+type SupplierRepository struct {
+	db *sql.DB
+}
+
+func NewSupplierRepository(db *sql.DB) *SupplierRepository {
+	return &SupplierRepository{db: db}
+}
+
+func (r *SupplierRepository) GetAll(name string) ([]models.Supplier, error) {
+	args := []interface{}{}
+	query := "SELECT id, name, contact_name, phone, email, address, created_at FROM suppliers"
+	if name != "" {
+		query += " WHERE name ILIKE $1"
+		args = append(args, "%"+name+"%")
+	}
+	query += " ORDER BY id ASC"
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suppliers []models.Supplier
+	for rows.Next() {
+		var supplier models.Supplier
+		err := rows.Scan(&supplier.ID, &supplier.Name, &supplier.ContactName, &supplier.Phone, &supplier.Email, &supplier.Address, &supplier.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, supplier)
+	}
+	return suppliers, nil
+}
+
+func (r *SupplierRepository) Create(supplier models.Supplier) (models.Supplier, error) {
+	query := "INSERT INTO suppliers (name, contact_name, phone, email, address) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at"
+	row := r.db.QueryRow(query, supplier.Name, supplier.ContactName, supplier.Phone, supplier.Email, supplier.Address)
+	err := row.Scan(&supplier.ID, &supplier.CreatedAt)
+	if err != nil {
+		return models.Supplier{}, err
+	}
+	return supplier, nil
+}
+
+func (r *SupplierRepository) GetByID(id int) (models.Supplier, error) {
+	query := "SELECT id, name, contact_name, phone, email, address, created_at FROM suppliers WHERE id = $1"
+	row := r.db.QueryRow(query, id)
+	var supplier models.Supplier
+	err := row.Scan(&supplier.ID, &supplier.Name, &supplier.ContactName, &supplier.Phone, &supplier.Email, &supplier.Address, &supplier.CreatedAt)
+	if err != nil {
+		return models.Supplier{}, err
+	}
+	return supplier, nil
+}
+
+func (r *SupplierRepository) Update(id int, supplier models.Supplier) (models.Supplier, error) {
+	query := "UPDATE suppliers SET name = $2, contact_name = $3, phone = $4, email = $5, address = $6, updated_at = NOW() WHERE id = $1"
+	result, err := r.db.Exec(query, id, supplier.Name, supplier.ContactName, supplier.Phone, supplier.Email, supplier.Address)
+	if err != nil {
+		return models.Supplier{}, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return models.Supplier{}, err
+	}
+	if rows == 0 {
+		return models.Supplier{}, errors.New("supplier not found")
+	}
+
+	supplier.ID = id
+	return supplier, nil
+}
+
+func (r *SupplierRepository) Delete(id int) error {
+	var orders int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM purchase_orders WHERE supplier_id = $1", id).Scan(&orders)
+	if err != nil {
+		return err
+	}
+	if orders > 0 {
+		return errors.New("supplier has purchase orders and cannot be deleted")
+	}
+
+	result, err := r.db.Exec("DELETE FROM suppliers WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("supplier not found")
+	}
+	return nil
+}
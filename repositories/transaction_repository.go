@@ -2,184 +2,703 @@ package repositories
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"go-kasir-api/models"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type TransactionRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	loyalty  *LoyaltyRepository
+	notifier LowStockNotifier
+	checkout models.CheckoutConfig
+	calendar *BusinessCalendar
 }
 
-func NewTransactionRepository(db *sql.DB) *TransactionRepository {
-	return &TransactionRepository{db: db}
+func NewTransactionRepository(db *sql.DB, loyalty *LoyaltyRepository, notifier LowStockNotifier, checkout models.CheckoutConfig, calendar *BusinessCalendar) *TransactionRepository {
+	return &TransactionRepository{db: db, loyalty: loyalty, notifier: notifier, checkout: checkout, calendar: calendar}
 }
 
-func (r *TransactionRepository) Create(items []models.CheckoutItem) (*models.Transaction, error) {
+// Create runs the checkout in a single database transaction. When idempotencyKey
+// is not nil the key is reserved first and the response is stored alongside the
+// sale, so a retried request can never commit a second time.
+func (r *TransactionRepository) Create(req models.CheckoutRequest, idempotencyKey *models.IdempotencyKey) (*models.Transaction, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	totalAmount := 0
-	details := make([]models.TransactionDetail, 0)
+	if idempotencyKey != nil {
+		if err := reserveIdempotencyKey(tx, idempotencyKey); err != nil {
+			return nil, err
+		}
+	}
 
-	for _, item := range items {
-		var productID, productPrice, stock int
-		var productName string
-		err := tx.QueryRow("SELECT id, name, price, stock FROM products WHERE id = $1", item.ProductID).Scan(&productID, &productName, &productPrice, &stock)
+	items, err := mergeCheckoutItems(req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RedeemPoints < 0 {
+		return nil, errors.New("Redeemed points cannot be negative")
+	}
+	if req.RedeemPoints > 0 && req.CustomerID == nil {
+		return nil, errors.New("Redeeming points requires a customer")
+	}
+	if req.RedeemAs != "" && req.RedeemAs != models.RedeemAsDiscount && req.RedeemAs != models.RedeemAsPayment {
+		return nil, errors.New("redeem_as must be discount or payment")
+	}
+	paymentMethod := req.PaymentMethod
+	if paymentMethod == "" {
+		paymentMethod = models.PaymentCash
+	}
+	if paymentMethod != models.PaymentCash && paymentMethod != models.PaymentCard && paymentMethod != models.PaymentQRIS {
+		return nil, errors.New("payment_method must be cash, card or qris")
+	}
+
+	// Every sale is booked to a shift so the drawer can be reconciled. Only
+	// when the store allows it can a sale without a cashier skip the shift.
+	if req.CashierID == nil && !r.checkout.AllowSalesWithoutShift {
+		return nil, errors.New("cashier_id is required; every sale is booked to the cashier's open shift")
+	}
+	outletID := req.OutletID
+	var shiftID *int
+	if req.CashierID != nil {
+		var active bool
+		var cashierOutlet sql.NullInt64
+		err := tx.QueryRow("SELECT active, outlet_id FROM cashiers WHERE id = $1", *req.CashierID).Scan(&active, &cashierOutlet)
+		if err == sql.ErrNoRows {
+			return nil, errors.New("Cashier not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, errors.New("Cashier is inactive")
+		}
+		if cashierOutlet.Valid {
+			id := int(cashierOutlet.Int64)
+			if outletID != nil && *outletID != id {
+				return nil, errors.New("Cashier does not work at this outlet")
+			}
+			outletID = &id
+		}
+
+		// The shift row is share-locked so the shift cannot be closed while
+		// this sale is being booked to it.
+		var shift, shiftOutlet int
+		err = tx.QueryRow("SELECT id, outlet_id FROM cashier_shifts WHERE cashier_id = $1 AND status = $2 FOR SHARE",
+			*req.CashierID, models.ShiftOpen).Scan(&shift, &shiftOutlet)
+		if err == sql.ErrNoRows {
+			return nil, errors.New("Cashier has no open shift")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if outletID != nil && *outletID != shiftOutlet {
+			return nil, errors.New("Cashier's shift is open at another outlet")
+		}
+		outletID = &shiftOutlet
+		shiftID = &shift
+	}
+	outlet, err := resolveOutlet(tx, outletID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The customer row is locked before any product so the loyalty balance
+	// cannot change between reading and spending it.
+	if req.CustomerID != nil {
+		if err := lockCustomer(tx, *req.CustomerID); err != nil {
+			return nil, err
+		}
+		if err := r.loyalty.expirePoints(tx, *req.CustomerID); err != nil {
+			return nil, err
+		}
+	}
+
+	lines, err := r.resolveCheckoutItems(tx, items)
+	if err != nil {
+		return nil, err
+	}
+	productIDs := make([]int64, 0, len(lines))
+	seen := make(map[int]bool, len(lines))
+	for _, line := range lines {
+		if !seen[line.productID] {
+			seen[line.productID] = true
+			productIDs = append(productIDs, int64(line.productID))
+		}
+	}
+
+	// A bundle sells its components, so they are locked along with it.
+	components, err := bundleComponents(tx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	lockIDs := append([]int64(nil), productIDs...)
+	for _, parts := range components {
+		for _, c := range parts {
+			if !seen[c.ProductID] {
+				seen[c.ProductID] = true
+				lockIDs = append(lockIDs, int64(c.ProductID))
+			}
+		}
+	}
+
+	// Lock every product in one round-trip. Taking the row locks in ID order
+	// means two baskets that share products can never deadlock each other.
+	rows, err := tx.Query(`SELECT id, name, price, cost_price, stock, reorder_point, target_stock, unit,
+	                              (SELECT precision FROM units WHERE code = products.unit), category_id, options <> '[]'::jsonb
+	                       FROM products WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(lockIDs))
+	if err != nil {
+		return nil, err
+	}
+	products := make(map[int]models.Product, len(lockIDs))
+	precisions := make(map[int]int, len(lockIDs))
+	listings := make(map[int]bool)
+	for rows.Next() {
+		var product models.Product
+		var precision int
+		var listing bool
+		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.CostPrice, &product.Stock, &product.ReorderPoint, &product.TargetStock,
+			&product.Unit, &precision, &product.CategoryID, &listing)
 		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		products[product.ID] = product
+		precisions[product.ID] = precision
+		listings[product.ID] = listing
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// A price change that fell due since the scheduler last ran takes effect
+	// before the sale is priced.
+	prices, _, err := applyDuePriceChanges(tx, lockIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, price := range prices {
+		product := products[id]
+		product.Price = price
+		products[id] = product
+	}
+	// Components only change while their bundle is locked, so they are read
+	// again now that it is.
+	if components, err = bundleComponents(tx, productIDs); err != nil {
+		return nil, err
+	}
+	for _, parts := range components {
+		for _, c := range parts {
+			if _, ok := products[c.ProductID]; !ok {
+				return nil, errors.New("Bundle changed during checkout; try again")
+			}
+		}
+	}
+	// The product locks above also guard the outlet's stock rows.
+	atOutlet, err := outletProducts(tx, outlet, lockIDs)
+	if err != nil {
+		return nil, err
+	}
+	units, err := sellingUnits(tx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines in other units or from scale labels are converted to the
+	// product's own unit, and lines of the same product are added up.
+	sales := make([]checkoutSale, 0, len(productIDs))
+	index := make(map[int]int, len(productIDs))
+	for _, line := range lines {
+		product, ok := products[line.productID]
+		if !ok {
 			return nil, errors.New("Product not found")
 		}
+		if listings[product.ID] {
+			return nil, errors.New(product.Name + " has variants; sell one of its variants")
+		}
+		price := product.Price
+		if local := atOutlet[product.ID]; local.price != nil {
+			price = *local.price
+		}
+		sale, err := priceCheckoutLine(line, product, price, precisions[product.ID], units[product.ID])
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[product.ID]; ok {
+			sales[i].quantity += sale.quantity
+			sales[i].priced += sale.priced
+			sales[i].fixed += sale.fixed
+			continue
+		}
+		index[product.ID] = len(sales)
+		sales = append(sales, sale)
+	}
+
+	// A price list replaces the unit price of the products it lists, at the
+	// highest quantity break the whole quantity sold reaches. Price labels
+	// and units with a price of their own keep their price.
+	priceListID, err := checkoutPriceList(tx, req.PriceListID, req.CustomerID, r.calendar.Today())
+	if err != nil {
+		return nil, err
+	}
+	if priceListID != nil {
+		tiers, err := priceListTiers(tx, *priceListID, productIDs)
+		if err != nil {
+			return nil, err
+		}
+		applied := false
+		for i, sale := range sales {
+			if price, ok := tierPrice(tiers[sale.productID], sale.quantity); ok {
+				sales[i].price = price
+				applied = true
+			}
+		}
+		if !applied {
+			priceListID = nil
+		}
+	}
+
+	excluded, err := r.loyalty.excludedCategories(tx)
+	if err != nil {
+		return nil, err
+	}
+	grossAmount := 0
+	eligibleAmount := 0
+	details := make([]models.TransactionDetail, 0, len(sales))
+	for _, sale := range sales {
+		product := products[sale.productID]
+		subtotal := roundAmount(sale.amount(), r.checkout.MoneyRounding)
+		grossAmount += subtotal
+		if !excluded[product.CategoryID] {
+			eligibleAmount += subtotal
+		}
 
-		if stock < item.Quantity {
+		parts := components[product.ID]
+		if len(parts) == 0 {
+			details = append(details, models.TransactionDetail{
+				ProductID:   product.ID,
+				ProductName: product.Name,
+				Quantity:    sale.quantity,
+				Unit:        product.Unit,
+				Subtotal:    subtotal,
+				UnitCost:    product.CostPrice,
+			})
+			continue
+		}
+
+		// A bundle is booked as its components, and its price is shared out
+		// by what the components would cost on their own.
+		partQuantities := make([]models.Quantity, len(parts))
+		weights := make([]int64, len(parts))
+		for j, c := range parts {
+			component := products[c.ProductID]
+			quantity, err := sale.quantity.Times(c.Quantity)
+			if err != nil {
+				return nil, err
+			}
+			if !quantity.FitsPrecision(precisions[component.ID]) {
+				return nil, precisionError(component.Name, component.Unit, precisions[component.ID])
+			}
+			price := component.Price
+			if local := atOutlet[component.ID]; local.price != nil {
+				price = *local.price
+			}
+			partQuantities[j] = quantity
+			weights[j] = quantity.Amount(price)
+		}
+		shares := allocateAmount(subtotal, weights)
+		bundleID, bundleQuantity := product.ID, sale.quantity
+		for j, c := range parts {
+			component := products[c.ProductID]
+			details = append(details, models.TransactionDetail{
+				ProductID:      component.ID,
+				ProductName:    component.Name,
+				Quantity:       partQuantities[j],
+				Unit:           component.Unit,
+				Subtotal:       shares[j],
+				UnitCost:       component.CostPrice,
+				BundleID:       &bundleID,
+				BundleQuantity: &bundleQuantity,
+			})
+		}
+	}
+
+	// A product sold on its own and in bundles leaves stock once, for the
+	// whole quantity.
+	stockIDs := make([]int64, 0, len(details))
+	taken := make([]models.Quantity, 0, len(details))
+	position := make(map[int]int, len(details))
+	for _, detail := range details {
+		if i, ok := position[detail.ProductID]; ok {
+			taken[i] += detail.Quantity
+			continue
+		}
+		position[detail.ProductID] = len(stockIDs)
+		stockIDs = append(stockIDs, int64(detail.ProductID))
+		taken = append(taken, detail.Quantity)
+	}
+	for i, id := range stockIDs {
+		if atOutlet[int(id)].stock < taken[i] {
 			return nil, errors.New("Insufficient stock")
 		}
+	}
+
+	// Stock leaves batches first-expired-first-out, and expired batches
+	// cannot be sold.
+	batches, err := outletBatches(tx, outlet, stockIDs)
+	if err != nil {
+		return nil, err
+	}
+	today := r.calendar.LocalDate()
+	var takes []batchTake
+	for i, id := range stockIDs {
+		product := products[int(id)]
+		if expired := expiredQuantity(batches[product.ID], today); expired > 0 {
+			sellable := atOutlet[product.ID].stock - expired
+			if sellable < taken[i] {
+				if sellable < 0 {
+					sellable = 0
+				}
+				return nil, errors.New(product.Name + " has expired stock; only " + sellable.String() + " " + product.Unit + " can be sold")
+			}
+		}
+		takes = append(takes, takeBatches(batches[product.ID], taken[i], &today)...)
+	}
+	if err := saveBatchTakes(tx, takes); err != nil {
+		return nil, err
+	}
 
-		// Calculate subtotal
-		subtotal := productPrice * item.Quantity
-		totalAmount += subtotal
+	// The stock guard in the WHERE clause keeps the decrement safe even if the
+	// row was changed outside of this lock.
+	result, err := tx.Exec(`UPDATE outlet_stock os SET stock = os.stock - v.quantity, updated_at = NOW()
+	                        FROM unnest($2::int[], $3::numeric[]) AS v(product_id, quantity)
+	                        WHERE os.outlet_id = $1 AND os.product_id = v.product_id AND os.stock >= v.quantity`,
+		outlet, pq.Array(stockIDs), pq.Array(taken))
+	if err != nil {
+		return nil, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated != int64(len(stockIDs)) {
+		return nil, errors.New("Insufficient stock")
+	}
+	_, err = tx.Exec(`UPDATE products p SET stock = p.stock - v.quantity, updated_at = NOW()
+	                  FROM unnest($1::int[], $2::numeric[]) AS v(product_id, quantity)
+	                  WHERE p.id = v.product_id`, pq.Array(stockIDs), pq.Array(taken))
+	if err != nil {
+		return nil, err
+	}
 
-		// Update stock
-		_, err = tx.Exec("UPDATE products SET stock = stock - $1, updated_at = NOW() WHERE id = $2", item.Quantity, item.ProductID)
+	pointsValue := 0
+	if req.RedeemPoints > 0 {
+		balance, err := r.loyalty.balance(tx, *req.CustomerID)
 		if err != nil {
 			return nil, err
 		}
+		if balance < req.RedeemPoints {
+			return nil, errors.New("Insufficient loyalty points")
+		}
+		pointsValue = req.RedeemPoints * r.loyalty.Config().PointValue
+		if pointsValue > grossAmount {
+			return nil, errors.New("Redeemed points exceed the transaction total")
+		}
+	}
 
-		// Create transaction detail
-		details = append(details, models.TransactionDetail{
-			ProductID:   productID,
-			ProductName: productName,
-			Quantity:    item.Quantity,
-			Subtotal:    subtotal,
-		})
+	totalAmount := grossAmount
+	discountAmount := 0
+	pointsPayment := 0
+	if req.RedeemAs == models.RedeemAsPayment {
+		pointsPayment = pointsValue
+	} else {
+		discountAmount = pointsValue
+		totalAmount -= discountAmount
+	}
+
+	// Points are only earned on what the customer paid with money.
+	pointsEarned := 0
+	if req.CustomerID != nil {
+		pointsEarned = r.loyalty.PointsFor(eligibleAmount - pointsValue)
 	}
 
 	var transactionID int
-	err = tx.QueryRow("INSERT INTO transactions (total_amount) VALUES ($1) RETURNING id", totalAmount).Scan(&transactionID)
+	var createdAt time.Time
+	err = tx.QueryRow(`INSERT INTO transactions (customer_id, cashier_id, outlet_id, shift_id, payment_method, total_amount, discount_amount, points_redeemed, points_payment, points_earned, price_list_id)
+	                   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, created_at`,
+		req.CustomerID, req.CashierID, outlet, shiftID, paymentMethod, totalAmount, discountAmount, req.RedeemPoints, pointsPayment, pointsEarned, priceListID).Scan(&transactionID, &createdAt)
 	if err != nil {
 		return nil, err
 	}
 
-	// Insert details and capture IDs
-	for i := range details {
+	if req.RedeemPoints > 0 {
+		if err := r.loyalty.redeem(tx, *req.CustomerID, transactionID, req.RedeemPoints); err != nil {
+			return nil, err
+		}
+	}
+	if pointsEarned > 0 {
+		if err := r.loyalty.earn(tx, *req.CustomerID, transactionID, pointsEarned); err != nil {
+			return nil, err
+		}
+	}
+
+	productIDs = make([]int64, len(details))
+	quantities := make([]models.Quantity, len(details))
+	subtotals := make([]int64, len(details))
+	unitCosts := make([]int64, len(details))
+	bundleIDs := make([]sql.NullInt64, len(details))
+	bundleQuantities := make([]sql.NullString, len(details))
+	for i, detail := range details {
+		productIDs[i] = int64(detail.ProductID)
+		quantities[i] = detail.Quantity
+		subtotals[i] = int64(detail.Subtotal)
+		unitCosts[i] = int64(detail.UnitCost)
+		if detail.BundleID != nil {
+			bundleIDs[i] = sql.NullInt64{Int64: int64(*detail.BundleID), Valid: true}
+			bundleQuantities[i] = sql.NullString{String: detail.BundleQuantity.String(), Valid: true}
+		}
+	}
+	rows, err = tx.Query(`INSERT INTO transaction_details (transaction_id, product_id, quantity, subtotal, unit_cost, bundle_id, bundle_quantity)
+	                      SELECT $1, v.product_id, v.quantity, v.subtotal, v.unit_cost, v.bundle_id, v.bundle_quantity
+	                      FROM unnest($2::int[], $3::numeric[], $4::int[], $5::int[], $6::int[], $7::numeric[])
+	                           AS v(product_id, quantity, subtotal, unit_cost, bundle_id, bundle_quantity)
+	                      RETURNING id, product_id, COALESCE(bundle_id, 0)`,
+		transactionID, pq.Array(productIDs), pq.Array(quantities), pq.Array(subtotals), pq.Array(unitCosts),
+		pq.Array(bundleIDs), pq.Array(bundleQuantities))
+	if err != nil {
+		return nil, err
+	}
+	type detailKey struct {
+		productID int
+		bundleID  int
+	}
+	detailIDs := make(map[detailKey]int, len(details))
+	for rows.Next() {
 		var detailID int
-		err := tx.QueryRow("INSERT INTO transaction_details (transaction_id, product_id, quantity, subtotal) VALUES ($1, $2, $3, $4) RETURNING id", transactionID, details[i].ProductID, details[i].Quantity, details[i].Subtotal).Scan(&detailID)
-		if err != nil {
+		var key detailKey
+		if err := rows.Scan(&detailID, &key.productID, &key.bundleID); err != nil {
+			rows.Close()
 			return nil, err
 		}
-		// Update the detail with IDs
-		details[i].ID = detailID
+		detailIDs[key] = detailID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	movements := make([]models.StockMovement, len(details))
+	for i := range details {
+		key := detailKey{productID: details[i].ProductID}
+		if details[i].BundleID != nil {
+			key.bundleID = *details[i].BundleID
+		}
+		details[i].ID = detailIDs[key]
 		details[i].TransactionID = transactionID
+		movements[i] = models.StockMovement{
+			ProductID:     details[i].ProductID,
+			OutletID:      outlet,
+			Quantity:      -details[i].Quantity,
+			MovementType:  models.StockMovementSale,
+			ReferenceType: "transaction",
+			ReferenceID:   transactionID,
+			UnitCost:      &details[i].UnitCost,
+		}
+	}
+	if err := recordStockMovements(tx, movements); err != nil {
+		return nil, err
+	}
+
+	transaction := &models.Transaction{
+		ID:             transactionID,
+		CustomerID:     req.CustomerID,
+		CashierID:      req.CashierID,
+		OutletID:       outlet,
+		ShiftID:        shiftID,
+		PaymentMethod:  paymentMethod,
+		TotalAmount:    totalAmount,
+		DiscountAmount: discountAmount,
+		PointsRedeemed: req.RedeemPoints,
+		PointsPayment:  pointsPayment,
+		PointsEarned:   pointsEarned,
+		PriceListID:    priceListID,
+		CreatedAt:      createdAt,
+		Details:        details,
+	}
+
+	if idempotencyKey != nil {
+		body, err := json.Marshal(transaction)
+		if err != nil {
+			return nil, err
+		}
+		idempotencyKey.StatusCode = http.StatusCreated
+		idempotencyKey.ResponseBody = body
+		if err := completeIdempotencyKey(tx, idempotencyKey); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	return &models.Transaction{
-		ID:          transactionID,
-		TotalAmount: totalAmount,
-		Details:     details,
-	}, nil
+	r.notifyLowStock(transaction, products, stockIDs, taken)
+
+	return transaction, nil
 }
 
-func (r *TransactionRepository) GetTransactionReport(start string, end string) (*models.TransactionReport, error) {
-	var totalRevenue sql.NullInt64
-	var totalTransaction int
+// notifyLowStock fires an event for every product this sale took from above
+// its reorder point to at or below it. taken is the quantity that left stock
+// for each of productIDs. It runs after commit and in the background so a
+// slow notifier never holds up the checkout.
+func (r *TransactionRepository) notifyLowStock(transaction *models.Transaction, products map[int]models.Product, productIDs []int64, taken []models.Quantity) {
+	if r.notifier == nil {
+		return
+	}
+
+	var events []models.LowStockEvent
+	for i, id := range productIDs {
+		product := products[int(id)]
+		after := product.Stock - taken[i]
+		if product.ReorderPoint > 0 && product.Stock > product.ReorderPoint && after <= product.ReorderPoint {
+			events = append(events, models.LowStockEvent{
+				ProductID:     product.ID,
+				ProductName:   product.Name,
+				Stock:         after,
+				ReorderPoint:  product.ReorderPoint,
+				TargetStock:   product.TargetStock,
+				TransactionID: transaction.ID,
+				OccurredAt:    transaction.CreatedAt,
+			})
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	go func() {
+		for _, event := range events {
+			if err := r.notifier.NotifyLowStock(event); err != nil {
+				log.Println("Failed to send low stock notification:", err)
+			}
+		}
+	}()
+}
 
-	// Get total revenue and transaction count for date range
-	err := r.db.QueryRow(`
-		SELECT 
-			COALESCE(SUM(total_amount), 0), 
-			COUNT(*) 
-		FROM transactions 
-		WHERE DATE(created_at) BETWEEN $1 AND $2
-	`, start, end).Scan(&totalRevenue, &totalTransaction)
+func (r *TransactionRepository) GetByCustomerID(customerID int) ([]models.Transaction, error) {
+	rows, err := r.db.Query(`SELECT id, customer_id, cashier_id, outlet_id, shift_id, payment_method, total_amount, discount_amount, points_redeemed, points_payment, points_earned, price_list_id, created_at
+	                         FROM transactions
+	                         WHERE customer_id = $1
+	                         ORDER BY created_at DESC, id DESC`, customerID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	// Get best-selling product (by quantity sold in date range)
-	var bestSelling models.BestSellingProduct
-	err = r.db.QueryRow(`
-		SELECT 
-			p.name,
-			SUM(td.quantity) as total_quantity
-		FROM transaction_details td
-		JOIN transactions t ON t.id = td.transaction_id
-		JOIN products p ON p.id = td.product_id
-		WHERE DATE(t.created_at) BETWEEN $1 AND $2
-		GROUP BY p.id, p.name
-		ORDER BY total_quantity DESC
-		LIMIT 1
-	`, start, end).Scan(&bestSelling.ProductName, &bestSelling.QuantitySold)
-
-	// If no product sold in this period, keep bestSelling as zero value
-	if err == sql.ErrNoRows {
-		err = nil
-	} else if err != nil {
+	transactions := make([]models.Transaction, 0)
+	index := make(map[int]int)
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var transaction models.Transaction
+		var customer, cashier sql.NullInt64
+		err := rows.Scan(&transaction.ID, &customer, &cashier, &transaction.OutletID, &transaction.ShiftID, &transaction.PaymentMethod, &transaction.TotalAmount, &transaction.DiscountAmount,
+			&transaction.PointsRedeemed, &transaction.PointsPayment, &transaction.PointsEarned, &transaction.PriceListID, &transaction.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if customer.Valid {
+			id := int(customer.Int64)
+			transaction.CustomerID = &id
+		}
+		if cashier.Valid {
+			id := int(cashier.Int64)
+			transaction.CashierID = &id
+		}
+		transaction.Details = make([]models.TransactionDetail, 0)
+		index[transaction.ID] = len(transactions)
+		ids = append(ids, int64(transaction.ID))
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	revenue := int(0)
-	if totalRevenue.Valid {
-		revenue = int(totalRevenue.Int64)
+	if len(ids) == 0 {
+		return transactions, nil
 	}
 
-	return &models.TransactionReport{
-		TotalRevenue:       revenue,
-		TotalTransaction:   totalTransaction,
-		BestSellingProduct: bestSelling,
-	}, nil
+	if err := r.loadDetails(transactions, index, ids); err != nil {
+		return nil, err
+	}
+	return transactions, nil
 }
 
-func (r *TransactionRepository) GetTransactionReportToday() (*models.TransactionReport, error) {
-	var totalRevenue sql.NullInt64
-	var totalTransaction int
-
-	// Get total revenue and transaction count for today
-	err := r.db.QueryRow(`
-		SELECT 
-			COALESCE(SUM(total_amount), 0), 
-			COUNT(*) 
-		FROM transactions 
-		WHERE DATE(created_at) = CURRENT_DATE
-	`).Scan(&totalRevenue, &totalTransaction)
+// loadDetails fills the details of every transaction in one query. index maps
+// a transaction ID to its position in transactions.
+func (r *TransactionRepository) loadDetails(transactions []models.Transaction, index map[int]int, ids []int64) error {
+	rows, err := r.db.Query(`SELECT td.id, td.transaction_id, td.product_id, p.name, td.quantity, p.unit, td.subtotal, td.unit_cost,
+	                                td.bundle_id, td.bundle_quantity
+	                         FROM transaction_details td
+	                         JOIN products p ON p.id = td.product_id
+	                         WHERE td.transaction_id = ANY($1)
+	                         ORDER BY td.transaction_id, td.id`, pq.Array(ids))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer rows.Close()
 
-	// Get best-selling product (by quantity sold today)
-	var bestSelling models.BestSellingProduct
-	err = r.db.QueryRow(`
-		SELECT 
-			p.name,
-			SUM(td.quantity) as total_quantity
-		FROM transaction_details td
-		JOIN transactions t ON t.id = td.transaction_id
-		JOIN products p ON p.id = td.product_id
-		WHERE DATE(t.created_at) = CURRENT_DATE
-		GROUP BY p.id, p.name
-		ORDER BY total_quantity DESC
-		LIMIT 1
-	`).Scan(&bestSelling.ProductName, &bestSelling.QuantitySold)
-
-	// If no product sold today, keep bestSelling as zero value
-	if err == sql.ErrNoRows {
-		err = nil
-	} else if err != nil {
-		return nil, err
+	for rows.Next() {
+		var detail models.TransactionDetail
+		err := rows.Scan(&detail.ID, &detail.TransactionID, &detail.ProductID, &detail.ProductName, &detail.Quantity, &detail.Unit, &detail.Subtotal, &detail.UnitCost,
+			&detail.BundleID, &detail.BundleQuantity)
+		if err != nil {
+			return err
+		}
+		i := index[detail.TransactionID]
+		transactions[i].Details = append(transactions[i].Details, detail)
 	}
+	return rows.Err()
+}
 
-	revenue := int(0)
-	if totalRevenue.Valid {
-		revenue = int(totalRevenue.Int64)
+// mergeCheckoutItems folds repeated items into one line, keeping the order in
+// which each first appeared in the basket. Items only merge when they name the
+// same product in the same unit, or the same barcode; a scanned item without
+// a quantity counts once.
+func mergeCheckoutItems(items []models.CheckoutItem) ([]models.CheckoutItem, error) {
+	if len(items) == 0 {
+		return nil, errors.New("Checkout must contain at least one item")
 	}
 
-	return &models.TransactionReport{
-		TotalRevenue:       revenue,
-		TotalTransaction:   totalTransaction,
-		BestSellingProduct: bestSelling,
-	}, nil
+	type itemKey struct {
+		productID int
+		barcode   string
+		unit      string
+	}
+	merged := make([]models.CheckoutItem, 0, len(items))
+	index := make(map[itemKey]int, len(items))
+	for _, item := range items {
+		if item.Barcode != "" && item.Quantity == 0 {
+			item.Quantity = models.Whole(1)
+		}
+		if item.Quantity <= 0 {
+			return nil, errors.New("Quantity must be greater than zero")
+		}
+		key := itemKey{item.ProductID, item.Barcode, item.Unit}
+		if i, ok := index[key]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged, nil
 }
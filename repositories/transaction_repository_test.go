@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"go-kasir-api/models"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestMergeCheckoutItems(t *testing.T) {
+	items := []models.CheckoutItem{
+		{ProductID: 3, Quantity: models.Whole(1)},
+		{ProductID: 1, Quantity: models.Whole(2)},
+		{ProductID: 3, Quantity: models.Whole(4)},
+		{ProductID: 3, Unit: "dozen", Quantity: models.Whole(1)},
+		{Barcode: "2000123012504"},
+		{Barcode: "2000123012504"},
+	}
+
+	merged, err := mergeCheckoutItems(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.CheckoutItem{
+		{ProductID: 3, Quantity: models.Whole(5)},
+		{ProductID: 1, Quantity: models.Whole(2)},
+		{ProductID: 3, Unit: "dozen", Quantity: models.Whole(1)},
+		{Barcode: "2000123012504", Quantity: models.Whole(2)},
+	}
+	if len(merged) != len(want) {
+		t.Fatalf("got %d items, want %d", len(merged), len(want))
+	}
+	for i := range want {
+		if merged[i] != want[i] {
+			t.Errorf("item %d = %+v, want %+v", i, merged[i], want[i])
+		}
+	}
+
+	if _, err := mergeCheckoutItems([]models.CheckoutItem{{ProductID: 1, Quantity: 0}}); err == nil {
+		t.Error("expected an error for a zero quantity")
+	}
+	if _, err := mergeCheckoutItems(nil); err == nil {
+		t.Error("expected an error for an empty basket")
+	}
+}
+
+// openTestDB connects to the database in TEST_DB_CONN. The schema from the
+// README must already be applied.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	conn := os.Getenv("TEST_DB_CONN")
+	if conn == "" {
+		t.Skip("TEST_DB_CONN not set")
+	}
+	db, err := sql.Open("postgres", conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestTransactionRepositoryCreateConcurrentStock(t *testing.T) {
+	db := openTestDB(t)
+
+	const initialStock = 10
+	const buyers = 40
+
+	var categoryID int
+	err := db.QueryRow("INSERT INTO categories (name, description) VALUES ($1, '') RETURNING id",
+		fmt.Sprintf("concurrency-test-%d", os.Getpid())).Scan(&categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	productIDs := make([]int, 2)
+	t.Cleanup(func() { deleteTestRows(t, db, categoryID, productIDs) })
+
+	for i := range productIDs {
+		err := db.QueryRow("INSERT INTO products (name, price, stock, category_id) VALUES ($1, 1000, $2, $3) RETURNING id",
+			fmt.Sprintf("concurrency-test-%d-%d", os.Getpid(), i), initialStock, categoryID).Scan(&productIDs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Exec("INSERT INTO outlet_stock (outlet_id, product_id, stock) SELECT id, $1, $2 FROM outlets WHERE is_default",
+			productIDs[i], initialStock)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calendar, err := NewBusinessCalendar("UTC", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The baskets are sold without a cashier, so no shift is needed.
+	checkout := models.CheckoutConfig{MoneyRounding: 1, AllowSalesWithoutShift: true}
+	repo := NewTransactionRepository(db, NewLoyaltyRepository(db, models.LoyaltyConfig{}), nil, checkout, calendar)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sold := 0
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Alternate the basket order so lock ordering is exercised.
+			first, second := productIDs[0], productIDs[1]
+			if i%2 == 1 {
+				first, second = second, first
+			}
+			items := []models.CheckoutItem{
+				{ProductID: first, Quantity: models.Whole(1)},
+				{ProductID: second, Quantity: models.Whole(1)},
+			}
+			_, err := repo.Create(models.CheckoutRequest{Items: items}, nil)
+			if err != nil {
+				if err.Error() != "Insufficient stock" {
+					t.Errorf("unexpected checkout error: %v", err)
+				}
+				return
+			}
+			mu.Lock()
+			sold++
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	if sold != initialStock {
+		t.Errorf("sold %d baskets, want %d", sold, initialStock)
+	}
+	for _, id := range productIDs {
+		var stock, outletStock models.Quantity
+		err := db.QueryRow(`SELECT p.stock, os.stock
+		                    FROM products p
+		                    JOIN outlet_stock os ON os.product_id = p.id
+		                    JOIN outlets o ON o.id = os.outlet_id AND o.is_default
+		                    WHERE p.id = $1`, id).Scan(&stock, &outletStock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outletStock != stock {
+			t.Errorf("product %d outlet stock = %s, total = %s", id, outletStock, stock)
+		}
+		if stock < 0 {
+			t.Errorf("product %d stock went negative: %s", id, stock)
+		}
+		if stock != models.Whole(initialStock-sold) {
+			t.Errorf("product %d stock = %s, want %d", id, stock, initialStock-sold)
+		}
+	}
+}
+
+// deleteTestRows removes the sales of the test products, then the products,
+// whose stock rows and movements go with them, and then their category.
+// Product IDs that are still 0 were never created.
+func deleteTestRows(t *testing.T, db *sql.DB, categoryID int, productIDs []int) {
+	t.Helper()
+	ids := make([]int64, 0, len(productIDs))
+	for _, id := range productIDs {
+		if id != 0 {
+			ids = append(ids, int64(id))
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer tx.Rollback()
+
+	// The details go first so the transactions they point at can follow.
+	_, err = tx.Exec(`WITH sold AS (
+	                      DELETE FROM transaction_details WHERE product_id = ANY($1) RETURNING transaction_id
+	                  ) DELETE FROM transactions WHERE id IN (SELECT transaction_id FROM sold)`, pq.Array(ids))
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM products WHERE id = ANY($1)", pq.Array(ids))
+	}
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM categories WHERE id = $1", categoryID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		t.Errorf("cleaning up test rows: %v", err)
+	}
+}
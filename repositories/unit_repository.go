@@ -0,0 +1,256 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-kasir-api/models"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type UnitRepository struct {
+	db *sql.DB
+}
+
+func NewUnitRepository(db *sql.DB) *UnitRepository {
+	return &UnitRepository{db: db}
+}
+
+func (r *UnitRepository) GetAll() ([]models.Unit, error) {
+	rows, err := r.db.Query("SELECT code, name, precision FROM units ORDER BY code ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := make([]models.Unit, 0)
+	for rows.Next() {
+		var unit models.Unit
+		if err := rows.Scan(&unit.Code, &unit.Name, &unit.Precision); err != nil {
+			return nil, err
+		}
+		units = append(units, unit)
+	}
+	return units, rows.Err()
+}
+
+func (r *UnitRepository) Create(unit models.Unit) (models.Unit, error) {
+	unit.Code = strings.ToLower(strings.TrimSpace(unit.Code))
+	if unit.Code == "" {
+		return models.Unit{}, errors.New("Unit code is required")
+	}
+	if err := validateUnitPrecision(unit.Precision); err != nil {
+		return models.Unit{}, err
+	}
+
+	result, err := r.db.Exec("INSERT INTO units (code, name, precision) VALUES ($1, $2, $3) ON CONFLICT (code) DO NOTHING",
+		unit.Code, unit.Name, unit.Precision)
+	if err != nil {
+		return models.Unit{}, err
+	}
+	created, err := result.RowsAffected()
+	if err != nil {
+		return models.Unit{}, err
+	}
+	if created == 0 {
+		return models.Unit{}, errors.New("Unit " + unit.Code + " already exists")
+	}
+	return unit, nil
+}
+
+// Update renames a unit or changes its precision. Precision can only be
+// lowered while no product is counted in the unit, since existing stock and
+// history may use the decimals being taken away.
+func (r *UnitRepository) Update(code string, unit models.Unit) (models.Unit, error) {
+	if err := validateUnitPrecision(unit.Precision); err != nil {
+		return models.Unit{}, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Unit{}, err
+	}
+	defer tx.Rollback()
+
+	current, err := getUnit(tx, code, true)
+	if err != nil {
+		return models.Unit{}, err
+	}
+	if unit.Precision < current.Precision {
+		var used bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM products WHERE unit = $1)
+		                        OR EXISTS (SELECT 1 FROM product_units WHERE unit = $1)`, code).Scan(&used)
+		if err != nil {
+			return models.Unit{}, err
+		}
+		if used {
+			return models.Unit{}, errors.New("Unit is in use; its precision can only be raised")
+		}
+	}
+
+	_, err = tx.Exec("UPDATE units SET name = $2, precision = $3 WHERE code = $1", code, unit.Name, unit.Precision)
+	if err != nil {
+		return models.Unit{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Unit{}, err
+	}
+	unit.Code = current.Code
+	return unit, nil
+}
+
+func (r *UnitRepository) Delete(code string) error {
+	var used bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM products WHERE unit = $1)
+	                          OR EXISTS (SELECT 1 FROM product_units WHERE unit = $1)`, code).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return errors.New("unit is used by products and cannot be deleted")
+	}
+
+	result, err := r.db.Exec("DELETE FROM units WHERE code = $1", code)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("unit not found")
+	}
+	return nil
+}
+
+func validateUnitPrecision(precision int) error {
+	if precision < 0 || precision > models.QuantityDecimals {
+		return fmt.Errorf("Precision must be between 0 and %d", models.QuantityDecimals)
+	}
+	return nil
+}
+
+type unitQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func getUnit(q unitQueryer, code string, forUpdate bool) (models.Unit, error) {
+	query := "SELECT code, name, precision FROM units WHERE code = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var unit models.Unit
+	err := q.QueryRow(query, code).Scan(&unit.Code, &unit.Name, &unit.Precision)
+	if err == sql.ErrNoRows {
+		return models.Unit{}, errors.New("Unit " + code + " not found")
+	}
+	return unit, err
+}
+
+// precisionStep is the smallest quantity a unit with the given precision
+// can count.
+func precisionStep(precision int) models.Quantity {
+	step := models.Quantity(1)
+	for i := precision; i < models.QuantityDecimals; i++ {
+		step *= 10
+	}
+	return step
+}
+
+// precisionError explains that a quantity has more decimals than its unit
+// allows.
+func precisionError(name string, unit string, precision int) error {
+	return fmt.Errorf("Quantity of %s must be a multiple of %s %s", name, precisionStep(precision), unit)
+}
+
+// checkStockPrecision rejects stock changes with more decimals than the
+// products' units allow, e.g. half a piece.
+func checkStockPrecision(tx *sql.Tx, productIDs []int64, deltas []models.Quantity) error {
+	var name, unit string
+	var precision int
+	err := tx.QueryRow(`SELECT p.name, u.code, u.precision
+	                    FROM unnest($1::int[], $2::numeric[]) AS v(product_id, delta)
+	                    JOIN products p ON p.id = v.product_id
+	                    JOIN units u ON u.code = p.unit
+	                    WHERE v.delta <> ROUND(v.delta, u.precision)
+	                    LIMIT 1`, pq.Array(productIDs), pq.Array(deltas)).Scan(&name, &unit, &precision)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return precisionError(name, unit, precision)
+}
+
+// productUnitsColumn selects a product's other units as a JSON array, for
+// use with the products p alias.
+const productUnitsColumn = `COALESCE((SELECT json_agg(json_build_object('unit', pu.unit, 'factor', pu.factor, 'price', pu.price,
+	                                                       'barcode', COALESCE(pu.barcode, '')) ORDER BY pu.factor)
+	                               FROM product_units pu WHERE pu.product_id = p.id), '[]')`
+
+// checkProductUnits validates a product's unit and the other units it is
+// sold in, and that its stock settings fit the unit's precision. It returns
+// the product's unit code, "pcs" when none is given.
+func checkProductUnits(tx *sql.Tx, product models.Product) (string, error) {
+	code := strings.ToLower(strings.TrimSpace(product.Unit))
+	if code == "" {
+		code = "pcs"
+	}
+	unit, err := getUnit(tx, code, false)
+	if err != nil {
+		return "", err
+	}
+	for _, quantity := range []models.Quantity{product.Stock, product.ReorderPoint, product.TargetStock} {
+		if !quantity.FitsPrecision(unit.Precision) {
+			return "", precisionError(product.Name, unit.Code, unit.Precision)
+		}
+	}
+
+	seen := map[string]bool{code: true}
+	barcodes := map[string]bool{product.Barcode: true}
+	for _, other := range product.Units {
+		if seen[other.Unit] {
+			return "", errors.New("Unit " + other.Unit + " is listed more than once")
+		}
+		seen[other.Unit] = true
+		if other.Barcode != "" && barcodes[other.Barcode] {
+			return "", errors.New("Barcode " + other.Barcode + " is already in use")
+		}
+		barcodes[other.Barcode] = true
+		if _, err := getUnit(tx, other.Unit, false); err != nil {
+			return "", err
+		}
+		if other.Factor <= 0 {
+			return "", errors.New("Factor of unit " + other.Unit + " must be greater than zero")
+		}
+		if !other.Factor.FitsPrecision(unit.Precision) {
+			return "", fmt.Errorf("Factor of unit %s must be a multiple of %s %s", other.Unit, precisionStep(unit.Precision), unit.Code)
+		}
+		if other.Price != nil && *other.Price < 0 {
+			return "", errors.New("Price cannot be negative")
+		}
+	}
+	return code, nil
+}
+
+// saveProductUnits replaces the other units a product is sold in.
+func saveProductUnits(tx *sql.Tx, productID int, units []models.ProductUnit) error {
+	if _, err := tx.Exec("DELETE FROM product_units WHERE product_id = $1", productID); err != nil {
+		return err
+	}
+	for _, unit := range units {
+		if err := checkProductCodes(tx, productID, "", unit.Barcode); err != nil {
+			return err
+		}
+		_, err := tx.Exec("INSERT INTO product_units (product_id, unit, factor, price, barcode) VALUES ($1, $2, $3, $4, NULLIF($5, ''))",
+			productID, unit.Unit, unit.Factor, unit.Price, unit.Barcode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type CashierService struct {
+	repository *repositories.CashierRepository
+}
+
+func NewCashierService(repository *repositories.CashierRepository) *CashierService {
+	return &CashierService{repository: repository}
+}
+
+func (s *CashierService) GetAll() ([]models.Cashier, error) {
+	return s.repository.GetAll()
+}
+
+// Create always adds an active cashier.
+func (s *CashierService) Create(cashier models.Cashier) (models.Cashier, error) {
+	cashier.Active = true
+	return s.repository.Create(cashier)
+}
+
+func (s *CashierService) GetByID(id int) (models.Cashier, error) {
+	return s.repository.GetByID(id)
+}
+
+func (s *CashierService) Update(id int, cashier models.Cashier) (models.Cashier, error) {
+	return s.repository.Update(id, cashier)
+}
+
+func (s *CashierService) Delete(id int) error {
+	return s.repository.Delete(id)
+}
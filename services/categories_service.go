@@ -17,6 +17,12 @@ func (s *CategoryService) GetAll() ([]models.Category, error) {
 	return s.repository.GetAll()
 }
 
+// GetTree returns the categories nested under their parents, or only the
+// subtree under rootID when it is set.
+func (s *CategoryService) GetTree(rootID *int) ([]models.Category, error) {
+	return s.repository.GetTree(rootID)
+}
+
 func (s *CategoryService) Create(category models.Category) (models.Category, error) {
 	return s.repository.Create(category)
 }
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type CustomerService struct {
+	customerRepo    *repositories.CustomerRepository
+	transactionRepo *repositories.TransactionRepository
+	loyaltyRepo     *repositories.LoyaltyRepository
+}
+
+func NewCustomerService(customerRepo *repositories.CustomerRepository, transactionRepo *repositories.TransactionRepository, loyaltyRepo *repositories.LoyaltyRepository) *CustomerService {
+	return &CustomerService{customerRepo: customerRepo, transactionRepo: transactionRepo, loyaltyRepo: loyaltyRepo}
+}
+
+func (s *CustomerService) GetAll(name string, phone string, email string) ([]models.Customer, error) {
+	return s.customerRepo.GetAll(name, phone, email)
+}
+
+func (s *CustomerService) Create(customer models.Customer) (models.Customer, error) {
+	return s.customerRepo.Create(customer)
+}
+
+func (s *CustomerService) GetByID(id int) (models.Customer, error) {
+	return s.customerRepo.GetByID(id)
+}
+
+func (s *CustomerService) Update(id int, customer models.Customer) (models.Customer, error) {
+	return s.customerRepo.Update(id, customer)
+}
+
+func (s *CustomerService) Delete(id int) error {
+	return s.customerRepo.Delete(id)
+}
+
+func (s *CustomerService) GetTransactions(id int) ([]models.Transaction, error) {
+	if _, err := s.customerRepo.GetByID(id); err != nil {
+		return nil, err
+	}
+	return s.transactionRepo.GetByCustomerID(id)
+}
+
+func (s *CustomerService) GetStats(id int) (*models.CustomerStats, error) {
+	if _, err := s.customerRepo.GetByID(id); err != nil {
+		return nil, err
+	}
+	return s.customerRepo.GetStats(id)
+}
+
+func (s *CustomerService) GetLoyalty(id int) (*models.LoyaltyBalance, error) {
+	return s.loyaltyRepo.GetBalance(id)
+}
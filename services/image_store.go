@@ -0,0 +1,12 @@
+package services
+
+// ImageStore keeps uploaded image files under slash-separated keys such as
+// products/7/3f9c.jpg. storage.LocalStore keeps them on disk; an
+// S3-compatible store can stand in for it.
+type ImageStore interface {
+	// Save stores data under key, replacing anything already there, and
+	// returns the URL the file can be fetched from.
+	Save(key string, contentType string, data []byte) (string, error)
+	// Delete removes the file under key. A missing file is not an error.
+	Delete(key string) error
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type InventoryService struct {
+	repository *repositories.InventoryRepository
+}
+
+func NewInventoryService(repository *repositories.InventoryRepository) *InventoryService {
+	return &InventoryService{repository: repository}
+}
+
+func (s *InventoryService) GetLowStock(days int, coverDays int, outletID *int, categoryID *int) ([]models.LowStockItem, error) {
+	if days <= 0 || coverDays < 0 {
+		return nil, errors.New("days must be positive and cover_days cannot be negative")
+	}
+	return s.repository.GetLowStock(days, coverDays, outletID, categoryID)
+}
+
+func (s *InventoryService) EachLowStock(days int, coverDays int, outletID *int, categoryID *int, fn func(models.LowStockItem) error) error {
+	if days <= 0 || coverDays < 0 {
+		return errors.New("days must be positive and cover_days cannot be negative")
+	}
+	return s.repository.EachLowStock(days, coverDays, outletID, categoryID, fn)
+}
+
+func (s *InventoryService) GetBatches(productID int, outletID *int) ([]models.StockBatch, error) {
+	return s.repository.GetBatches(productID, outletID)
+}
+
+func (s *InventoryService) GetExpiring(days int, outletID *int, categoryID *int) ([]models.ExpiringBatch, error) {
+	if days <= 0 {
+		return nil, errors.New("days must be positive")
+	}
+	return s.repository.GetExpiring(days, outletID, categoryID)
+}
+
+func (s *InventoryService) EachExpiring(days int, outletID *int, categoryID *int, fn func(models.ExpiringBatch) error) error {
+	if days <= 0 {
+		return errors.New("days must be positive")
+	}
+	return s.repository.EachExpiring(days, outletID, categoryID, fn)
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type OutletService struct {
+	repository *repositories.OutletRepository
+}
+
+func NewOutletService(repository *repositories.OutletRepository) *OutletService {
+	return &OutletService{repository: repository}
+}
+
+func (s *OutletService) GetAll() ([]models.Outlet, error) {
+	return s.repository.GetAll()
+}
+
+// Create always adds an active outlet.
+func (s *OutletService) Create(outlet models.Outlet) (models.Outlet, error) {
+	outlet.Active = true
+	return s.repository.Create(outlet)
+}
+
+func (s *OutletService) GetByID(id int) (models.Outlet, error) {
+	return s.repository.GetByID(id)
+}
+
+func (s *OutletService) Update(id int, outlet models.Outlet) (models.Outlet, error) {
+	return s.repository.Update(id, outlet)
+}
+
+func (s *OutletService) Delete(id int) error {
+	return s.repository.Delete(id)
+}
+
+func (s *OutletService) GetProducts(id int) ([]models.OutletProduct, error) {
+	return s.repository.GetProducts(id)
+}
+
+func (s *OutletService) SetPrice(id int, productID int, price *int) (*models.OutletProduct, error) {
+	return s.repository.SetPrice(id, productID, price)
+}
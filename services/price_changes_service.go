@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+	"time"
+)
+
+type PriceChangeService struct {
+	repository *repositories.PriceChangeRepository
+}
+
+func NewPriceChangeService(repository *repositories.PriceChangeRepository) *PriceChangeService {
+	return &PriceChangeService{repository: repository}
+}
+
+func (s *PriceChangeService) GetAll(productID *int, status string) ([]models.PriceChange, error) {
+	if status != "" && status != models.PriceChangeScheduled && status != models.PriceChangeApplied && status != models.PriceChangeCancelled {
+		return nil, errors.New("status must be scheduled, applied or cancelled")
+	}
+	return s.repository.GetAll(productID, status)
+}
+
+func (s *PriceChangeService) Create(change models.PriceChange) (models.PriceChange, error) {
+	return s.repository.Create(change)
+}
+
+func (s *PriceChangeService) GetByID(id int) (models.PriceChange, error) {
+	return s.repository.GetByID(id)
+}
+
+func (s *PriceChangeService) Cancel(id int) (models.PriceChange, error) {
+	return s.repository.Cancel(id)
+}
+
+// ApplyDue applies the price changes whose time has come. It runs on a
+// schedule.
+func (s *PriceChangeService) ApplyDue() (int, error) {
+	return s.repository.ApplyDue()
+}
+
+// GetHistory lists a product's price changes, or with a date only those in
+// force that business day.
+func (s *PriceChangeService) GetHistory(productID int, date *time.Time) ([]models.PriceHistoryEntry, error) {
+	return s.repository.GetHistory(productID, date)
+}
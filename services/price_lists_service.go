@@ -0,0 +1,34 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type PriceListService struct {
+	repository *repositories.PriceListRepository
+}
+
+func NewPriceListService(repository *repositories.PriceListRepository) *PriceListService {
+	return &PriceListService{repository: repository}
+}
+
+func (s *PriceListService) GetAll() ([]models.PriceList, error) {
+	return s.repository.GetAll()
+}
+
+func (s *PriceListService) Create(list models.PriceList) (models.PriceList, error) {
+	return s.repository.Create(list)
+}
+
+func (s *PriceListService) GetByID(id int) (models.PriceList, error) {
+	return s.repository.GetByID(id)
+}
+
+func (s *PriceListService) Update(id int, list models.PriceList) (models.PriceList, error) {
+	return s.repository.Update(id, list)
+}
+
+func (s *PriceListService) Delete(id int) error {
+	return s.repository.Delete(id)
+}
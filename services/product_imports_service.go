@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-kasir-api/exports"
+	"go-kasir-api/models"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxProductImportRows caps the rows of one import file.
+const MaxProductImportRows = 10000
+
+var productImportColumns = map[string]bool{
+	"id": true, "name": true, "category": true, "price": true, "cost_price": true,
+	"stock": true, "reorder_point": true, "target_stock": true,
+}
+
+// Import upserts the products in a CSV or XLSX file. Rows that cannot be parsed
+// are reported with the rows the database rejects. In an all-or-nothing import
+// (chunkSize 0) any bad row means nothing is written.
+func (s *ProductService) Import(reader exports.Reader, dryRun bool, chunkSize int, changedBy string) (*models.ProductImportSummary, error) {
+	if chunkSize < 0 {
+		return nil, errors.New("chunk_size cannot be negative")
+	}
+	rows, rowErrors, err := parseProductImport(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// An all-or-nothing import with unreadable rows cannot succeed, but the
+	// rest is still checked so every problem is reported at once.
+	summary, err := s.productRepo.Import(rows, dryRun || (chunkSize == 0 && len(rowErrors) > 0), chunkSize, changedBy)
+	if err != nil {
+		return nil, err
+	}
+	summary.DryRun = dryRun
+	summary.TotalRows += countRows(rowErrors)
+	summary.Failed += countRows(rowErrors)
+	summary.Errors = append(summary.Errors, rowErrors...)
+	sort.SliceStable(summary.Errors, func(i, j int) bool { return summary.Errors[i].Row < summary.Errors[j].Row })
+	return summary, nil
+}
+
+// parseProductImport reads the header and data rows of an import file. Errors
+// in individual rows are returned alongside the rows that parsed; a bad header
+// or an unreadable file fails the whole import.
+func parseProductImport(reader exports.Reader) ([]models.ProductImportRow, []models.ProductImportError, error) {
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("import file is empty")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !productImportColumns[name] {
+			return nil, nil, fmt.Errorf("unknown column %q", header[i])
+		}
+		if _, ok := columns[name]; ok {
+			return nil, nil, fmt.Errorf("column %q appears twice", name)
+		}
+		columns[name] = i
+	}
+	_, hasID := columns["id"]
+	_, hasName := columns["name"]
+	if !hasID && !hasName {
+		return nil, nil, errors.New("import file needs an id or a name column")
+	}
+
+	var rows []models.ProductImportRow
+	var rowErrors []models.ProductImportError
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		if len(rows)+countRows(rowErrors) >= MaxProductImportRows {
+			return nil, nil, fmt.Errorf("import file has more than %d rows", MaxProductImportRows)
+		}
+
+		row, errs := parseProductImportRow(line, record, columns)
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, errs...)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+func parseProductImportRow(line int, record []string, columns map[string]int) (models.ProductImportRow, []models.ProductImportError) {
+	row := models.ProductImportRow{Row: line}
+	var errs []models.ProductImportError
+	cell := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	number := func(name string) *int {
+		value := cell(name)
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			errs = append(errs, models.ProductImportError{Row: line, Column: name, Message: "must be a whole number of zero or more"})
+			return nil
+		}
+		return &n
+	}
+	quantity := func(name string) *models.Quantity {
+		value := cell(name)
+		if value == "" {
+			return nil
+		}
+		q, err := models.ParseQuantity(value)
+		if err != nil || q < 0 {
+			errs = append(errs, models.ProductImportError{Row: line, Column: name, Message: "must be a number of zero or more with at most 3 decimals"})
+			return nil
+		}
+		return &q
+	}
+
+	row.ID = number("id")
+	row.Name = cell("name")
+	row.Category = cell("category")
+	row.Price = number("price")
+	row.CostPrice = number("cost_price")
+	row.Stock = quantity("stock")
+	row.ReorderPoint = quantity("reorder_point")
+	row.TargetStock = quantity("target_stock")
+	if row.ID == nil && row.Name == "" && len(errs) == 0 {
+		errs = append(errs, models.ProductImportError{Row: line, Column: "name", Message: "name or id is required"})
+	}
+	return row, errs
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// countRows counts the distinct rows in a list of errors.
+func countRows(rowErrors []models.ProductImportError) int {
+	rows := make(map[int]bool)
+	for _, e := range rowErrors {
+		rows[e.Row] = true
+	}
+	return len(rows)
+}
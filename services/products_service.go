@@ -1,34 +1,149 @@
 package services
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go-kasir-api/images"
 	"go-kasir-api/models"
 	"go-kasir-api/repositories"
+	"log"
 )
 
+// thumbnailSize is the longest side, in pixels, of a product thumbnail.
+const thumbnailSize = 256
+
 type ProductService struct {
-	productRepo *repositories.ProductRepository
+	productRepo       *repositories.ProductRepository
+	stockMovementRepo *repositories.StockMovementRepository
+	imageStore        ImageStore
+}
+
+func NewProductService(productRepo *repositories.ProductRepository, stockMovementRepo *repositories.StockMovementRepository, imageStore ImageStore) *ProductService {
+	return &ProductService{productRepo: productRepo, stockMovementRepo: stockMovementRepo, imageStore: imageStore}
+}
+
+func (s *ProductService) GetAll(name string, categoryID *int) ([]models.Product, error) {
+	return s.productRepo.GetAll(name, categoryID)
 }
 
-func NewProductService(productRepo *repositories.ProductRepository) *ProductService {
-	return &ProductService{productRepo: productRepo}
+func (s *ProductService) EachProduct(name string, categoryID *int, fn func(models.Product) error) error {
+	return s.productRepo.EachProduct(name, categoryID, fn)
 }
 
-func (s *ProductService) GetAll(name string) ([]models.Product, error) {
-	return s.productRepo.GetAll(name)
+// Search ranks the products matching text, best first.
+func (s *ProductService) Search(text string, categoryID *int, limit int) ([]models.ProductSearchResult, error) {
+	return s.productRepo.Search(text, categoryID, limit)
 }
 
-func (s *ProductService) Create(product models.Product) (models.Product, error) {
-	return s.productRepo.Create(product)
+func (s *ProductService) Create(product models.Product, changedBy string) (models.Product, error) {
+	return s.productRepo.Create(product, changedBy)
 }
 
 func (s *ProductService) GetByID(id int) (models.Product, error) {
 	return s.productRepo.GetByID(id)
 }
 
-func (s *ProductService) Update(id int, product models.Product) (models.Product, error) {
-	return s.productRepo.Update(id, product)
+// GetVariants lists the variants of a product with options.
+func (s *ProductService) GetVariants(id int) ([]models.Product, error) {
+	if _, err := s.productRepo.GetByID(id); err != nil {
+		return nil, err
+	}
+	return s.productRepo.GetVariants(id)
 }
 
+func (s *ProductService) CreateVariant(parentID int, variant models.Product, changedBy string) (models.Product, error) {
+	variant.ParentID = &parentID
+	return s.productRepo.Create(variant, changedBy)
+}
+
+func (s *ProductService) Update(id int, product models.Product, changedBy string) (models.Product, error) {
+	return s.productRepo.Update(id, product, changedBy)
+}
+
+// Delete removes a product along with its image files.
 func (s *ProductService) Delete(id int) error {
-	return s.productRepo.Delete(id)
+	image, err := s.productRepo.Delete(id)
+	if err != nil {
+		return err
+	}
+	if image != nil {
+		s.deleteImageFiles(*image)
+	}
+	return nil
+}
+
+// SetImage stores an uploaded picture and its thumbnail as a product's image,
+// replacing the one it had. Files are stored under a new name each time, so a
+// cached old image is never mistaken for the new one.
+func (s *ProductService) SetImage(id int, image *images.Image) (models.ProductImage, error) {
+	if _, err := s.productRepo.GetByID(id); err != nil {
+		return models.ProductImage{}, err
+	}
+	thumbnail, err := image.Thumbnail(thumbnailSize)
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return models.ProductImage{}, err
+	}
+	name := fmt.Sprintf("products/%d/%s", id, hex.EncodeToString(token))
+	record := models.ProductImage{
+		ProductID:    id,
+		ContentType:  image.ContentType,
+		Size:         len(image.Data),
+		Width:        image.Width,
+		Height:       image.Height,
+		Key:          name + image.Extension,
+		ThumbnailKey: name + "_thumb.jpg",
+	}
+	record.URL, err = s.imageStore.Save(record.Key, image.ContentType, image.Data)
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+	record.ThumbnailURL, err = s.imageStore.Save(record.ThumbnailKey, "image/jpeg", thumbnail)
+	if err != nil {
+		s.deleteImageFiles(record)
+		return models.ProductImage{}, err
+	}
+
+	saved, old, err := s.productRepo.SetImage(record)
+	if err != nil {
+		s.deleteImageFiles(record)
+		return models.ProductImage{}, err
+	}
+	if old != nil {
+		s.deleteImageFiles(*old)
+	}
+	return saved, nil
+}
+
+// RemoveImage takes a product's image away and deletes its files.
+func (s *ProductService) RemoveImage(id int) error {
+	image, err := s.productRepo.RemoveImage(id)
+	if err != nil {
+		return err
+	}
+	s.deleteImageFiles(image)
+	return nil
+}
+
+// deleteImageFiles removes an image's files once nothing refers to them. The
+// database change they follow has already been made, so a failure is logged
+// and leaves an orphaned file rather than failing the request.
+func (s *ProductService) deleteImageFiles(image models.ProductImage) {
+	for _, key := range []string{image.Key, image.ThumbnailKey} {
+		if err := s.imageStore.Delete(key); err != nil {
+			log.Printf("Failed to delete image file %s: %v", key, err)
+		}
+	}
+}
+
+func (s *ProductService) GetStockMovements(id int) ([]models.StockMovement, error) {
+	if _, err := s.productRepo.GetByID(id); err != nil {
+		return nil, err
+	}
+	return s.stockMovementRepo.GetByProductID(id)
 }
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type PurchaseOrderService struct {
+	repository *repositories.PurchaseOrderRepository
+}
+
+func NewPurchaseOrderService(repository *repositories.PurchaseOrderRepository) *PurchaseOrderService {
+	return &PurchaseOrderService{repository: repository}
+}
+
+func (s *PurchaseOrderService) GetAll(status string, supplierID int) ([]models.PurchaseOrder, error) {
+	return s.repository.GetAll(status, supplierID)
+}
+
+func (s *PurchaseOrderService) GetByID(id int) (*models.PurchaseOrder, error) {
+	return s.repository.GetByID(id)
+}
+
+func (s *PurchaseOrderService) Create(order models.PurchaseOrder) (*models.PurchaseOrder, error) {
+	return s.repository.Create(order)
+}
+
+func (s *PurchaseOrderService) Update(id int, order models.PurchaseOrder) (*models.PurchaseOrder, error) {
+	return s.repository.Update(id, order)
+}
+
+func (s *PurchaseOrderService) Delete(id int) error {
+	return s.repository.Delete(id)
+}
+
+func (s *PurchaseOrderService) Submit(id int) (*models.PurchaseOrder, error) {
+	return s.repository.UpdateStatus(id, models.PurchaseOrderOrdered, models.PurchaseOrderDraft)
+}
+
+// Cancel closes an order. Anything already received stays in stock; only the
+// outstanding quantities are dropped.
+func (s *PurchaseOrderService) Cancel(id int) (*models.PurchaseOrder, error) {
+	return s.repository.UpdateStatus(id, models.PurchaseOrderCancelled,
+		models.PurchaseOrderDraft, models.PurchaseOrderOrdered, models.PurchaseOrderPartiallyReceived)
+}
+
+func (s *PurchaseOrderService) Receive(id int, req models.ReceiveRequest) (*models.GoodsReceipt, error) {
+	return s.repository.Receive(id, req)
+}
+
+func (s *PurchaseOrderService) GetOutstandingBySupplier(outletID *int, categoryID *int) ([]models.OutstandingPurchaseOrders, error) {
+	return s.repository.GetOutstandingBySupplier(outletID, categoryID)
+}
+
+func (s *PurchaseOrderService) EachOutstandingBySupplier(outletID *int, categoryID *int, fn func(models.OutstandingPurchaseOrders) error) error {
+	return s.repository.EachOutstandingBySupplier(outletID, categoryID, fn)
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+	"time"
+)
+
+type ReportService struct {
+	repository *repositories.ReportRepository
+}
+
+func NewReportService(repository *repositories.ReportRepository) *ReportService {
+	return &ReportService{repository: repository}
+}
+
+func (s *ReportService) GetProfit(start time.Time, end time.Time, outletID *int, categoryID *int, groupBy string) (*models.ProfitReport, error) {
+	return s.repository.GetProfit(start, end, outletID, categoryID, groupBy)
+}
+
+// CheckProfitGroupBy returns an error for a group_by the profit report does
+// not support.
+func (s *ReportService) CheckProfitGroupBy(groupBy string) error {
+	return repositories.CheckProfitGroupBy(groupBy)
+}
+
+func (s *ReportService) GetSalesSeries(start time.Time, end time.Time, outletID *int, categoryID *int, interval string) (*models.SalesSeries, error) {
+	return s.repository.GetSalesSeries(start, end, outletID, categoryID, interval)
+}
+
+// CheckSalesSeries returns an error for an interval the sales series does not
+// support, or a range with too many buckets of it.
+func (s *ReportService) CheckSalesSeries(start time.Time, end time.Time, interval string) error {
+	return repositories.CheckSalesSeries(start, end, interval)
+}
+
+func (s *ReportService) GetSalesBreakdown(start time.Time, end time.Time, outletID *int, categoryID *int, dimension string, rankBy string, ascending bool, limit int) (*models.SalesBreakdown, error) {
+	return s.repository.GetSalesBreakdown(start, end, outletID, categoryID, dimension, rankBy, ascending, limit)
+}
+
+// CheckRankBy returns an error for a rank_by the sales breakdowns do not
+// support.
+func (s *ReportService) CheckRankBy(rankBy string) error {
+	return repositories.CheckRankBy(rankBy)
+}
+
+func (s *ReportService) GetDeadStock(days int, outletID *int, categoryID *int) (*models.DeadStockReport, error) {
+	return s.repository.GetDeadStock(days, outletID, categoryID)
+}
+
+func (s *ReportService) EachProfitLine(start time.Time, end time.Time, outletID *int, categoryID *int, groupBy string, fn func(models.ProfitReportLine) error) error {
+	return s.repository.EachProfitLine(start, end, outletID, categoryID, groupBy, fn)
+}
+
+func (s *ReportService) EachSalesSeriesPoint(start time.Time, end time.Time, outletID *int, categoryID *int, interval string, fn func(models.SalesSeriesPoint) error) error {
+	return s.repository.EachSalesSeriesPoint(start, end, outletID, categoryID, interval, fn)
+}
+
+func (s *ReportService) EachSalesBreakdownLine(start time.Time, end time.Time, outletID *int, categoryID *int, dimension string, rankBy string, ascending bool, limit int, fn func(models.SalesBreakdownLine) error) error {
+	return s.repository.EachSalesBreakdownLine(start, end, outletID, categoryID, dimension, rankBy, ascending, limit, fn)
+}
+
+func (s *ReportService) EachDeadStockItem(days int, outletID *int, categoryID *int, fn func(models.DeadStockItem) error) error {
+	return s.repository.EachDeadStockItem(days, outletID, categoryID, fn)
+}
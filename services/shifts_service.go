@@ -0,0 +1,38 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type ShiftService struct {
+	repository *repositories.ShiftRepository
+}
+
+func NewShiftService(repository *repositories.ShiftRepository) *ShiftService {
+	return &ShiftService{repository: repository}
+}
+
+func (s *ShiftService) GetAll(status string, cashierID int) ([]models.Shift, error) {
+	return s.repository.GetAll(status, cashierID)
+}
+
+func (s *ShiftService) GetByID(id int) (*models.Shift, error) {
+	return s.repository.GetByID(id)
+}
+
+func (s *ShiftService) Open(req models.OpenShiftRequest) (*models.Shift, error) {
+	return s.repository.Open(req)
+}
+
+func (s *ShiftService) AddCashMovement(shiftID int, movement models.CashMovement) (*models.CashMovement, error) {
+	return s.repository.AddCashMovement(shiftID, movement)
+}
+
+func (s *ShiftService) Close(id int, req models.CloseShiftRequest) (*models.ShiftReport, error) {
+	return s.repository.Close(id, req)
+}
+
+func (s *ShiftService) GetReport(id int) (*models.ShiftReport, error) {
+	return s.repository.GetReport(id)
+}
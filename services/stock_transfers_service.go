@@ -0,0 +1,42 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type StockTransferService struct {
+	repository *repositories.StockTransferRepository
+}
+
+func NewStockTransferService(repository *repositories.StockTransferRepository) *StockTransferService {
+	return &StockTransferService{repository: repository}
+}
+
+func (s *StockTransferService) GetAll(status string, outletID int) ([]models.StockTransfer, error) {
+	return s.repository.GetAll(status, outletID)
+}
+
+func (s *StockTransferService) GetByID(id int) (*models.StockTransfer, error) {
+	return s.repository.GetByID(id)
+}
+
+func (s *StockTransferService) Create(transfer models.StockTransfer) (*models.StockTransfer, error) {
+	return s.repository.Create(transfer)
+}
+
+func (s *StockTransferService) Update(id int, transfer models.StockTransfer) (*models.StockTransfer, error) {
+	return s.repository.Update(id, transfer)
+}
+
+func (s *StockTransferService) Delete(id int) error {
+	return s.repository.Delete(id)
+}
+
+func (s *StockTransferService) Dispatch(id int) (*models.StockTransfer, error) {
+	return s.repository.Dispatch(id)
+}
+
+func (s *StockTransferService) Receive(id int, req models.ReceiveTransferRequest) (*models.StockTransfer, error) {
+	return s.repository.Receive(id, req)
+}
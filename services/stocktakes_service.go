@@ -0,0 +1,46 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type StocktakeService struct {
+	repository *repositories.StocktakeRepository
+}
+
+func NewStocktakeService(repository *repositories.StocktakeRepository) *StocktakeService {
+	return &StocktakeService{repository: repository}
+}
+
+func (s *StocktakeService) GetAll(status string) ([]models.Stocktake, error) {
+	return s.repository.GetAll(status)
+}
+
+func (s *StocktakeService) GetByID(id int) (*models.Stocktake, error) {
+	return s.repository.GetByID(id)
+}
+
+func (s *StocktakeService) Open(stocktake models.Stocktake) (*models.Stocktake, error) {
+	return s.repository.Open(stocktake)
+}
+
+func (s *StocktakeService) SubmitCounts(id int, req models.StocktakeCountRequest) (*models.Stocktake, error) {
+	return s.repository.SubmitCounts(id, req)
+}
+
+func (s *StocktakeService) GetVariance(id int) (*models.StocktakeVariance, error) {
+	return s.repository.GetVariance(id)
+}
+
+func (s *StocktakeService) EachVarianceLine(id int, fn func(models.StocktakeVarianceLine) error) error {
+	return s.repository.EachVarianceLine(id, fn)
+}
+
+func (s *StocktakeService) Finalize(id int, req models.FinalizeStocktakeRequest) (*models.StocktakeVariance, error) {
+	return s.repository.Finalize(id, req)
+}
+
+func (s *StocktakeService) Cancel(id int) (*models.Stocktake, error) {
+	return s.repository.Cancel(id)
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type SupplierService struct {
+	repository *repositories.SupplierRepository
+}
+
+func NewSupplierService(repository *repositories.SupplierRepository) *SupplierService {
+	return &SupplierService{repository: repository}
+}
+
+func (s *SupplierService) GetAll(name string) ([]models.Supplier, error) {
+	return s.repository.GetAll(name)
+}
+
+func (s *SupplierService) Create(supplier models.Supplier) (models.Supplier, error) {
+	return s.repository.Create(supplier)
+}
+
+func (s *SupplierService) GetByID(id int) (models.Supplier, error) {
+	return s.repository.GetByID(id)
+}
+
+func (s *SupplierService) Update(id int, supplier models.Supplier) (models.Supplier, error) {
+	return s.repository.Update(id, supplier)
+}
+
+func (s *SupplierService) Delete(id int) error {
+	return s.repository.Delete(id)
+}
@@ -1,27 +1,114 @@
 package services
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"go-kasir-api/models"
 	"go-kasir-api/repositories"
+	"time"
 )
 
+var ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used with a different request body")
+
 type TransactionService struct {
 	productRepo     *repositories.ProductRepository
 	transactionRepo *repositories.TransactionRepository
+	idempotencyRepo *repositories.IdempotencyRepository
+	reportRepo      *repositories.ReportRepository
+	idempotencyTTL  time.Duration
+}
+
+func NewTransactionService(productRepo *repositories.ProductRepository, transactionRepo *repositories.TransactionRepository, idempotencyRepo *repositories.IdempotencyRepository, reportRepo *repositories.ReportRepository, idempotencyTTL time.Duration) *TransactionService {
+	return &TransactionService{
+		productRepo:     productRepo,
+		transactionRepo: transactionRepo,
+		idempotencyRepo: idempotencyRepo,
+		reportRepo:      reportRepo,
+		idempotencyTTL:  idempotencyTTL,
+	}
+}
+
+func (s *TransactionService) Create(req models.CheckoutRequest) (*models.Transaction, error) {
+	return s.transactionRepo.Create(req, nil)
+}
+
+// CreateIdempotent performs the checkout at most once per key. A retry with the
+// same key and body gets the stored response back instead of a new sale.
+func (s *TransactionService) CreateIdempotent(key string, req models.CheckoutRequest) (*models.Transaction, *models.IdempotencyKey, error) {
+	hash, err := hashCheckoutRequest(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := s.lookupIdempotencyKey(key, hash)
+	if err != nil || stored != nil {
+		return nil, stored, err
+	}
+
+	record := &models.IdempotencyKey{
+		Key:         key,
+		RequestHash: hash,
+		TTL:         s.idempotencyTTL,
+	}
+	transaction, err := s.transactionRepo.Create(req, record)
+	if errors.Is(err, repositories.ErrIdempotencyKeyExists) {
+		// A concurrent request with the same key committed first.
+		stored, err = s.lookupIdempotencyKey(key, hash)
+		if err == nil && stored == nil {
+			err = repositories.ErrIdempotencyKeyExists
+		}
+		return nil, stored, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return transaction, nil, nil
+}
+
+func (s *TransactionService) PurgeExpiredIdempotencyKeys() (int64, error) {
+	return s.idempotencyRepo.DeleteExpired()
+}
+
+func (s *TransactionService) lookupIdempotencyKey(key string, hash string) (*models.IdempotencyKey, error) {
+	stored, err := s.idempotencyRepo.GetByKey(key)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.RequestHash != hash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	return stored, nil
+}
+
+func hashCheckoutRequest(req models.CheckoutRequest) (string, error) {
+	// Hash the decoded request rather than the raw bytes so formatting
+	// differences between retries do not count as a different body.
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
 }
 
-func NewTransactionService(productRepo *repositories.ProductRepository, transactionRepo *repositories.TransactionRepository) *TransactionService {
-	return &TransactionService{productRepo: productRepo, transactionRepo: transactionRepo}
+func (s *TransactionService) GetTransactionReport(start time.Time, end time.Time, outletID *int, categoryID *int) (*models.TransactionReport, error) {
+	return s.reportRepo.GetTransactionReport(start, end, outletID, categoryID)
 }
 
-func (s *TransactionService) Create(items []models.CheckoutItem) (*models.Transaction, error) {
-	return s.transactionRepo.Create(items)
+func (s *TransactionService) GetTransactionReportToday(outletID *int, categoryID *int) (*models.TransactionReport, error) {
+	return s.reportRepo.GetTransactionReport(time.Time{}, time.Time{}, outletID, categoryID)
 }
 
-func (s *TransactionService) GetTransactionReport(start string, end string) (*models.TransactionReport, error) {
-	return s.transactionRepo.GetTransactionReport(start, end)
+func (s *TransactionService) GetTransactions(start time.Time, end time.Time, outletID *int, categoryID *int) ([]models.Transaction, error) {
+	return s.reportRepo.GetTransactions(start, end, outletID, categoryID)
 }
 
-func (s *TransactionService) GetTransactionReportToday() (*models.TransactionReport, error) {
-	return s.transactionRepo.GetTransactionReportToday()
+func (s *TransactionService) EachTransactionLine(start time.Time, end time.Time, outletID *int, categoryID *int, fn func(models.Transaction, models.TransactionDetail) error) error {
+	return s.reportRepo.EachTransactionLine(start, end, outletID, categoryID, fn)
 }
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"go-kasir-api/models"
+	"go-kasir-api/repositories"
+)
+
+type UnitService struct {
+	repository *repositories.UnitRepository
+}
+
+func NewUnitService(repository *repositories.UnitRepository) *UnitService {
+	return &UnitService{repository: repository}
+}
+
+func (s *UnitService) GetAll() ([]models.Unit, error) {
+	return s.repository.GetAll()
+}
+
+func (s *UnitService) Create(unit models.Unit) (models.Unit, error) {
+	return s.repository.Create(unit)
+}
+
+func (s *UnitService) Update(code string, unit models.Unit) (models.Unit, error) {
+	return s.repository.Update(code, unit)
+}
+
+func (s *UnitService) Delete(code string) error {
+	return s.repository.Delete(code)
+}
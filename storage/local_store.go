@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps uploaded files in a directory on the server's disk. The
+// URLs it hands out start with baseURL, where Handler or a web server in
+// front of the API must serve the directory.
+type LocalStore struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocalStore(dir string, baseURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Save writes data under key and returns the URL it is served at. The file is
+// written in full before it appears under its name, so a half-written upload
+// is never served.
+func (s *LocalStore) Save(key string, contentType string, data []byte) (string, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", err
+	}
+	return s.baseURL + "/" + key, nil
+}
+
+// Delete removes the file under key, and its directory once that is empty. A
+// file that is already gone is not an error.
+func (s *LocalStore) Delete(key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if dir := filepath.Dir(name); dir != filepath.Clean(s.dir) {
+		os.Remove(dir)
+	}
+	return nil
+}
+
+// Handler serves the stored files by key. Directories are not listed.
+func (s *LocalStore) Handler() http.Handler {
+	return http.FileServer(filesOnly{http.Dir(s.dir)})
+}
+
+// path maps a key to its file, refusing keys that would leave the directory.
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", errors.New("invalid storage key " + key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// filesOnly hides directories so the file server does not list them.
+type filesOnly struct {
+	fs http.FileSystem
+}
+
+func (f filesOnly) Open(name string) (http.File, error) {
+	file, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, os.ErrNotExist
+	}
+	return file, nil
+}